@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/png"
+	"log"
+	"os"
+
+	"github.com/flaticols/loglutgen/luts"
+	_ "golang.org/x/image/tiff"
+)
+
+// readImage decodes a PNG or TIFF file. Any embedded color profile or gamma
+// is ignored: code values are used as they are stored.
+func readImage(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	img, _, err := image.Decode(f)
+	return img, err
+}
+
+// is8Bit reports whether img stores at most 8 bits per channel.
+func is8Bit(img image.Image) bool {
+	switch img.ColorModel() {
+	case color.RGBA64Model, color.NRGBA64Model, color.Gray16Model, color.Alpha16Model:
+		return false
+	}
+	return true
+}
+
+// applyLUTToImage generates the LUT for cfg, runs every pixel of the image at
+// inPath through it as an Apple Log encoded value, and writes the graded
+// result to outPath as a 16-bit PNG. With markClipping, clipped pixels are
+// painted with marker colors instead (see luts.ApplyImageMarked).
+func applyLUTToImage(cfg luts.Config, inPath, outPath string, markClipping bool) error {
+	img, err := readImage(inPath)
+	if err != nil {
+		return fmt.Errorf("reading image %s: %w", inPath, err)
+	}
+	if is8Bit(img) {
+		log.Printf("Warning: %s has 8 bits per channel; expect banding once the log curve is expanded (use 16-bit TIFF or PNG)\n", inPath)
+	}
+	cube, _ := luts.BuildCube(cfg)
+	apply := luts.ApplyImage
+	if markClipping {
+		apply = luts.ApplyImageMarked
+	}
+	if err := writePNG(outPath, apply(img, cube)); err != nil {
+		return fmt.Errorf("writing image %s: %w", outPath, err)
+	}
+	return nil
+}
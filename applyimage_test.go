@@ -0,0 +1,71 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+func TestApplyImageMarksClippedPixels(t *testing.T) {
+	dir := t.TempDir()
+	frame := image.NewNRGBA64(image.Rect(0, 0, 4, 2))
+	for y := 0; y < 2; y++ {
+		frame.SetNRGBA64(0, y, color.NRGBA64{A: 0xffff})                                  // Crushed black
+		frame.SetNRGBA64(1, y, color.NRGBA64{R: 0xffff, G: 0xffff, B: 0xffff, A: 0x8000}) // Blown white, half transparent
+		frame.SetNRGBA64(2, y, color.NRGBA64{R: 0x8000, G: 0x8000, B: 0x8000, A: 0xffff}) // Mid-gray
+		frame.SetNRGBA64(3, y, color.NRGBA64{R: 0x6000, G: 0x5000, B: 0x4000, A: 0xffff}) // Midtone skin
+	}
+	in := filepath.Join(dir, "frame.png")
+	if err := writePNG(in, frame); err != nil {
+		t.Fatal(err)
+	}
+	cfg := luts.Config{Size: 17}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	read := func(markClipping bool) image.Image {
+		out := filepath.Join(dir, "graded.png")
+		if err := applyLUTToImage(cfg, in, out, markClipping); err != nil {
+			t.Fatal(err)
+		}
+		img, err := readImage(out)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if img.Bounds() != frame.Bounds() {
+			t.Fatalf("graded bounds %v, want %v", img.Bounds(), frame.Bounds())
+		}
+		return img
+	}
+	at := func(img image.Image, x, y int) color.NRGBA64 {
+		return color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+	}
+
+	plain, marked := read(false), read(true)
+	magenta := color.NRGBA64{R: 0xffff, B: 0xffff, A: 0x8000}
+	green := color.NRGBA64{G: 0xffff, A: 0xffff}
+	for y := 0; y < 2; y++ {
+		if got := at(marked, 0, y); got != green {
+			t.Errorf("crushed black at (0, %d) = %v, want the green marker %v", y, got, green)
+		}
+		if got := at(marked, 1, y); got != magenta {
+			t.Errorf("blown white at (1, %d) = %v, want the magenta marker %v with the pixel's alpha", y, got, magenta)
+		}
+		for x := 2; x < 4; x++ {
+			if got, want := at(marked, x, y), at(plain, x, y); got != want {
+				t.Errorf("unclipped pixel (%d, %d) = %v, want the graded %v", x, y, got, want)
+			}
+		}
+		if got := at(plain, 0, y); got == green {
+			t.Errorf("crushed black at (0, %d) is marked without -markClipping", y)
+		}
+		if got := at(plain, 1, y); got == magenta {
+			t.Errorf("blown white at (1, %d) is marked without -markClipping", y)
+		}
+	}
+}
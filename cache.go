@@ -0,0 +1,132 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"runtime/debug"
+	"sync"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// cacheFileName is the name of the incremental-generation cache written to
+// the output directory by -cache.
+const cacheFileName = ".lutcache.json"
+
+// lutCache maps each config source to the hash of everything its output
+// depends on and the hashes of the files it produced, so unchanged configs
+// can be skipped on later runs.
+type lutCache struct {
+	Version string                `json:"version"` // Tool version the entries were produced by
+	Entries map[string]cacheEntry `json:"entries"` // Keyed by config path
+
+	path string
+	mu   sync.Mutex // Guards Entries while configs are processed concurrently
+}
+
+type cacheEntry struct {
+	InputHash string            `json:"input_hash"` // Config content plus dependencies
+	Outputs   map[string]string `json:"outputs"`    // Output path -> SHA-256 of its content
+}
+
+// toolVersion identifies the running build for cache invalidation. It hashes
+// the executable so any rebuild invalidates the cache, falling back to the
+// module build info when the executable cannot be read.
+func toolVersion() string {
+	if exe, err := os.Executable(); err == nil {
+		if f, err := os.Open(exe); err == nil {
+			defer f.Close()
+			h := sha256.New()
+			if _, err := io.Copy(h, f); err == nil {
+				return hex.EncodeToString(h.Sum(nil))
+			}
+		}
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "unknown"
+}
+
+// loadCache reads the cache at path. A missing file, or one written by a
+// different tool version, yields an empty cache.
+func loadCache(path, version string) (*lutCache, error) {
+	c := &lutCache{Version: version, Entries: map[string]cacheEntry{}, path: path}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var stored lutCache
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	if stored.Version == version && stored.Entries != nil {
+		c.Entries = stored.Entries
+	}
+	return c, nil
+}
+
+// save writes the cache back to its file.
+func (c *lutCache) save() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(c.path, append(data, '\n'), 0644)
+}
+
+// fresh reports whether source was last generated from inputs with the same
+// hash and all of its outputs still exist with the recorded content.
+func (c *lutCache) fresh(source, inputHash string) bool {
+	c.mu.Lock()
+	e, ok := c.Entries[source]
+	c.mu.Unlock()
+	if !ok || e.InputHash != inputHash || len(e.Outputs) == 0 {
+		return false
+	}
+	for path, want := range e.Outputs {
+		data, err := os.ReadFile(path)
+		if err != nil || hashBytes(data) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// record stores the input hash and output hashes produced for source.
+func (c *lutCache) record(source, inputHash string, outputs map[string]string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Entries[source] = cacheEntry{InputHash: inputHash, Outputs: outputs}
+}
+
+// hashBytes returns the hex SHA-256 of data.
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// configInputHash hashes a config's effective settings, after presets,
+// defaults, and output name expansion, together with the run-wide options its
+// outputs depend on. Edits that leave the settings unchanged, such as
+// reformatting the file or spelling out a default, keep the hash.
+func configInputHash(cfg luts.Config, opts runOptions) string {
+	h := sha256.New()
+	settings, _ := json.Marshal(cfg) // Config holds only plain JSON values
+	fmt.Fprintf(h, "config %d\n", len(settings))
+	h.Write(settings)
+	fmt.Fprintf(h, "outputDir %q checksums %t preview %t\n", opts.outputDir, opts.checksums, opts.preview)
+	fmt.Fprintf(h, "flatten %t configSub %q\n", opts.flatten != nil, opts.configSub)
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheSkipsUnchangedConfigs(t *testing.T) {
+	dir := t.TempDir()
+	cachePath := filepath.Join(dir, cacheFileName)
+	cache, err := loadCache(cachePath, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := runOptions{outputDir: dir, cache: cache}
+	original := []byte(`{"size": 17, "output": "look.cube"}`)
+
+	if err := processConfig("look.json", original, opts); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	first := cache.Entries["look.json"].InputHash
+	if err := processConfig("look.json", original, opts); !errors.Is(err, errUnchanged) {
+		t.Fatalf("unchanged config: error %v, want errUnchanged", err)
+	}
+
+	edited := []byte(`{"size": 18, "output": "look.cube"}`)
+	if err := processConfig("look.json", edited, opts); err != nil {
+		t.Fatalf("edited config: %v", err)
+	}
+	if cache.Entries["look.json"].InputHash == first {
+		t.Error("cache entry was not updated after the edit")
+	}
+
+	if err := cache.save(); err != nil {
+		t.Fatal(err)
+	}
+	reloaded, err := loadCache(cachePath, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts.cache = reloaded
+	if err := processConfig("look.json", edited, opts); !errors.Is(err, errUnchanged) {
+		t.Errorf("after reload: error %v, want errUnchanged", err)
+	}
+
+	upgraded, err := loadCache(cachePath, "v2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(upgraded.Entries) != 0 {
+		t.Errorf("a new tool version kept %d cache entries", len(upgraded.Entries))
+	}
+}
+
+func TestCacheTracksFlatteningAndChecksums(t *testing.T) {
+	configDir, outputDir := t.TempDir(), t.TempDir()
+	path := filepath.Join(configDir, "projectA", "shot1.json")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"size": 2, "output": "output.cube"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cache, err := loadCache(filepath.Join(outputDir, cacheFileName), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := runOptions{configDir: configDir, outputDir: outputDir, cache: cache, checksums: true}
+	if err := processConfigFile(path, opts); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+
+	sidecar := filepath.Join(outputDir, "projectA", "output.cube.sha256")
+	if err := os.Remove(sidecar); err != nil {
+		t.Fatal(err)
+	}
+	if err := processConfigFile(path, opts); err != nil {
+		t.Fatalf("after removing the checksum file: %v", err)
+	}
+	if !exists(sidecar) {
+		t.Error("the removed checksum file was not rewritten")
+	}
+
+	opts.flatten = &flatNames{owners: map[string]string{}}
+	if err := processConfigFile(path, opts); err != nil {
+		t.Fatalf("flattened run: %v", err)
+	}
+	if !exists(filepath.Join(outputDir, "projectA_output.cube")) {
+		t.Error("switching to -flatten did not write the flattened output")
+	}
+	if err := processConfigFile(path, opts); !errors.Is(err, errUnchanged) {
+		t.Errorf("unchanged flattened config: error %v, want errUnchanged", err)
+	}
+}
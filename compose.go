@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// readCubeFile parses the .cube file at path.
+func readCubeFile(path string) (*luts.Cube, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	cube, err := luts.ParseCube(f)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return cube, nil
+}
+
+// checkDataFormat rejects the output formats that are rendered from a
+// config's pipeline rather than written from LUT data.
+func checkDataFormat(cfg luts.Config) error {
+	switch strings.ToLower(cfg.OutputFormat) {
+	case "hald", "dctl":
+		return fmt.Errorf("format %s cannot be written from LUT data (valid: cube, 3dl, vlt)", cfg.OutputFormat)
+	}
+	return nil
+}
+
+// composeFiles bakes the LUT at firstPath followed by the one at secondPath
+// into a single LUT written to outPath, in the cube, 3dl, or vlt format its
+// extension selects.
+func composeFiles(firstPath, secondPath, outPath, separator string, checksums bool) error {
+	first, err := readCubeFile(firstPath)
+	if err != nil {
+		return err
+	}
+	second, err := readCubeFile(secondPath)
+	if err != nil {
+		return err
+	}
+	cube := luts.ComposeCubes(first, second)
+
+	cfg := luts.Config{Output: outPath, Size: cube.Size, DomainMin: cube.DomainMin, DomainMax: cube.DomainMax, Separator: separator,
+		Comment: fmt.Sprintf("Composed from %s followed by %s", filepath.Base(firstPath), filepath.Base(secondPath))}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+	if err := checkDataFormat(cfg); err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+	return writeOutput(outPath, stampVersion(cfg, luts.FormatCube(cfg, cube)), checksums)
+}
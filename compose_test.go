@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestComposeFiles(t *testing.T) {
+	dir := t.TempDir()
+	opts := runOptions{outputDir: dir}
+	for name, doc := range map[string]string{
+		"convert.json": `{"size": 9, "output": "convert.cube"}`,
+		"look.json":    `{"size": 5, "identity": true, "output": "look.cube"}`,
+	} {
+		if err := processConfig(name, []byte(doc), opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	convert, look := filepath.Join(dir, "convert.cube"), filepath.Join(dir, "look.cube")
+	out := filepath.Join(dir, "baked.cube")
+	if err := composeFiles(convert, look, out, "", false); err != nil {
+		t.Fatal(err)
+	}
+	baked, err := readCubeFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	original, err := readCubeFile(convert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if baked.Size != 9 {
+		t.Errorf("baked size %d, want 9", baked.Size)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n# Composed from convert.cube followed by look.cube\n"; !strings.Contains(string(data), want) {
+		t.Errorf("baked LUT header lacks %q:\n%s", want[1:], strings.Join(strings.SplitAfter(string(data), "\n")[:3], ""))
+	}
+	for n := range original.Data {
+		for c := range original.Data[n] {
+			if d := baked.Data[n][c] - original.Data[n][c]; d > 1e-6 || d < -1e-6 {
+				t.Fatalf("node %d: baking with an identity LUT gives %v, want %v", n, baked.Data[n], original.Data[n])
+			}
+		}
+	}
+
+	for _, name := range []string{"baked.png", "baked.dctl"} {
+		err := composeFiles(convert, look, filepath.Join(dir, name), "", false)
+		if err == nil || !strings.Contains(err.Error(), "cannot be written from LUT data") {
+			t.Errorf("composing to %s: error %v", name, err)
+		}
+		if exists(filepath.Join(dir, name)) {
+			t.Errorf("%s was written", name)
+		}
+	}
+
+	broken := filepath.Join(dir, "broken.cube")
+	if err := os.WriteFile(broken, []byte("LUT_3D_SIZE 2\n0 0 0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err = composeFiles(convert, broken, filepath.Join(dir, "never.cube"), "", false)
+	if err == nil || !strings.Contains(err.Error(), broken) {
+		t.Errorf("composing with a truncated cube: error %v, want one naming %s", err, broken)
+	}
+	if exists(filepath.Join(dir, "never.cube")) {
+		t.Error("output was written despite the malformed input")
+	}
+}
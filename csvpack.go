@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// csvRequiredColumns lists the header columns a look-pack CSV must provide.
+var csvRequiredColumns = []string{"look", "output"}
+
+// configFieldKinds maps each scalar Config JSON key to the kind of its field,
+// so CSV cells can be converted to the matching JSON type. Nested fields such
+// as zone_looks cannot be expressed in a single cell and are left out.
+func configFieldKinds() map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(luts.Config{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("json"), ",")[0]
+		if tag == "" || tag == "-" {
+			continue
+		}
+		ft := t.Field(i).Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem() // Optional scalars such as look_strength
+		}
+		switch kind := ft.Kind(); kind {
+		case reflect.Int, reflect.Float64, reflect.Bool, reflect.String:
+			kinds[tag] = kind
+		}
+	}
+	return kinds
+}
+
+// configsFromCSV reads a look-pack CSV whose header row names Config JSON keys
+// and returns one JSON config document per data row. Empty cells are left out
+// so the field falls back to its preset or default value.
+func configsFromCSV(r io.Reader) ([][]byte, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+
+	kinds := configFieldKinds()
+	header := records[0]
+	seen := make(map[string]bool)
+	for i, col := range header {
+		col = strings.TrimSpace(col)
+		if _, ok := kinds[col]; !ok {
+			return nil, fmt.Errorf("unknown column %q", col)
+		}
+		header[i] = col
+		seen[col] = true
+	}
+	for _, col := range csvRequiredColumns {
+		if !seen[col] {
+			return nil, fmt.Errorf("missing required column %q", col)
+		}
+	}
+
+	var docs [][]byte
+	for n, record := range records[1:] {
+		row := n + 2 // 1-based, counting the header
+		fields := make(map[string]any)
+		for i, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if cell == "" {
+				continue
+			}
+			col := header[i]
+			switch kinds[col] {
+			case reflect.Int, reflect.Float64:
+				v, err := strconv.ParseFloat(cell, 64)
+				if err != nil {
+					return nil, fmt.Errorf("row %d, column %s: %w", row, col, err)
+				}
+				fields[col] = v
+			case reflect.Bool:
+				v, err := strconv.ParseBool(cell)
+				if err != nil {
+					return nil, fmt.Errorf("row %d, column %s: %w", row, col, err)
+				}
+				fields[col] = v
+			default:
+				fields[col] = cell
+			}
+		}
+		doc, err := json.Marshal(fields)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", row, err)
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
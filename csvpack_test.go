@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// generateFromJSON parses a config document the way the batch run does and
+// returns the LUT it generates.
+func generateFromJSON(t *testing.T, doc string) string {
+	t.Helper()
+	cfg, err := parseConfig([]byte(doc), luts.Config{})
+	if err != nil {
+		t.Fatalf("parsing %s: %v", doc, err)
+	}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("validating %s: %v", doc, err)
+	}
+	data, err := luts.Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestCSVRowsMatchJSONConfigs(t *testing.T) {
+	docs, err := configsFromCSV(strings.NewReader(`look,output,exposure_offset,size,normalize_white
+tealOrange,teal.cube,1.1,17,
+warmVintage,warm.cube,,9,true
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`{"look": "tealOrange", "output": "teal.cube", "exposure_offset": 1.1, "size": 17}`,
+		`{"look": "warmVintage", "output": "warm.cube", "size": 9, "normalize_white": true}`,
+	}
+	if len(docs) != len(want) {
+		t.Fatalf("got %d configs, want %d", len(docs), len(want))
+	}
+	for i, doc := range docs {
+		if generateFromJSON(t, string(doc)) != generateFromJSON(t, want[i]) {
+			t.Errorf("row %d: %s generates a different LUT from %s", i+1, doc, want[i])
+		}
+	}
+}
+
+func TestCSVRequiresColumns(t *testing.T) {
+	for _, csv := range []string{
+		"look,size\ntealOrange,17\n",
+		"look,output,no_such_field\ntealOrange,a.cube,1\n",
+	} {
+		if _, err := configsFromCSV(strings.NewReader(csv)); err == nil {
+			t.Errorf("configsFromCSV accepted %q", csv)
+		}
+	}
+}
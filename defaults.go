@@ -0,0 +1,31 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// Environment variables supplying the -default* flags when they are not
+// given on the command line.
+const (
+	envDefaultExposureStops    = "LOGLUTGEN_DEFAULT_EXPOSURE_STOPS"
+	envDefaultTargetColorSpace = "LOGLUTGEN_DEFAULT_TARGET_COLOR_SPACE"
+)
+
+// configDefaults builds the run-wide defaults from the -default* flag values,
+// which are empty when unset. Precedence for each field is the config's own
+// value, then these defaults, then the built-in default from SetDefaults.
+func configDefaults(exposureStops, targetColorSpace string) (luts.Config, error) {
+	var cfg luts.Config
+	if exposureStops != "" {
+		stops, err := strconv.ParseFloat(exposureStops, 64)
+		if err != nil {
+			return cfg, fmt.Errorf("invalid -defaultExposureStops %q: %w", exposureStops, err)
+		}
+		cfg.ExposureStops = stops
+	}
+	cfg.TargetColorSpace = targetColorSpace
+	return cfg, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigDefaultsPrecedence(t *testing.T) {
+	base, err := configDefaults("0.5", "acescct")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, doc   string
+		stops       float64
+		space, look string
+		size        int
+	}{
+		{"flag over built-in", `{}`, 0.5, "acescct", "none", 17},
+		{"file over flag", `{"exposure_stops": -1, "target_color_space": "srgb"}`, -1, "srgb", "none", 17},
+		{"preset over built-in", `{"preset": "coolNight"}`, 0.5, "acescct", "tealOrange", 33},
+		{"file over preset", `{"preset": "coolNight", "look": "none", "size": 9}`, 0.5, "acescct", "none", 9},
+		{"target replaces the flag", `{"target": "appleReference", "exposure_stops": 1}`, 1, "", "none", 17},
+	} {
+		cfg, err := parseConfig([]byte(tc.doc), base)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if cfg.ExposureStops != tc.stops || cfg.TargetColorSpace != tc.space || cfg.Look != tc.look || cfg.Size != tc.size {
+			t.Errorf("%s: exposure_stops %g, target_color_space %q, look %q, size %d; want %g, %q, %q, %d",
+				tc.name, cfg.ExposureStops, cfg.TargetColorSpace, cfg.Look, cfg.Size, tc.stops, tc.space, tc.look, tc.size)
+		}
+	}
+
+	// Setting both in the file conflicts even when target_color_space
+	// repeats the flag's value.
+	cfg, err := parseConfig([]byte(`{"target": "rec709", "target_color_space": "acescct"}`), base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("target and target_color_space in one file: error %v", err)
+	}
+
+	if _, err := configDefaults("half", ""); err == nil || !strings.Contains(err.Error(), "-defaultExposureStops") {
+		t.Errorf("non-numeric -defaultExposureStops: error %v", err)
+	}
+	if cfg, err := configDefaults("", ""); err != nil || cfg.ExposureStops != 0 || cfg.TargetColorSpace != "" {
+		t.Errorf("no flags: %+v, %v; want the zero config", cfg, err)
+	}
+}
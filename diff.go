@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// diffFiles compares the LUTs at firstPath and secondPath and prints the
+// differences to w. With imagePath set, it also writes a heatmap of the
+// per-node differences there as a PNG. It reports whether the largest
+// difference exceeds tolerance; a negative tolerance disables the check.
+func diffFiles(w io.Writer, firstPath, secondPath, imagePath string, tolerance float64) (bool, error) {
+	first, err := readCubeFile(firstPath)
+	if err != nil {
+		return false, err
+	}
+	second, err := readCubeFile(secondPath)
+	if err != nil {
+		return false, err
+	}
+	d := luts.DiffCubes(first, second)
+	if imagePath != "" {
+		if err := writePNG(imagePath, luts.RenderDiffHeatmap(d)); err != nil {
+			return false, fmt.Errorf("writing heatmap %s: %w", imagePath, err)
+		}
+	}
+
+	fmt.Fprintf(w, "Compared %d^3 nodes (%s: %d, %s: %d)\n", d.Size, firstPath, first.Size, secondPath, second.Size)
+	fmt.Fprintf(w, "Max difference:  R %.6f  G %.6f  B %.6f\n", d.Max[0], d.Max[1], d.Max[2])
+	fmt.Fprintf(w, "Mean difference: R %.6f  G %.6f  B %.6f\n", d.Mean[0], d.Mean[1], d.Mean[2])
+	if d.Largest > 0 {
+		fmt.Fprintf(w, "Largest at grid (%d, %d, %d), input %.6f %.6f %.6f\n",
+			d.MaxAt[0], d.MaxAt[1], d.MaxAt[2], d.MaxIn[0], d.MaxIn[1], d.MaxIn[2])
+	}
+	return tolerance >= 0 && d.Largest > tolerance, nil
+}
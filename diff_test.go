@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDiffFilesReportsExposureOffset(t *testing.T) {
+	dir := t.TempDir()
+	opts := runOptions{outputDir: dir}
+	for name, doc := range map[string]string{
+		"base.json":   `{"size": 9, "output": "base.cube"}`,
+		"offset.json": `{"size": 9, "exposure_offset": 1.2, "output": "offset.cube"}`,
+	} {
+		if err := processConfig(name, []byte(doc), opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	basePath, offsetPath := filepath.Join(dir, "base.cube"), filepath.Join(dir, "offset.cube")
+	base, err := readCubeFile(basePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	offset, err := readCubeFile(offsetPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var maxDiff, mean [3]float64
+	for n := range base.Data {
+		for c := range base.Data[n] {
+			d := math.Abs(offset.Data[n][c] - base.Data[n][c])
+			maxDiff[c] = max(maxDiff[c], d)
+			mean[c] += d / float64(len(base.Data))
+		}
+	}
+	if maxDiff[1] == 0 {
+		t.Fatal("exposure_offset 1.2 left the LUT unchanged")
+	}
+
+	var out bytes.Buffer
+	exceeded, err := diffFiles(&out, basePath, offsetPath, "", maxDiff[1]/2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exceeded {
+		t.Error("difference within a tolerance of half the largest difference")
+	}
+	for _, want := range []string{
+		fmt.Sprintf("Max difference:  R %.6f  G %.6f  B %.6f\n", maxDiff[0], maxDiff[1], maxDiff[2]),
+		fmt.Sprintf("Mean difference: R %.6f  G %.6f  B %.6f\n", mean[0], mean[1], mean[2]),
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("report\n%s\nmissing %q", out.String(), want)
+		}
+	}
+
+	out.Reset()
+	if exceeded, err := diffFiles(&out, basePath, basePath, "", 0); err != nil || exceeded {
+		t.Errorf("identical files: exceeded %v, error %v", exceeded, err)
+	}
+	if !strings.Contains(out.String(), "Max difference:  R 0.000000  G 0.000000  B 0.000000\n") {
+		t.Errorf("identical files report\n%s", out.String())
+	}
+
+	nan := filepath.Join(dir, "nan.cube")
+	if err := os.WriteFile(nan, []byte("LUT_3D_SIZE 2\nnan 0 0\n"+strings.Repeat("0 0 0\n", 7)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := diffFiles(&out, basePath, nan, "", -1); err == nil || !strings.Contains(err.Error(), "not finite") {
+		t.Errorf("diff against a NaN cube: error %v", err)
+	}
+}
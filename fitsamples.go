@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// fitSamplesFile builds a LUT of the given size fitting the measured samples
+// in the CSV file at csvPath and writes it to outPath, in the cube, 3dl, or
+// vlt format its extension selects.
+func fitSamplesFile(csvPath, outPath string, size int, separator string, checksums bool) error {
+	f, err := os.Open(csvPath)
+	if err != nil {
+		return err
+	}
+	samples, err := luts.ParseSamples(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", csvPath, err)
+	}
+
+	cfg := luts.Config{Output: outPath, Size: size, Separator: separator,
+		Comment: fmt.Sprintf("Fitted to the measured samples in %s", filepath.Base(csvPath))}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+	if err := checkDataFormat(cfg); err != nil {
+		return fmt.Errorf("invalid output: %w", err)
+	}
+	cube := luts.FitCube(samples, cfg.Size)
+	return writeOutput(outPath, stampVersion(cfg, luts.FormatCube(cfg, cube)), checksums)
+}
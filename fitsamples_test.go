@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFitSamplesFile(t *testing.T) {
+	dir := t.TempDir()
+	var csv strings.Builder
+	csv.WriteString("in_r,in_g,in_b,out_r,out_g,out_b\n")
+	for i := 0; i < 8; i++ {
+		r, g, b := i>>2&1, i>>1&1, i&1
+		fmt.Fprintf(&csv, "%d,%d,%d,%d,%d,%d\n", r, g, b, r, g, b)
+	}
+	csvPath := filepath.Join(dir, "probe.csv")
+	if err := os.WriteFile(csvPath, []byte(csv.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	out := filepath.Join(dir, "calibrated.cube")
+	if err := fitSamplesFile(csvPath, out, 5, "", false); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "\n# Fitted to the measured samples in probe.csv\n"; !strings.Contains(string(data), want) {
+		t.Errorf("fitted LUT header lacks %q:\n%s", want[1:], strings.Join(strings.SplitAfter(string(data), "\n")[:3], ""))
+	}
+	cube, err := readCubeFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cube.Size != 5 {
+		t.Errorf("fitted size %d, want 5", cube.Size)
+	}
+
+	for _, name := range []string{"calibrated.png", "calibrated.dctl"} {
+		err := fitSamplesFile(csvPath, filepath.Join(dir, name), 5, "", false)
+		if err == nil || !strings.Contains(err.Error(), "cannot be written from LUT data") {
+			t.Errorf("fitting to %s: error %v", name, err)
+		}
+		if exists(filepath.Join(dir, name)) {
+			t.Errorf("%s was written", name)
+		}
+	}
+}
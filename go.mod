@@ -1,3 +1,8 @@
 module github.com/flaticols/loglutgen
 
 go 1.24.1
+
+require (
+	golang.org/x/image v0.36.0
+	gopkg.in/yaml.v3 v3.0.1
+)
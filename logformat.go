@@ -0,0 +1,70 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jsonLog writes the log as JSON lines for -logFormat json: one result object
+// per processed config, and every other log message wrapped in an object with
+// its text, so each line of stderr can be parsed on its own. It is safe for
+// concurrent use.
+type jsonLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// configResult is the JSON line logged for each processed config.
+type configResult struct {
+	Time       string   `json:"time"`
+	Source     string   `json:"source"`            // Config file, or CSV file and row
+	Outputs    []string `json:"outputs,omitempty"` // Files written, or that -dryRun would write
+	Status     string   `json:"status"`            // "ok", "skipped", or "error"
+	Error      string   `json:"error,omitempty"`   // Why the config failed or was skipped
+	DurationMS float64  `json:"duration_ms"`
+}
+
+// logMessage is the JSON line logged for any other log message.
+type logMessage struct {
+	Time    string `json:"time"`
+	Message string `json:"message"`
+}
+
+// Write logs one message from the log package, which makes a single Write
+// call per message. It is used with log.SetFlags(0) so p holds only the text.
+func (l *jsonLog) Write(p []byte) (int, error) {
+	if err := l.emit(logMessage{Time: logTime(), Message: strings.TrimRight(string(p), "\n")}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// result logs the outcome of processing the config at source.
+func (l *jsonLog) result(source string, outputs []string, status string, err error, elapsed time.Duration) {
+	r := configResult{Time: logTime(), Source: source, Outputs: outputs, Status: status,
+		DurationMS: float64(elapsed.Microseconds()) / 1000}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	l.emit(r)
+}
+
+// emit writes v as one JSON line.
+func (l *jsonLog) emit(v any) error {
+	line, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, err = l.w.Write(append(line, '\n'))
+	return err
+}
+
+// logTime returns the current time for a JSON log line.
+func logTime() string {
+	return time.Now().Format(time.RFC3339Nano)
+}
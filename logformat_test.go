@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJSONLogWritesOneObjectPerLine(t *testing.T) {
+	var buf bytes.Buffer
+	jlog := &jsonLog{w: &buf}
+	logger := log.New(jlog, "", 0)
+
+	logger.Printf("Warning: %s: %q\n", "look.json", "quoted\nand split")
+	dir := t.TempDir()
+	var written []string
+	err := processConfig("look.json", []byte(`{"size": 2, "output": "look.cube"}`), runOptions{outputDir: dir, written: &written})
+	if err != nil {
+		t.Fatal(err)
+	}
+	jlog.result("look.json", written, "ok", nil, 1500*time.Microsecond)
+	jlog.result("bad.json", nil, "error", errors.New("invalid config: size must be at least 2, got 1"), 0)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			logger.Printf("Processing config: shot%d.json", i)
+		}()
+	}
+	wg.Wait()
+
+	var lines []map[string]any
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var v map[string]any
+		if err := json.Unmarshal(scanner.Bytes(), &v); err != nil {
+			t.Fatalf("line %d is not a JSON object: %v\n%s", len(lines)+1, err, scanner.Text())
+		}
+		if _, err := time.Parse(time.RFC3339Nano, fmt.Sprint(v["time"])); err != nil {
+			t.Errorf("line %d: time %v: %v", len(lines)+1, v["time"], err)
+		}
+		lines = append(lines, v)
+	}
+	if len(lines) != 11 {
+		t.Fatalf("got %d lines, want 11", len(lines))
+	}
+	if got := lines[0]["message"]; got != `Warning: look.json: "quoted\nand split"` {
+		t.Errorf("message line = %q", got)
+	}
+	ok, failed := lines[1], lines[2]
+	outputs, _ := ok["outputs"].([]any)
+	if ok["source"] != "look.json" || ok["status"] != "ok" || ok["duration_ms"] != 1.5 ||
+		len(outputs) != 1 || outputs[0] != filepath.Join(dir, "look.cube") {
+		t.Errorf("ok result = %v", ok)
+	}
+	if _, has := ok["error"]; has {
+		t.Errorf("ok result has an error field: %v", ok)
+	}
+	if failed["status"] != "error" || failed["error"] != "invalid config: size must be at least 2, got 1" {
+		t.Errorf("error result = %v", failed)
+	}
+	if _, has := failed["outputs"]; has {
+		t.Errorf("error result lists outputs: %v", failed)
+	}
+}
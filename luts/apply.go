@@ -0,0 +1,88 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// Clipping marker colors painted by ApplyImageMarked.
+var (
+	clipHighMarker = color.NRGBA64{R: 0xffff, B: 0xffff, A: 0xffff} // Magenta: a channel reached 1.0
+	clipLowMarker  = color.NRGBA64{G: 0xffff, A: 0xffff}            // Green: a channel reached 0.0
+)
+
+// ApplyImage applies cube to every pixel of img using trilinear
+// interpolation and returns the result as a 16-bit non-premultiplied image.
+// Pixel values are treated as the LUT's encoded input; alpha is preserved.
+// Rows are processed concurrently.
+func ApplyImage(img image.Image, cube *Cube) image.Image {
+	return applyImage(img, cube, false)
+}
+
+// ApplyImageMarked is ApplyImage with clipped pixels flagged for QA: pixels
+// where the lookup leaves any channel at or above 1.0 are painted magenta,
+// and otherwise pixels with any channel at or below 0.0 are painted green.
+// Alpha is preserved.
+func ApplyImageMarked(img image.Image, cube *Cube) image.Image {
+	return applyImage(img, cube, true)
+}
+
+// applyImage implements ApplyImage, and ApplyImageMarked when mark is set.
+func applyImage(img image.Image, cube *Cube, mark bool) image.Image {
+	bounds := img.Bounds()
+	out := image.NewNRGBA64(bounds)
+
+	parallelSlices(bounds.Dy(), func(i int) {
+		y := bounds.Min.Y + i
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			px := nrgba64At(img, x, y)
+			v := cube.sample(float64(px.R)/0xffff, float64(px.G)/0xffff, float64(px.B)/0xffff)
+			if mark {
+				if marker, ok := clipMarker(v); ok {
+					marker.A = px.A
+					out.SetNRGBA64(x, y, marker)
+					continue
+				}
+			}
+			out.SetNRGBA64(x, y, color.NRGBA64{
+				R: to16Bit(v[0]),
+				G: to16Bit(v[1]),
+				B: to16Bit(v[2]),
+				A: px.A,
+			})
+		}
+	})
+	return out
+}
+
+// clipMarker returns the marker color for a looked-up value with a channel
+// clipped high or low, and false if no channel is clipped.
+func clipMarker(v [3]float64) (color.NRGBA64, bool) {
+	if v[0] >= 1 || v[1] >= 1 || v[2] >= 1 {
+		return clipHighMarker, true
+	}
+	if v[0] <= 0 || v[1] <= 0 || v[2] <= 0 {
+		return clipLowMarker, true
+	}
+	return color.NRGBA64{}, false
+}
+
+// nrgba64At returns the non-premultiplied color of img at (x, y), so color is
+// independent of alpha. NRGBA and NRGBA64 images are read directly to avoid
+// the rounding of a premultiplied round trip.
+func nrgba64At(img image.Image, x, y int) color.NRGBA64 {
+	switch im := img.(type) {
+	case *image.NRGBA:
+		c := im.NRGBAAt(x, y)
+		return color.NRGBA64{R: uint16(c.R) * 0x101, G: uint16(c.G) * 0x101, B: uint16(c.B) * 0x101, A: uint16(c.A) * 0x101}
+	case *image.NRGBA64:
+		return im.NRGBA64At(x, y)
+	}
+	return color.NRGBA64Model.Convert(img.At(x, y)).(color.NRGBA64)
+}
+
+// to16Bit converts a [0,1] channel value to a 16-bit code value.
+func to16Bit(v float64) uint16 {
+	return uint16(math.Round(math.Min(math.Max(v, 0), 1) * 0xffff))
+}
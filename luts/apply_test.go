@@ -0,0 +1,74 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+// testImage returns a 16-bit image with varied colors and alpha.
+func testImage() *image.NRGBA64 {
+	img := image.NewNRGBA64(image.Rect(0, 0, 16, 8))
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			img.SetNRGBA64(x, y, color.NRGBA64{
+				R: uint16(x * 4369), G: uint16(y * 9362), B: uint16((x*y*997 + 31) % 0x10000), A: uint16(0xffff - x*1000),
+			})
+		}
+	}
+	return img
+}
+
+// affineCube returns a cube whose nodes hold f of their input coordinates.
+func affineCube(size int, f func(v [3]float64) [3]float64) *Cube {
+	cube := &Cube{Size: size, Data: make([][3]float64, size*size*size)}
+	step := 1 / float64(size-1)
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				cube.Data[cube.index(i, j, k)] = f([3]float64{float64(i) * step, float64(j) * step, float64(k) * step})
+			}
+		}
+	}
+	return cube
+}
+
+func TestApplyImageIdentity(t *testing.T) {
+	cube, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Identity, c.Size = true, 17 }))
+	in := testImage()
+	out := ApplyImage(in, cube).(*image.NRGBA64)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			if got, want := out.NRGBA64At(x, y), in.NRGBA64At(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+
+	small := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	small.SetNRGBA(0, 0, color.NRGBA{R: 10, G: 128, B: 250, A: 128})
+	small.SetNRGBA(1, 0, color.NRGBA{R: 255, A: 255})
+	out = ApplyImage(small, cube).(*image.NRGBA64)
+	for x := 0; x < 2; x++ {
+		if got, want := color.NRGBAModel.Convert(out.At(x, 0)), small.NRGBAAt(x, 0); got != want {
+			t.Errorf("8-bit pixel %d = %v, want %v", x, got, want)
+		}
+	}
+}
+
+func TestApplyImageAffineLUT(t *testing.T) {
+	// Trilinear interpolation reproduces an affine map exactly, so every
+	// pixel is inverted and its channels rotated.
+	cube := affineCube(5, func(v [3]float64) [3]float64 { return [3]float64{1 - v[1], 1 - v[2], 1 - v[0]} })
+	in := testImage()
+	out := ApplyImage(in, cube).(*image.NRGBA64)
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 16; x++ {
+			p := in.NRGBA64At(x, y)
+			want := color.NRGBA64{R: 0xffff - p.G, G: 0xffff - p.B, B: 0xffff - p.R, A: p.A}
+			if got := out.NRGBA64At(x, y); got != want {
+				t.Fatalf("pixel (%d, %d) = %v, want %v", x, y, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package luts
+
+import "fmt"
+
+// bandingSlopeLimit is the neutral-transfer slope above which a single grid
+// cell spans enough output range for interpolation banding to become visible.
+const bandingSlopeLimit = 2.5
+
+// CheckBanding walks the neutral (gray) transfer one grid cell at a time and
+// reports the input regions whose slope exceeds bandingSlopeLimit. Apple Log
+// is steepest in the shadows, so that is where the warnings usually land.
+func CheckBanding(cfg Config) []string {
+	neutral := func(x float64) float64 {
+		r, g, b := processPixel(cfg, x, x, x)
+		return (r + g + b) / 3
+	}
+
+	var warnings []string
+	step := 1 / float64(cfg.Size-1)
+	start, peak := -1.0, 0.0
+	flush := func(end float64) {
+		if start < 0 {
+			return
+		}
+		warnings = append(warnings, fmt.Sprintf(
+			"banding likely for input %.3f-%.3f (slope up to %.2f at size %d); consider a larger size or a 1D shaper LUT",
+			start, end, peak, cfg.Size))
+		start, peak = -1, 0
+	}
+	for i := 0; i < cfg.Size-1; i++ {
+		x0 := float64(i) * step
+		x1 := float64(i+1) * step
+		slope := (neutral(x1) - neutral(x0)) / step
+		if slope > bandingSlopeLimit {
+			if start < 0 {
+				start = x0
+			}
+			peak = max(peak, slope)
+			continue
+		}
+		flush(x0)
+	}
+	flush(1)
+	return warnings
+}
@@ -0,0 +1,20 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckBandingFlagsSteepShadows(t *testing.T) {
+	if w := CheckBanding(defaultConfig(t, func(c *Config) { c.Size = 17 })); len(w) != 0 {
+		t.Errorf("default config warned: %v", w)
+	}
+	steep := defaultConfig(t, func(c *Config) { c.Size, c.ShadowLift = 17, 0.2 })
+	w := CheckBanding(steep)
+	if len(w) != 1 || !strings.HasPrefix(w[0], "banding likely for input 0.000-") {
+		t.Fatalf("steep shadows at size 17: warnings %v, want one starting at input 0", w)
+	}
+	if !strings.Contains(w[0], "at size 17") {
+		t.Errorf("warning %q does not name the size", w[0])
+	}
+}
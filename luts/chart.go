@@ -0,0 +1,70 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// chartInput returns the encoded input value of the synthetic test chart at
+// normalized position (u, v). The top three quarters sweep hue horizontally
+// and brightness vertically; the bottom quarter is a horizontal gray ramp.
+func chartInput(u, v float64) (float64, float64, float64) {
+	if v >= 0.75 {
+		return u, u, u
+	}
+	value := 1 - v/0.75
+	return hsvToRGB(u*360, 0.7, value)
+}
+
+// hsvToRGB converts a hue in degrees and saturation/value in [0,1] to RGB.
+func hsvToRGB(h, s, v float64) (float64, float64, float64) {
+	c := v * s
+	hp := math.Mod(h, 360) / 60
+	x := c * (1 - math.Abs(math.Mod(hp, 2)-1))
+	var r, g, b float64
+	switch {
+	case hp < 1:
+		r, g, b = c, x, 0
+	case hp < 2:
+		r, g, b = x, c, 0
+	case hp < 3:
+		r, g, b = 0, c, x
+	case hp < 4:
+		r, g, b = 0, x, c
+	case hp < 5:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	m := v - c
+	return r + m, g + m, b + m
+}
+
+// to8Bit converts a [0,1] channel value to an 8-bit code value.
+func to8Bit(v float64) uint8 {
+	return uint8(math.Round(math.Min(math.Max(v, 0), 1) * 255))
+}
+
+// renderChart renders the synthetic test chart at size x size pixels, with
+// each pixel run through the config's pipeline.
+func renderChart(cfg Config, size int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			u := float64(x) / float64(max(size-1, 1))
+			v := float64(y) / float64(max(size-1, 1))
+			inR, inG, inB := chartInput(u, v)
+			r, g, b := processPixel(cfg, inR, inG, inB)
+			img.SetNRGBA(x, y, color.NRGBA{R: to8Bit(r), G: to8Bit(g), B: to8Bit(b), A: 255})
+		}
+	}
+	return img
+}
+
+// RenderPreview renders the synthetic test chart through cfg's pipeline at
+// size x size pixels, as a thumbnail of what the LUT does. cfg must have
+// passed SetDefaults and Validate.
+func RenderPreview(cfg Config, size int) *image.NRGBA {
+	return renderChart(cfg, size)
+}
@@ -0,0 +1,100 @@
+package luts
+
+import "strings"
+
+// ClipReport counts the grid nodes whose colors the conversion pushes out of
+// range, as measured by CountClipping.
+type ClipReport struct {
+	Nodes    int    // Grid nodes checked
+	Clipped  int    // Nodes with at least one channel clipped by the gamut conversion or the look
+	Gamut    int    // Nodes with a channel outside [0,1] after the gamut conversion
+	Look     int    // Nodes with a channel the creative look clamped to 0 or 1
+	Channels [3]int // Clipped nodes per channel: red, green, blue
+}
+
+// add accumulates o into r.
+func (r *ClipReport) add(o ClipReport) {
+	r.Nodes += o.Nodes
+	r.Clipped += o.Clipped
+	r.Gamut += o.Gamut
+	r.Look += o.Look
+	for c := range r.Channels {
+		r.Channels[c] += o.Channels[c]
+	}
+}
+
+// CountClipping runs every node of cfg's grid through the pipeline and counts
+// the nodes where a channel leaves [0,1] (or, for unbounded targets such as
+// ACEScct, goes negative) in the gamut conversion, where it is clipped (or
+// brought into range by GamutMapping "compress" or "preserveHue"), or is
+// clamped to 0 or 1 by the creative look, zone looks, or look expression.
+// Inverse and identity LUTs have neither stage and report no clipping.
+func CountClipping(cfg Config) ClipReport {
+	size := cfg.Size
+	if cfg.Invert || cfg.Identity {
+		return ClipReport{Nodes: size * size * size}
+	}
+	target, err := cfg.displayTarget()
+	if err != nil {
+		target = displayTargets["rec709"]
+	}
+	m, convert := gamutMatrix(cfg, target)
+	checkGamut := convert || strings.EqualFold(cfg.GamutMapping, "compress") // Matching primaries are passed through
+	hasLook := !strings.EqualFold(cfg.Look, "none") || cfg.ZoneLooks.enabled() || cfg.LookExpr != ""
+	plain := cfg
+	plain.Look, plain.ZoneLooks, plain.LookExpr, plain.lookProgram = "none", ZoneLooks{}, "", nil
+
+	decoded := make([]float64, size)
+	for n := range decoded {
+		decoded[n] = decodeInput(cfg, cfg.DomainMin+float64(n)/float64(size-1)*(cfg.DomainMax-cfg.DomainMin))
+	}
+	slices := make([]ClipReport, size)
+	parallelSlices(size, func(i int) {
+		rep := &slices[i]
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				var clipped [3]bool
+				gamut, look := false, false
+				if checkGamut {
+					linR, linG, linB := gradeLinear(cfg, decoded[i], decoded[j], decoded[k])
+					linR, linG, linB = multiplyMatrix(m, linR, linG, linB)
+					for c, v := range [3]float64{linR, linG, linB} {
+						if v < 0 || (v > 1 && !target.Unbounded) {
+							clipped[c], gamut = true, true
+						}
+					}
+				}
+				if hasLook {
+					pr, pg, pb := processDecoded(plain, decoded[i], decoded[j], decoded[k])
+					r, g, b := processDecoded(cfg, decoded[i], decoded[j], decoded[k])
+					pre, post := [3]float64{pr, pg, pb}, [3]float64{r, g, b}
+					for c := range post {
+						if (post[c] <= 0 && pre[c] > 0) || (post[c] >= 1 && pre[c] < 1) {
+							clipped[c], look = true, true
+						}
+					}
+				}
+				rep.Nodes++
+				for c, ok := range clipped {
+					if ok {
+						rep.Channels[c]++
+					}
+				}
+				if gamut || look {
+					rep.Clipped++
+				}
+				if gamut {
+					rep.Gamut++
+				}
+				if look {
+					rep.Look++
+				}
+			}
+		}
+	})
+	var report ClipReport
+	for _, rep := range slices {
+		report.add(rep)
+	}
+	return report
+}
@@ -0,0 +1,25 @@
+package luts
+
+import "testing"
+
+func TestCountClipping(t *testing.T) {
+	normal := CountClipping(defaultConfig(t, func(c *Config) { c.Size = 9 }))
+	hot := CountClipping(defaultConfig(t, func(c *Config) { c.Size, c.ExposureStops = 9, 3 }))
+	if hot.Nodes != 729 || hot.Clipped <= normal.Clipped {
+		t.Errorf("+3 stops clips %d of %d nodes, want more than the %d clipped without", hot.Clipped, hot.Nodes, normal.Clipped)
+	}
+	for c, n := range hot.Channels {
+		if n <= normal.Channels[c] || n > hot.Clipped {
+			t.Errorf("+3 stops clips channel %d on %d nodes, want more than %d and at most %d", c, n, normal.Channels[c], hot.Clipped)
+		}
+	}
+
+	warm := CountClipping(defaultConfig(t, func(c *Config) { c.Size, c.Look, c.Params = 9, "warmVintage", map[string]float64{"warmth": 0.5} }))
+	if warm.Look == 0 || warm.Clipped < warm.Look || warm.Channels[0] == 0 {
+		t.Errorf("a strong warm look reports %+v, want red clipped by the look", warm)
+	}
+
+	if id := CountClipping(defaultConfig(t, func(c *Config) { c.Size, c.Identity = 9, true })); id.Nodes != 729 || id.Clipped != 0 {
+		t.Errorf("identity LUT reports %+v, want nothing clipped", id)
+	}
+}
@@ -0,0 +1,22 @@
+package luts
+
+// ComposeCubes bakes first followed by second into a single cube, so the
+// pair is applied with one interpolation instead of two. Each node of the
+// result is looked up in first and the output looked up in second, both
+// trilinearly. The result spans first's domain at the larger of the two
+// grid sizes, so neither LUT loses resolution when their sizes differ.
+func ComposeCubes(first, second *Cube) *Cube {
+	size := max(first.Size, second.Size)
+	d := first.domain()
+	out := &Cube{Size: size, Data: make([][3]float64, size*size*size), DomainMin: d[0], DomainMax: d[1]}
+	input := func(n int) float64 { return d[0] + float64(n)/float64(size-1)*(d[1]-d[0]) }
+	parallelSlices(size, func(i int) {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				v := first.sample(input(i), input(j), input(k))
+				out.Data[out.index(i, j, k)] = second.sample(v[0], v[1], v[2])
+			}
+		}
+	})
+	return out
+}
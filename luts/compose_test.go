@@ -0,0 +1,27 @@
+package luts
+
+import "testing"
+
+func TestComposeAffineCubes(t *testing.T) {
+	scale := func(v [3]float64) [3]float64 { return [3]float64{0.5 * v[0], 0.25 + 0.5*v[1], 1 - v[2]} }
+	swap := func(v [3]float64) [3]float64 { return [3]float64{v[1], v[2], 0.2 + 0.8*v[0]} }
+	first, second := affineCube(5, scale), affineCube(9, swap)
+	for _, tc := range []struct {
+		name          string
+		first, second *Cube
+		f, g          func([3]float64) [3]float64
+	}{
+		{"smaller first", first, second, scale, swap},
+		{"smaller second", second, first, swap, scale},
+	} {
+		composed := ComposeCubes(tc.first, tc.second)
+		if composed.Size != 9 {
+			t.Errorf("%s: size %d, want the larger size 9", tc.name, composed.Size)
+		}
+		for _, in := range [][3]float64{{0, 0, 0}, {1, 1, 1}, {0.3, 0.6, 0.9}, {0.125, 0.5, 0.875}} {
+			if got, want := composed.sample(in[0], in[1], in[2]), tc.g(tc.f(in)); !nearRGB(got, want, 1e-12) {
+				t.Errorf("%s: %v maps to %v, want %v", tc.name, in, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+)
+
+// Contact sheet layout, in pixels.
+const (
+	sheetPadding    = 8
+	sheetLabelScale = 2
+)
+
+var (
+	sheetBackground = color.NRGBA{R: 32, G: 32, B: 32, A: 255}
+	sheetLabelColor = color.NRGBA{R: 230, G: 230, B: 230, A: 255}
+)
+
+// RenderContactSheet renders the test chart through each look in looks using
+// base for every other setting, and tiles the results with the look names as
+// labels, columns tiles per row.
+func RenderContactSheet(base Config, looks []string, tileSize, columns int) *image.NRGBA {
+	columns = max(min(columns, len(looks)), 1)
+	rows := (len(looks) + columns - 1) / columns
+	labelHeight := glyphHeight*sheetLabelScale + sheetPadding
+	cellW := tileSize + sheetPadding
+	cellH := tileSize + labelHeight + sheetPadding
+
+	sheet := image.NewNRGBA(image.Rect(0, 0, columns*cellW+sheetPadding, rows*cellH+sheetPadding))
+	draw.Draw(sheet, sheet.Bounds(), &image.Uniform{C: sheetBackground}, image.Point{}, draw.Src)
+
+	for i, look := range looks {
+		cfg := base
+		cfg.Look = look
+		x := sheetPadding + (i%columns)*cellW
+		y := sheetPadding + (i/columns)*cellH
+
+		tile := renderChart(cfg, tileSize)
+		draw.Draw(sheet, image.Rect(x, y, x+tileSize, y+tileSize), tile, image.Point{}, draw.Src)
+		drawText(sheet, x, y+tileSize+sheetPadding/2, look, sheetLabelScale, sheetLabelColor)
+	}
+	return sheet
+}
@@ -0,0 +1,47 @@
+package luts
+
+import (
+	"image"
+	"testing"
+)
+
+func TestContactSheetHasOneLabeledTilePerLook(t *testing.T) {
+	const tileSize, columns = 24, 3
+	base := defaultConfig(t, nil)
+	looks := LookNames()
+	sheet := RenderContactSheet(base, looks, tileSize, columns)
+
+	rows := (len(looks) + columns - 1) / columns
+	labelHeight := glyphHeight*sheetLabelScale + sheetPadding
+	cellW, cellH := tileSize+sheetPadding, tileSize+labelHeight+sheetPadding
+	if want := image.Rect(0, 0, columns*cellW+sheetPadding, rows*cellH+sheetPadding); sheet.Bounds() != want {
+		t.Fatalf("sheet bounds %v, want %v for %d looks", sheet.Bounds(), want, len(looks))
+	}
+
+	for i, look := range looks {
+		x := sheetPadding + (i%columns)*cellW
+		y := sheetPadding + (i/columns)*cellH
+		cfg := base
+		cfg.Look = look
+		tile := renderChart(cfg, tileSize)
+		for ty := 0; ty < tileSize; ty++ {
+			for tx := 0; tx < tileSize; tx++ {
+				if got, want := sheet.NRGBAAt(x+tx, y+ty), tile.NRGBAAt(tx, ty); got != want {
+					t.Fatalf("%s tile pixel (%d, %d) = %v, want %v", look, tx, ty, got, want)
+				}
+			}
+		}
+
+		label := 0
+		for ly := y + tileSize; ly < y+tileSize+labelHeight; ly++ {
+			for lx := x; lx < x+cellW; lx++ {
+				if sheet.NRGBAAt(lx, ly) == sheetLabelColor {
+					label++
+				}
+			}
+		}
+		if label == 0 {
+			t.Errorf("%s tile has no label", look)
+		}
+	}
+}
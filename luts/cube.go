@@ -0,0 +1,309 @@
+package luts
+
+import (
+	"math"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Cube is a 3D LUT grid of Size^3 output RGB triplets. Nodes are stored with
+// red as the slowest-varying axis and blue as the fastest, matching the order
+// of the lines written by FormatCube.
+type Cube struct {
+	Size int
+	Data [][3]float64
+
+	// DomainMin and DomainMax are the input values at the first and last
+	// grid nodes. If both are zero the domain is [0,1].
+	DomainMin, DomainMax float64
+
+	// Shaper, if set, is a 1D pre-LUT spanning the domain that maps each
+	// input channel to a grid coordinate in [0,1] before the 3D lookup.
+	Shaper []float64
+
+	// Title is the TITLE of a cube read by ParseCube. FormatCube takes the
+	// title from its Config instead.
+	Title string
+}
+
+// domain returns the cube's input domain.
+func (c *Cube) domain() [2]float64 {
+	if c.DomainMin == 0 && c.DomainMax == 0 {
+		return [2]float64{0, 1}
+	}
+	return [2]float64{c.DomainMin, c.DomainMax}
+}
+
+// index returns the position in Data of the node at grid coordinate (i, j, k)
+// along the red, green, and blue axes.
+func (c *Cube) index(i, j, k int) int {
+	return (i*c.Size+j)*c.Size + k
+}
+
+// BuildCube computes the LUT grid for cfg. Each input grid value
+// (representing an Apple Log encoded value) is run through processPixel and
+// optionally normalized so white maps to white. NaN and infinite channel
+// values are replaced with finite ones and counted in Stats.NonFinite, and
+// with OutputClip "soft" highlights are rolled off below 1.0. The whole grid is then
+// optionally remapped to the target black level, dithered, and quantized to
+// an integer bit depth. With Identity set, each node holds its own input
+// coordinates and none of these stages run.
+func BuildCube(cfg Config) (*Cube, Stats) {
+	size := cfg.Size
+	cube := &Cube{Size: size, Data: make([][3]float64, size*size*size), DomainMin: cfg.DomainMin, DomainMax: cfg.DomainMax}
+	var stats Stats
+
+	white := [3]float64{1, 1, 1}
+	if cfg.NormalizeWhite {
+		white = whiteLevels(cfg)
+	}
+
+	// Grid coordinates map linearly onto the domain, or through the inverse
+	// shaper so each node sits where the shaper sends its input.
+	input := func(n int) float64 {
+		return cfg.DomainMin + float64(n)/float64(size-1)*(cfg.DomainMax-cfg.DomainMin)
+	}
+	if cfg.ShaperSize > 0 && !cfg.ShaperOnly {
+		var inverse func(float64) float64
+		cube.Shaper, inverse = buildShaper(cfg)
+		shaped := make([]float64, size)
+		for n := range shaped {
+			shaped[n] = inverse(float64(n) / float64(size-1))
+		}
+		input = func(n int) float64 { return shaped[n] }
+	}
+
+	// An identity LUT holds each node's own coordinates, untouched by any
+	// later stage.
+	if cfg.Identity {
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				for k := 0; k < size; k++ {
+					cube.Data[cube.index(i, j, k)] = [3]float64{input(i), input(j), input(k)}
+				}
+			}
+		}
+		return cube, stats
+	}
+
+	// Every node draws its channels from the same size input values, so they
+	// are decoded to linear light once up front.
+	decoded := make([]float64, size)
+	for n := range decoded {
+		decoded[n] = decodeInput(cfg, input(n))
+	}
+
+	// Loop over the 3D LUT grid, one red slice per task. Nodes are written to
+	// their own index, so the result does not depend on scheduling.
+	nonFinite := make([]int, size)
+	softClipOutput := strings.EqualFold(cfg.OutputClip, "soft")
+	parallelSlices(size, func(i int) {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				// Input values (simulate Apple Log encoded values) spanning
+				// the configured domain, [0, 1] by default. Inverse LUTs take
+				// display values, which have no decode to cache.
+				var encR, encG, encB float64
+				if cfg.Invert {
+					encR, encG, encB = processPixel(cfg, input(i), input(j), input(k))
+				} else {
+					encR, encG, encB = processDecoded(cfg, decoded[i], decoded[j], decoded[k])
+				}
+
+				// Scale so input white lands exactly on output white. Channels
+				// pushed past 1.0 are clipped there, unless a soft output_clip
+				// rolls them off below.
+				if cfg.NormalizeWhite {
+					encR, encG, encB = encR/white[0], encG/white[1], encB/white[2]
+					if !softClipOutput {
+						encR, encG, encB = min(encR, 1), min(encG, 1), min(encB, 1)
+					}
+				}
+
+				node := [3]float64{encR, encG, encB}
+				for ch, v := range node {
+					if f, ok := finiteValue(v); !ok {
+						node[ch] = f
+						nonFinite[i]++
+					}
+					if softClipOutput {
+						node[ch] = softClip(node[ch])
+					}
+				}
+				cube.Data[cube.index(i, j, k)] = node
+			}
+		}
+	})
+	for _, n := range nonFinite {
+		stats.NonFinite += n
+	}
+
+	if cfg.OutputBlack > 0 {
+		remapBlack(cube, cfg.OutputBlack)
+	}
+
+	if cfg.Dither {
+		ditherCube(cube, cfg.DitherAmount)
+	}
+
+	// Quantize to the target bit depth if requested.
+	if cfg.QuantizeBits > 0 {
+		var quantErrSum float64
+		for n := range cube.Data {
+			for ch, v := range cube.Data[n] {
+				q := quantize(v, cfg.QuantizeBits)
+				e := math.Abs(q - v)
+				stats.QuantMaxError = max(stats.QuantMaxError, e)
+				quantErrSum += e
+				cube.Data[n][ch] = q
+			}
+		}
+		stats.QuantMeanError = quantErrSum / float64(3*len(cube.Data))
+	}
+	return cube, stats
+}
+
+// finiteValue replaces NaN with 0 and infinities with the nearest end of
+// [0,1], reporting whether v was already finite.
+func finiteValue(v float64) (float64, bool) {
+	switch {
+	case math.IsNaN(v), math.IsInf(v, -1):
+		return 0, false
+	case math.IsInf(v, 1):
+		return 1, false
+	}
+	return v, true
+}
+
+// ditherSeed fixes the dither pattern so output is reproducible.
+const ditherSeed = 0x5eed_1a7e
+
+// ditherCube adds triangular-PDF noise of peak amplitude to every channel of
+// every node, keeping results within [0, max(v, 1)]. The noise is a hash of
+// the node and channel, so it is the same on every run.
+func ditherCube(cube *Cube, amplitude float64) {
+	for n := range cube.Data {
+		for ch, v := range cube.Data[n] {
+			i := uint64(3*n + ch)
+			// Sum of two uniform values in [0,1) minus 1 has a triangular
+			// distribution on (-1, 1).
+			tpdf := uniformHash(ditherSeed, 2*i) + uniformHash(ditherSeed, 2*i+1) - 1
+			cube.Data[n][ch] = min(max(v+tpdf*amplitude, 0), max(v, 1))
+		}
+	}
+}
+
+// uniformHash maps (seed, i) to a value in [0,1) with the SplitMix64 mixer.
+func uniformHash(seed, i uint64) float64 {
+	z := seed + (i+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z ^= z >> 31
+	return float64(z>>11) / (1 << 53)
+}
+
+// workers is the number of goroutines parallelSlices runs. Tests and
+// benchmarks set it to 1 to compare against a serial build.
+var workers = runtime.NumCPU()
+
+// parallelSlices calls fn(i) for every i in [0, n) on a pool of workers
+// goroutines and returns when all calls have finished. A panic in fn is
+// re-raised on the calling goroutine once the pool has drained, so callers
+// can recover from it as they would from a serial loop.
+func parallelSlices(n int, fn func(i int)) {
+	slices := make(chan int)
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		panicked any
+	)
+	call := func(i int) {
+		defer func() {
+			if r := recover(); r != nil {
+				mu.Lock()
+				if panicked == nil {
+					panicked = r
+				}
+				mu.Unlock()
+			}
+		}()
+		fn(i)
+	}
+	for w := 0; w < min(workers, n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range slices {
+				call(i)
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		slices <- i
+	}
+	close(slices)
+	wg.Wait()
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// remapBlack linearly remaps every node so the darkest output value in the
+// cube lands on black while 1.0 stays at 1.0, preserving relative tones.
+func remapBlack(cube *Cube, black float64) {
+	lo := math.Inf(1)
+	for _, v := range cube.Data {
+		lo = min(lo, v[0], v[1], v[2])
+	}
+	if lo >= 1 {
+		return
+	}
+	scale := (1 - black) / (1 - lo)
+	for n := range cube.Data {
+		for ch, v := range cube.Data[n] {
+			cube.Data[n][ch] = black + (v-lo)*scale
+		}
+	}
+}
+
+// sample looks up an input RGB value with trilinear interpolation between the
+// surrounding grid nodes. Inputs outside the cube's domain are clamped.
+func (c *Cube) sample(r, g, b float64) [3]float64 {
+	d := c.domain()
+	coord := func(v float64) float64 {
+		if c.Shaper != nil {
+			return shaperLookup(c.Shaper, d[0], d[1], v)
+		}
+		return (v - d[0]) / (d[1] - d[0])
+	}
+	return trilinearSample(c.Data, c.Size, coord(r), coord(g), coord(b))
+}
+
+// trilinearSample interpolates the size^3 grid, stored in Cube.Data order, at
+// normalized coordinates r, g, and b, where 0 and 1 are the first and last
+// nodes along each axis. Coordinates outside [0,1] are clamped to the grid
+// boundary, and coordinates on a node return that node's value exactly.
+func trilinearSample(grid [][3]float64, size int, r, g, b float64) [3]float64 {
+	n := size - 1
+	pos := func(v float64) (int, float64) {
+		v = math.Min(math.Max(v, 0), 1) * float64(n)
+		i := min(int(v), n-1)
+		return i, v - float64(i)
+	}
+	i, fr := pos(r)
+	j, fg := pos(g)
+	k, fb := pos(b)
+	index := func(i, j, k int) int { return (i*size+j)*size + k }
+
+	var out [3]float64
+	for ch := 0; ch < 3; ch++ {
+		lerp := func(a, b, t float64) float64 { return a*(1-t) + b*t } // Exact at t = 0 and t = 1
+		c00 := lerp(grid[index(i, j, k)][ch], grid[index(i+1, j, k)][ch], fr)
+		c01 := lerp(grid[index(i, j, k+1)][ch], grid[index(i+1, j, k+1)][ch], fr)
+		c10 := lerp(grid[index(i, j+1, k)][ch], grid[index(i+1, j+1, k)][ch], fr)
+		c11 := lerp(grid[index(i, j+1, k+1)][ch], grid[index(i+1, j+1, k+1)][ch], fr)
+		out[ch] = lerp(lerp(c00, c10, fg), lerp(c01, c11, fg), fb)
+	}
+	return out
+}
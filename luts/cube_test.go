@@ -0,0 +1,473 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// serially runs fn with parallelSlices limited to one worker.
+func serially(fn func()) {
+	saved := workers
+	workers = 1
+	defer func() { workers = saved }()
+	fn()
+}
+
+func TestParallelMatchesSerial(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Size, c.Look = 17, "tealOrange" })
+	parallel, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var serial string
+	serially(func() { serial, err = Generate(cfg) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parallel != serial {
+		t.Error("parallel and serial builds differ")
+	}
+}
+
+func TestWorkerPanicReachesCaller(t *testing.T) {
+	registerLook("panicky", nil, func(_ Config, r, g, b float64) (float64, float64, float64) {
+		if r > 0.5 {
+			panic("look failed")
+		}
+		return r, g, b
+	}, nil)
+	defer func() {
+		delete(lookRegistry, "panicky")
+		lookOrder = lookOrder[:len(lookOrder)-1]
+	}()
+	saved := workers
+	workers = 4
+	defer func() { workers = saved }()
+
+	cfg := defaultConfig(t, func(c *Config) { c.Size, c.Look = 9, "panicky" })
+	defer func() {
+		if r := recover(); r != "look failed" {
+			t.Errorf("recovered %v, want the worker's panic", r)
+		}
+	}()
+	BuildCube(cfg)
+	t.Error("BuildCube returned normally")
+}
+
+func TestTracePixelFinalMatchesNodes(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		edit func(c *Config)
+	}{
+		{"plain", func(c *Config) { c.Look = "tealOrange" }},
+		{"output_black", func(c *Config) { c.OutputBlack = 0.1 }},
+		{"normalize_white", func(c *Config) { c.NormalizeWhite, c.ExposureStops = true, -0.5 }},
+		{"soft output_clip", func(c *Config) { c.OutputClip, c.ExposureStops = "soft", 1 }},
+		{"dither and quantize", func(c *Config) { c.Dither, c.QuantizeBits = true, 8 }},
+		{"invert", func(c *Config) { c.Invert, c.OutputBlack = true, 0.05 }},
+	} {
+		cfg := defaultConfig(t, func(c *Config) {
+			c.Size = 5
+			tc.edit(c)
+		})
+		cube, _ := BuildCube(cfg)
+		input := func(n int) float64 { return float64(n) / float64(cfg.Size-1) }
+		for i := 0; i < cfg.Size; i++ {
+			for j := 0; j < cfg.Size; j++ {
+				for k := 0; k < cfg.Size; k++ {
+					got := TracePixel(cfg, input(i), input(j), input(k)).Final
+					want := cube.Data[cube.index(i, j, k)]
+					for ch := range want {
+						if !near(got[ch], want[ch], 1e-12) {
+							t.Fatalf("%s: node (%d, %d, %d): Final %v, cube holds %v", tc.name, i, j, k, got, want)
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkBuildCube(b *testing.B) {
+	for _, size := range []int{17, 33, 65} {
+		cfg := defaultConfig(b, func(c *Config) { c.Size, c.Look = size, "tealOrange" })
+		b.Run(fmt.Sprintf("size=%d/parallel", size), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				BuildCube(cfg)
+			}
+		})
+		b.Run(fmt.Sprintf("size=%d/serial", size), func(b *testing.B) {
+			serially(func() {
+				for i := 0; i < b.N; i++ {
+					BuildCube(cfg)
+				}
+			})
+		})
+	}
+}
+
+func TestTrilinearSample(t *testing.T) {
+	// Trilinear interpolation reproduces any function that is linear in
+	// each axis, so r, g*b and r*g*b are exact everywhere, not just at nodes.
+	f := func(r, g, b float64) [3]float64 { return [3]float64{r, g * b, r * g * b} }
+	for _, size := range []int{2, 3, 5} {
+		grid := make([][3]float64, size*size*size)
+		n := float64(size - 1)
+		for i := 0; i < size; i++ {
+			for j := 0; j < size; j++ {
+				for k := 0; k < size; k++ {
+					grid[(i*size+j)*size+k] = f(float64(i)/n, float64(j)/n, float64(k)/n)
+				}
+			}
+		}
+		for _, tc := range []struct {
+			name    string
+			r, g, b float64
+			want    [3]float64
+		}{
+			{"black corner", 0, 0, 0, f(0, 0, 0)},
+			{"white corner", 1, 1, 1, f(1, 1, 1)},
+			{"red corner", 1, 0, 0, f(1, 0, 0)},
+			{"edge midpoint", 0.5, 1, 0, f(0.5, 1, 0)},
+			{"face center", 1, 0.5, 0.5, f(1, 0.5, 0.5)},
+			{"center", 0.5, 0.5, 0.5, f(0.5, 0.5, 0.5)},
+			{"off-node", 0.3, 0.7, 0.9, f(0.3, 0.7, 0.9)},
+			{"below range", -0.5, 0.25, -2, f(0, 0.25, 0)},
+			{"above range", 1.5, 0.25, 3, f(1, 0.25, 1)},
+		} {
+			got := trilinearSample(grid, size, tc.r, tc.g, tc.b)
+			for ch := range got {
+				if math.Abs(got[ch]-tc.want[ch]) > 1e-12 {
+					t.Errorf("size %d, %s (%g, %g, %g) = %v, want %v", size, tc.name, tc.r, tc.g, tc.b, got, tc.want)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestQuantizationErrorShrinksWithBitDepth(t *testing.T) {
+	stats := func(bits int) Stats {
+		cfg := defaultConfig(t, func(c *Config) { c.Size, c.Look, c.QuantizeBits = 17, "tealOrange", bits })
+		_, s := BuildCube(cfg)
+		return s
+	}
+	if s := stats(0); s.QuantMaxError != 0 || s.QuantMeanError != 0 {
+		t.Errorf("float output: error max %g mean %g, want 0", s.QuantMaxError, s.QuantMeanError)
+	}
+	prev := stats(8)
+	for _, bits := range []int{10, 12, 16} {
+		s := stats(bits)
+		if s.QuantMaxError >= prev.QuantMaxError || s.QuantMeanError >= prev.QuantMeanError {
+			t.Errorf("%d bits: error max %g mean %g, not below the previous depth's %g and %g",
+				bits, s.QuantMaxError, s.QuantMeanError, prev.QuantMaxError, prev.QuantMeanError)
+		}
+		if step := 1 / float64(int(1)<<bits-1); s.QuantMaxError > step/2+1e-12 {
+			t.Errorf("%d bits: max error %g exceeds half a code value %g", bits, s.QuantMaxError, step/2)
+		}
+		prev = s
+	}
+}
+
+func TestNormalizeWhiteMapsWhiteToWhite(t *testing.T) {
+	white := func(normalize bool) [3]float64 {
+		cfg := defaultConfig(t, func(c *Config) {
+			c.Size, c.Look, c.NormalizeWhite, c.ExposureOffset = 9, "warmVintage", normalize, 0.8
+		})
+		cube, _ := BuildCube(cfg)
+		return cube.Data[len(cube.Data)-1]
+	}
+	if got := white(false); got == [3]float64{1, 1, 1} {
+		t.Fatalf("white already maps to %v without normalize_white", got)
+	}
+	if got := white(true); got != [3]float64{1, 1, 1} {
+		t.Errorf("normalize_white: white maps to %v, want exactly 1 1 1", got)
+	}
+
+	// Channels scaled past white are rolled off by a soft output_clip
+	// instead of being clipped flat at 1.0 first.
+	soft, _ := BuildCube(defaultConfig(t, func(c *Config) {
+		c.Size, c.Look, c.NormalizeWhite, c.ExposureOffset, c.OutputClip = 9, "warmVintage", true, 0.8, "soft"
+	}))
+	over := 0
+	for n, node := range soft.Data {
+		for ch, v := range node {
+			if v >= 1 {
+				t.Fatalf("soft node %d channel %d = %g, want below 1.0", n, ch, v)
+			}
+			if v > softClip(1) {
+				over++
+			}
+		}
+	}
+	if over == 0 {
+		t.Error("soft output_clip rolls off no channel scaled past white; normalize_white clipped them first")
+	}
+}
+
+func TestOutputBlackAnchorsMinimum(t *testing.T) {
+	base, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.Look = 9, "tealOrange" }))
+	cube, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.Look, c.OutputBlack = 9, "tealOrange", 0.0625 }))
+	lo, baseLo := math.Inf(1), math.Inf(1)
+	for n := range cube.Data {
+		lo = min(lo, cube.Data[n][0], cube.Data[n][1], cube.Data[n][2])
+		baseLo = min(baseLo, base.Data[n][0], base.Data[n][1], base.Data[n][2])
+	}
+	if !near(lo, 0.0625, 1e-12) {
+		t.Errorf("minimum output %g, want 0.0625", lo)
+	}
+	// Above black the remap is affine, so relative tones are preserved.
+	scale := (1 - 0.0625) / (1 - baseLo)
+	for n := range cube.Data {
+		for c, v := range cube.Data[n] {
+			if want := 0.0625 + (base.Data[n][c]-baseLo)*scale; !near(v, want, 1e-12) {
+				t.Fatalf("node %d channel %d = %g, want %g", n, c, v, want)
+			}
+		}
+	}
+
+	for _, black := range []float64{-0.1, 1} {
+		cfg := Config{OutputBlack: black}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Validate accepted output_black %g", black)
+		}
+	}
+}
+
+func TestDomainMaxGridEndpoints(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Size, c.DomainMax, c.Look = 9, 1.2, "warmVintage" })
+	cube, _ := BuildCube(cfg)
+	for _, tc := range []struct {
+		node int
+		in   float64
+	}{{0, 0}, {4, 0.6}, {8, 1.2}} {
+		r, g, b := processPixel(cfg, tc.in, tc.in, tc.in)
+		if got := cube.Data[cube.index(tc.node, tc.node, tc.node)]; !nearRGB(got, [3]float64{r, g, b}, 1e-12) {
+			t.Errorf("node %d = %v, want the output %v for input %g", tc.node, got, [3]float64{r, g, b}, tc.in)
+		}
+	}
+	if decodeInput(cfg, 1.2) <= decodeInput(cfg, 1) {
+		t.Error("input 1.2 decodes no brighter than 1.0; super-whites are clipped")
+	}
+
+	data, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"DOMAIN_MIN 0.0 0.0 0.0\n", "DOMAIN_MAX 1.2 1.2 1.2\n", "LUT_3D_INPUT_RANGE 0.0 1.2\n"} {
+		if !strings.Contains(data, line) {
+			t.Errorf("header is missing %q", line)
+		}
+	}
+	parsed, err := ParseCube(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.DomainMin != 0 || parsed.DomainMax != 1.2 {
+		t.Errorf("parsed domain [%g, %g], want [0, 1.2]", parsed.DomainMin, parsed.DomainMax)
+	}
+}
+
+func TestPathologicalConfigsWriteFiniteValues(t *testing.T) {
+	value := regexp.MustCompile(`^-?[0-9]+\.[0-9]{6}$`)
+	for name, edit := range map[string]func(c *Config){
+		"negative gain":     func(c *Config) { c.Gain = [3]float64{-1, -2, -0.5} },
+		"tiny gamma":        func(c *Config) { c.Gamma = [3]float64{1e-3, 1e-3, 1e-3} },
+		"extreme exposure":  func(c *Config) { c.ExposureStops, c.ExposureOffset = maxExposureStops, 50 },
+		"super-white input": func(c *Config) { c.DomainMin, c.DomainMax, c.ToneMap = -2, 40, "aces" },
+		"linear input":      func(c *Config) { c.InputEncoding, c.DomainMax, c.NormalizeExposure = "linear", 1e6, true },
+		"dividing expr":     func(c *Config) { c.LookExpr = "r = r / (g - g); g = 0/0; b = -b/0" },
+	} {
+		cfg := defaultConfig(t, func(c *Config) { c.Size = 5; edit(c) })
+		lut, err := Generate(cfg)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		rows := 0
+		for _, line := range strings.Split(lut, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "LUT_") || strings.HasPrefix(line, "DOMAIN_") {
+				continue
+			}
+			rows++
+			for _, f := range fields {
+				if !value.MatchString(f) {
+					t.Errorf("%s: data value %q is not a finite fixed-point number", name, f)
+				}
+			}
+		}
+		if rows != 125 {
+			t.Errorf("%s: %d data lines, want 125", name, rows)
+		}
+	}
+
+	for _, offset := range []float64{-1, -1e-9} {
+		cfg := Config{ExposureOffset: offset}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "exposure_offset") {
+			t.Errorf("exposure_offset %g: error %v", offset, err)
+		}
+	}
+}
+
+func TestFiniteValue(t *testing.T) {
+	for _, tc := range []struct {
+		in, want float64
+		ok       bool
+	}{
+		{0.25, 0.25, true},
+		{-3, -3, true},
+		{math.NaN(), 0, false},
+		{math.Inf(1), 1, false},
+		{math.Inf(-1), 0, false},
+	} {
+		if got, ok := finiteValue(tc.in); got != tc.want || ok != tc.ok {
+			t.Errorf("finiteValue(%g) = %g, %t, want %g, %t", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestPrecision(t *testing.T) {
+	decimals := func(precision int) map[int]bool {
+		lut, err := Generate(defaultConfig(t, func(c *Config) { c.Size, c.Precision, c.DomainMax = 3, precision, 1e-9 }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seen := map[int]bool{}
+		for _, line := range strings.Split(lut, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 3 || strings.ContainsAny(line, "#_") {
+				continue
+			}
+			for _, f := range fields {
+				if strings.ContainsAny(f, "eE") {
+					t.Errorf("precision %d: value %q uses scientific notation", precision, f)
+				}
+				seen[len(f)-strings.IndexByte(f, '.')-1] = true
+			}
+		}
+		return seen
+	}
+	for _, tc := range []struct{ precision, want int }{{0, 6}, {8, 8}, {1, 2}, {12, 10}} {
+		if got := decimals(tc.precision); len(got) != 1 || !got[tc.want] {
+			t.Errorf("precision %d writes %v decimal places, want only %d", tc.precision, got, tc.want)
+		}
+	}
+}
+
+func TestDither(t *testing.T) {
+	generate := func(edit func(c *Config)) string {
+		lut, err := Generate(defaultConfig(t, func(c *Config) { c.Size = 9; edit(c) }))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return lut
+	}
+	plain := generate(func(c *Config) {})
+	if got := generate(func(c *Config) { c.DitherAmount = 0.01 }); got != plain {
+		t.Error("dither_amount without dither changed the output")
+	}
+	dithered := generate(func(c *Config) { c.Dither = true })
+	if dithered == plain {
+		t.Error("dither left the output unchanged")
+	}
+	if again := generate(func(c *Config) { c.Dither = true }); again != dithered {
+		t.Error("dithered output differs between runs")
+	}
+
+	clean, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size = 9 }))
+	noisy, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.Dither, c.DitherAmount = 9, true, 0.01 }))
+	for n := range clean.Data {
+		for c := range clean.Data[n] {
+			if d := math.Abs(noisy.Data[n][c] - clean.Data[n][c]); d > 0.01 {
+				t.Fatalf("node %d channel %d moved by %g, more than the amplitude 0.01", n, c, d)
+			}
+		}
+	}
+}
+
+// uncachedGrid computes every node of cfg's grid with processPixel, decoding
+// each channel afresh, as BuildCube did before caching the decode.
+func uncachedGrid(cfg Config) [][3]float64 {
+	size := cfg.Size
+	data := make([][3]float64, 0, size*size*size)
+	input := func(n int) float64 { return float64(n) / float64(size-1) }
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				r, g, b := processPixel(cfg, input(i), input(j), input(k))
+				data = append(data, [3]float64{r, g, b})
+			}
+		}
+	}
+	return data
+}
+
+func TestDecodeCacheMatchesProcessPixel(t *testing.T) {
+	for _, edit := range []func(c *Config){
+		func(c *Config) {},
+		func(c *Config) { c.ExposureStops, c.Temperature, c.Look = 0.7, 4300, "warmVintage" },
+		func(c *Config) { c.InputEncoding, c.ToneMap = "srgb", "aces" },
+	} {
+		cfg := defaultConfig(t, func(c *Config) { c.Size = 9; edit(c) })
+		cube, _ := BuildCube(cfg)
+		want := uncachedGrid(cfg)
+		for n := range want {
+			if cube.Data[n] != want[n] {
+				t.Errorf("node %d = %v, want the uncached %v", n, cube.Data[n], want[n])
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkDecodeCache(b *testing.B) {
+	cfg := defaultConfig(b, func(c *Config) { c.Size = 33 })
+	b.Run("cached", func(b *testing.B) {
+		serially(func() {
+			for i := 0; i < b.N; i++ {
+				BuildCube(cfg)
+			}
+		})
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			uncachedGrid(cfg)
+		}
+	})
+}
+
+func TestIdentityLUT(t *testing.T) {
+	const size = 5
+	cfg := defaultConfig(t, func(c *Config) {
+		c.Size, c.Identity, c.Precision = size, true, 4
+		c.Look, c.ExposureStops, c.Temperature, c.OutputBlack = "tealOrange", 2, 3200, 0.1
+	})
+	lut, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"TITLE \"Identity\"\n", fmt.Sprintf("LUT_3D_SIZE %d\n", size)} {
+		if !strings.Contains(lut, want) {
+			t.Errorf("identity LUT lacks the header line %q", strings.TrimSpace(want))
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(lut), "\n")
+	lines = lines[len(lines)-size*size*size:]
+	coord := func(n int) string { return fmt.Sprintf("%.4f", float64(n)/(size-1)) }
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				n := (i*size+j)*size + k
+				if want := coord(i) + " " + coord(j) + " " + coord(k); lines[n] != want {
+					t.Errorf("line %d of the data = %q, want %q", n, lines[n], want)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package luts
+
+import "fmt"
+
+// Curve is a tone curve given by [input, output] control points, with inputs
+// in increasing order. An empty curve is the identity.
+type Curve [][2]float64
+
+// validate checks that the control points have increasing inputs in [0,1].
+func (c Curve) validate(name string) error {
+	if len(c) == 1 {
+		return fmt.Errorf("%s needs at least 2 control points, got 1", name)
+	}
+	for i, p := range c {
+		if p[0] < 0 || p[0] > 1 {
+			return fmt.Errorf("%s point %d: input %g is outside [0, 1]", name, i, p[0])
+		}
+		if i > 0 && p[0] <= c[i-1][0] {
+			return fmt.Errorf("%s point %d: inputs must be strictly increasing, got %g after %g", name, i, p[0], c[i-1][0])
+		}
+	}
+	return nil
+}
+
+// apply evaluates the curve at x with monotone cubic (Fritsch-Carlson)
+// interpolation, which passes through every control point and never
+// overshoots between them, so a curve with non-decreasing outputs stays
+// non-decreasing. Inputs beyond the first or last point take that point's
+// output.
+func (c Curve) apply(x float64) float64 {
+	n := len(c)
+	if n < 2 {
+		return x
+	}
+	if x <= c[0][0] {
+		return c[0][1]
+	}
+	if x >= c[n-1][0] {
+		return c[n-1][1]
+	}
+	i := 0
+	for x > c[i+1][0] {
+		i++
+	}
+	h := c[i+1][0] - c[i][0]
+	t := (x - c[i][0]) / h
+	m0, m1 := c.tangent(i), c.tangent(i+1)
+	t2, t3 := t*t, t*t*t
+	return (2*t3-3*t2+1)*c[i][1] + (t3-2*t2+t)*h*m0 + (-2*t3+3*t2)*c[i+1][1] + (t3-t2)*h*m1
+}
+
+// slope returns the secant slope between points i and i+1.
+func (c Curve) slope(i int) float64 {
+	return (c[i+1][1] - c[i][1]) / (c[i+1][0] - c[i][0])
+}
+
+// tangent returns the Fritsch-Carlson tangent at point i: the end secants at
+// the ends, zero at local extrema, and otherwise the weighted harmonic mean
+// of the neighboring secants, which keeps each segment monotonic.
+func (c Curve) tangent(i int) float64 {
+	n := len(c)
+	if i == 0 {
+		return c.slope(0)
+	}
+	if i == n-1 {
+		return c.slope(n - 2)
+	}
+	d0, d1 := c.slope(i-1), c.slope(i)
+	if d0*d1 <= 0 {
+		return 0
+	}
+	h0, h1 := c[i][0]-c[i-1][0], c[i+1][0]-c[i][0]
+	w0, w1 := 2*h1+h0, h1+2*h0
+	return (w0 + w1) / (w0/d0 + w1/d1)
+}
+
+// curvesEnabled reports whether any channel has a tone curve.
+func (c *Config) curvesEnabled() bool {
+	return len(c.RedCurve) > 0 || len(c.GreenCurve) > 0 || len(c.BlueCurve) > 0
+}
+
+// validateCurves checks the RedCurve, GreenCurve, and BlueCurve control points.
+func (c *Config) validateCurves() error {
+	for i, curve := range [3]Curve{c.RedCurve, c.GreenCurve, c.BlueCurve} {
+		if err := curve.validate([3]string{"red_curve", "green_curve", "blue_curve"}[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCurveSCurve(t *testing.T) {
+	for _, s := range []Curve{
+		{{0, 0}, {0.5, 0.55}, {1, 1}},
+		{{0, 0}, {0.25, 0.15}, {0.75, 0.85}, {1, 1}},
+	} {
+		for _, p := range s {
+			if got := s.apply(p[0]); !near(got, p[1], 1e-12) {
+				t.Errorf("%v: apply(%g) = %g, want the control point output %g", s, p[0], got, p[1])
+			}
+		}
+		prev := s.apply(0)
+		for i := 1; i <= 1000; i++ {
+			x := float64(i) / 1000
+			got := s.apply(x)
+			if got < prev {
+				t.Fatalf("%v: apply(%g) = %g falls below %g", s, x, got, prev)
+			}
+			prev = got
+		}
+	}
+
+	// Three points with a flat section must not overshoot it.
+	flat := Curve{{0, 0}, {0.5, 0.6}, {1, 0.6}}
+	for _, x := range []float64{0.6, 0.75, 0.9} {
+		if got := flat.apply(x); !near(got, 0.6, 1e-12) {
+			t.Errorf("flat section: apply(%g) = %g, want 0.6", x, got)
+		}
+	}
+	for _, x := range []float64{0, 0.3, 1} {
+		if got := (Curve{}).apply(x); got != x {
+			t.Errorf("empty curve: apply(%g) = %g", x, got)
+		}
+	}
+}
+
+func TestCurveValidate(t *testing.T) {
+	for _, tc := range []struct {
+		curve Curve
+		want  string
+	}{
+		{Curve{{0.5, 0.5}}, "at least 2"},
+		{Curve{{0, 0}, {1.2, 1}}, "outside [0, 1]"},
+		{Curve{{-0.1, 0}, {1, 1}}, "outside [0, 1]"},
+		{Curve{{0, 0}, {0.6, 0.5}, {0.4, 0.7}, {1, 1}}, "strictly increasing"},
+		{Curve{{0, 0}, {0.5, 0.5}, {0.5, 0.6}, {1, 1}}, "strictly increasing"},
+	} {
+		if err := tc.curve.validate("red_curve"); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%v: error %v, want one containing %q", tc.curve, err, tc.want)
+		}
+	}
+	if err := (Curve{{0, 0.1}, {1, 0.9}}).validate("red_curve"); err != nil {
+		t.Errorf("valid curve: %v", err)
+	}
+}
@@ -0,0 +1,494 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dctlLooks maps the lowercased names of the looks GenerateDCTL implements to
+// their LOOK constant.
+var dctlLooks = map[string]int{"none": 0, "tealorange": 1, "warmvintage": 2, "bleachbypass": 3, "filmprint": 4}
+
+// validateDCTL checks that a config for the dctl format only uses stages
+// that the generated DCTL implements. Stages that work on the whole cube,
+// such as output_black and dither, have no per-pixel equivalent.
+func (c *Config) validateDCTL() error {
+	if _, ok := dctlLooks[strings.ToLower(c.Look)]; !ok {
+		return fmt.Errorf("look %q has no DCTL implementation", c.Look)
+	}
+	if c.Identity || c.Invert || c.LookPair || c.LookExpr != "" || c.ZoneLooks.enabled() || c.curvesEnabled() {
+		return fmt.Errorf("the dctl format cannot be combined with identity, invert, look_pair, look_expr, zone_looks, or RGB curves")
+	}
+	if c.ShaperOnly || c.ShaperSize > 0 {
+		return fmt.Errorf("shaper_only and shaper_size require the cube format")
+	}
+	if c.OutputBlack > 0 || c.Dither || c.QuantizeBits > 0 {
+		return fmt.Errorf("the dctl format cannot be combined with output_black, dither, or quantize_bits")
+	}
+	return nil
+}
+
+// dctlFloat formats v as a single-precision C literal, e.g. "1.0f".
+func dctlFloat(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 32)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s + "f"
+}
+
+// dctlBool returns the DCTL constant for a boolean switch.
+func dctlBool(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// GenerateDCTL returns DaVinci Resolve DCTL source that applies cfg's
+// conversion per pixel, without the interpolation error of a baked LUT. The
+// config values become #define constants in front of a fixed transform that
+// mirrors processPixel; cfg must have passed Validate for the dctl format.
+func GenerateDCTL(cfg Config) string {
+	target, err := cfg.displayTarget()
+	if err != nil {
+		target = displayTargets["rec709"]
+	}
+	m, convert := gamutMatrix(cfg, target)
+	gamutMode := 0 // No conversion
+	switch {
+	case strings.EqualFold(cfg.GamutMapping, "compress"):
+		gamutMode = 2
+	case convert && strings.EqualFold(cfg.GamutMapping, "preserveHue"):
+		gamutMode = 4
+	case convert && target.Unbounded:
+		gamutMode = 3
+	case convert:
+		gamutMode = 1
+	}
+	inputEncoding := map[string]int{"applelog": 0, "linear": 1, "srgb": 2}[strings.ToLower(cfg.InputEncoding)]
+	toneMap := map[string]int{"none": 0, "reinhard": 1, "aces": 2}[strings.ToLower(cfg.ToneMap)]
+	transfer, gamma := 0, 1.0
+	switch target.Transfer {
+	case "srgb":
+		transfer = 1
+	case "gamma2.4":
+		transfer, gamma = 2, 2.4
+	case "gamma2.6":
+		transfer, gamma = 2, 2.6
+	case "gamma":
+		transfer, gamma = 2, target.Gamma
+	case "hlg":
+		transfer = 3
+	case "pq":
+		transfer = 4
+	case "acescct":
+		transfer = 5
+	}
+	saturation := 1.0
+	if cfg.Saturation != nil {
+		saturation = *cfg.Saturation
+	}
+	contrast := 1.0
+	if cfg.Contrast != nil {
+		contrast = *cfg.Contrast
+	}
+	normalize := [3]float64{1, 1, 1}
+	if cfg.NormalizeWhite {
+		for c, w := range whiteLevels(cfg) {
+			normalize[c] = 1 / w
+		}
+	}
+	wb := whiteBalanceGains(cfg)
+	gamutScale := (gamutLimit - gamutThreshold) /
+		math.Pow(math.Pow((1-gamutThreshold)/(gamutLimit-gamutThreshold), -gamutPower)-1, 1/gamutPower)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("// Generated DCTL for Apple Log to %s conversion\n", target.Name))
+	b.WriteString(fmt.Sprintf("// %s\n\n", strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(cubeTitle(cfg))))
+	define := func(name string, v any) {
+		if f, ok := v.(float64); ok {
+			v = dctlFloat(f)
+		}
+		b.WriteString(fmt.Sprintf("#define %s %v\n", name, v))
+	}
+	define("INPUT_ENCODING", inputEncoding)
+	offset, gain := cfg.ExposureOffset, math.Exp2(cfg.ExposureStops)
+	start, span, a := 0.0, 1.0, 0.0
+	if cfg.NormalizeExposure {
+		offset, gain = 1, exposureGain(cfg)
+		start, span, a = exposureRolloff(cfg)
+	}
+	define("EXPOSURE_OFFSET", offset)
+	define("INPUT_MAX", max(cfg.DomainMax, 1))
+	define("EXPOSURE_GAIN", gain)
+	define("ROLLOFF_START", start)
+	define("ROLLOFF_SPAN", span)
+	define("ROLLOFF_A", a)
+	define("WB_R", wb[0])
+	define("WB_G", wb[1])
+	define("WB_B", wb[2])
+	define("TONE_MAP", toneMap)
+	define("KNEE_START", cfg.KneeStart)
+	define("KNEE_STRENGTH", cfg.KneeStrength)
+	define("GAMUT_MODE", gamutMode)
+	define("GAMUT_UNBOUNDED", dctlBool(target.Unbounded))
+	for i, v := range m {
+		define(fmt.Sprintf("M%d%d", i/3, i%3), v)
+	}
+	define("GAMUT_THRESHOLD", gamutThreshold)
+	define("GAMUT_SCALE", gamutScale)
+	define("GAMUT_POWER", gamutPower)
+	define("BLACK_POINT", cfg.BlackPoint)
+	define("WHITE_POINT", cfg.WhitePoint)
+	define("SHADOW_LIFT", cfg.ShadowLift)
+	define("SHADOW_LIFT_PIVOT", shadowLiftPivot(cfg.ShadowLiftSpace))
+	define("SHADOW_LIFT_LINEAR", dctlBool(strings.EqualFold(cfg.ShadowLiftSpace, "linear")))
+	define("TRANSFER", transfer)
+	define("TRANSFER_GAMMA", gamma)
+	define("PEAK_NITS", cfg.PeakNits)
+	for c, ch := range [3]string{"R", "G", "B"} {
+		define("LIFT_"+ch, cfg.Lift[c])
+		define("GAMMA_"+ch, cfg.Gamma[c])
+		define("GAIN_"+ch, cfg.Gain[c])
+	}
+	define("SATURATION", saturation)
+	define("CONTRAST", contrast)
+	define("CONTRAST_PIVOT", cfg.ContrastPivot)
+	define("LOOK", dctlLooks[strings.ToLower(cfg.Look)])
+	define("LOOK_STRENGTH", cfg.lookStrength())
+	define("LOOK_INTENSITY", cfg.LookIntensity)
+	for c, ch := range [3]string{"R", "G", "B"} {
+		define("LUMA_"+ch, cfg.lumaWeights()[c])
+	}
+	define("TEAL_ORANGE_PIVOT", cfg.TealOrangePivot)
+	define("TEAL_ORANGE_WIDTH", cfg.TealOrangeWidth)
+	define("TEAL_SHADOW_BLUE", cfg.param("shadow_blue_boost", tealOrangeParams))
+	define("TEAL_HIGHLIGHT_WARMTH", cfg.param("highlight_warmth", tealOrangeParams))
+	define("VINTAGE_WARMTH", cfg.param("warmth", warmVintageParams))
+	define("VINTAGE_FADE", cfg.param("fade", warmVintageParams))
+	define("FILM_DENSITY", cfg.param("density", filmPrintParams))
+	define("NORMALIZE_WHITE", dctlBool(cfg.NormalizeWhite))
+	define("NORMALIZE_R", normalize[0])
+	define("NORMALIZE_G", normalize[1])
+	define("NORMALIZE_B", normalize[2])
+	define("SOFT_CLIP", dctlBool(strings.EqualFold(cfg.OutputClip, "soft")))
+	define("SOFT_CLIP_START", softClipStart)
+	define("SOFT_CLIP_STRENGTH", softClipStrength)
+	b.WriteString(dctlBody)
+	return cfg.withNewlines(b.String())
+}
+
+// dctlBody is the DCTL transform behind the constants written by
+// GenerateDCTL. Each function mirrors the Go function of the same name.
+const dctlBody = `
+__DEVICE__ float clamp01(float x) {
+    return _fminf(_fmaxf(x, 0.0f), 1.0f);
+}
+
+__DEVICE__ float smoothstep(float edge0, float edge1, float x) {
+    if (edge1 <= edge0) {
+        return x < edge0 ? 0.0f : 1.0f;
+    }
+    float t = clamp01((x - edge0) / (edge1 - edge0));
+    return t * t * (3.0f - 2.0f * t);
+}
+
+__DEVICE__ float rollOffHighlight(float v) {
+    if (ROLLOFF_A == 0.0f || v <= ROLLOFF_START) {
+        return v;
+    }
+    float t = (v - ROLLOFF_START) / ROLLOFF_SPAN;
+    return ROLLOFF_START + ROLLOFF_SPAN * t / (1.0f + ROLLOFF_A * t);
+}
+
+__DEVICE__ float decodeSignal(float v) {
+    if (INPUT_ENCODING == 2) {
+        return v <= 0.04045f ? v / 12.92f : _powf((v + 0.055f) / 1.055f, 2.4f);
+    }
+    if (INPUT_ENCODING == 1) {
+        return v;
+    }
+    return _powf(_fmaxf(v, 0.0f), 1.5f);
+}
+
+__DEVICE__ float encodeSignal(float v) {
+    if (INPUT_ENCODING == 2) {
+        return v <= 0.0031308f ? 12.92f * v : 1.055f * _powf(v, 1.0f / 2.4f) - 0.055f;
+    }
+    if (INPUT_ENCODING == 1) {
+        return v;
+    }
+    return _powf(_fmaxf(v, 0.0f), 1.0f / 1.5f);
+}
+
+__DEVICE__ float decodeInput(float x) {
+    if (EXPOSURE_GAIN == 1.0f) {
+        return rollOffHighlight(decodeSignal(_fminf(x * EXPOSURE_OFFSET, INPUT_MAX)));
+    }
+    float v = decodeSignal(_fminf(x, INPUT_MAX)) * EXPOSURE_GAIN;
+    if (EXPOSURE_OFFSET != 1.0f) {
+        v = decodeSignal(_fminf(encodeSignal(v) * EXPOSURE_OFFSET, INPUT_MAX));
+    }
+    return rollOffHighlight(v);
+}
+
+__DEVICE__ float toneMap(float x) {
+    x = _fmaxf(x, 0.0f);
+    if (TONE_MAP == 1) {
+        return x / (1.0f + x);
+    }
+    return _fminf((x * (2.51f * x + 0.03f)) / (x * (2.43f * x + 0.59f) + 0.14f), 1.0f);
+}
+
+__DEVICE__ float applyKnee(float x) {
+    if (KNEE_START >= 1.0f || x <= KNEE_START) {
+        return x;
+    }
+    float t = (x - KNEE_START) / (1.0f - KNEE_START);
+    return KNEE_START + (1.0f - KNEE_START) * t / _powf(1.0f + _powf(t, KNEE_STRENGTH), 1.0f / KNEE_STRENGTH);
+}
+
+__DEVICE__ float softClip(float x) {
+    if (x <= SOFT_CLIP_START) {
+        return x;
+    }
+    float t = (x - SOFT_CLIP_START) / (1.0f - SOFT_CLIP_START);
+    return SOFT_CLIP_START + (1.0f - SOFT_CLIP_START) * t / _powf(1.0f + _powf(t, SOFT_CLIP_STRENGTH), 1.0f / SOFT_CLIP_STRENGTH);
+}
+
+__DEVICE__ float compressChannel(float c, float ach) {
+    float d = (ach - c) / ach;
+    if (d > GAMUT_THRESHOLD) {
+        float x = (d - GAMUT_THRESHOLD) / GAMUT_SCALE;
+        d = GAMUT_THRESHOLD + GAMUT_SCALE * x / _powf(1.0f + _powf(x, GAMUT_POWER), 1.0f / GAMUT_POWER);
+    }
+    return ach - d * ach;
+}
+
+__DEVICE__ float3 convertGamut(float r, float g, float b) {
+    if (GAMUT_MODE != 0) {
+        float cr = M00 * r + M01 * g + M02 * b;
+        float cg = M10 * r + M11 * g + M12 * b;
+        float cb = M20 * r + M21 * g + M22 * b;
+        if (GAMUT_MODE == 1) {
+            return make_float3(clamp01(cr), clamp01(cg), clamp01(cb));
+        }
+        if (GAMUT_MODE == 3) {
+            return make_float3(_fmaxf(cr, 0.0f), _fmaxf(cg, 0.0f), _fmaxf(cb, 0.0f));
+        }
+        if (GAMUT_MODE == 4) {
+            float lo = _fminf(cr, _fminf(cg, cb));
+            if (lo < 0.0f) {
+                float y = LUMA_R * cr + LUMA_G * cg + LUMA_B * cb;
+                if (y <= 0.0f) {
+                    return make_float3(0.0f, 0.0f, 0.0f);
+                }
+                float t = y / (y - lo);
+                cr = y + t * (cr - y); cg = y + t * (cg - y); cb = y + t * (cb - y);
+            }
+            float hi = _fmaxf(cr, _fmaxf(cg, cb));
+            if (hi > 1.0f && GAMUT_UNBOUNDED == 0) {
+                cr /= hi; cg /= hi; cb /= hi;
+            }
+            return make_float3(_fmaxf(cr, 0.0f), _fmaxf(cg, 0.0f), _fmaxf(cb, 0.0f));
+        }
+        r = cr; g = cg; b = cb;
+    }
+    if (GAMUT_MODE == 2) {
+        float ach = _fmaxf(r, _fmaxf(g, b));
+        if (ach <= 0.0f) {
+            return make_float3(0.0f, 0.0f, 0.0f);
+        }
+        r = compressChannel(r, ach);
+        g = compressChannel(g, ach);
+        b = compressChannel(b, ach);
+        if (GAMUT_UNBOUNDED != 0) {
+            return make_float3(_fmaxf(r, 0.0f), _fmaxf(g, 0.0f), _fmaxf(b, 0.0f));
+        }
+        if (ach > 1.0f) {
+            r /= ach; g /= ach; b /= ach;
+        }
+        return make_float3(clamp01(r), clamp01(g), clamp01(b));
+    }
+    return make_float3(r, g, b);
+}
+
+__DEVICE__ float applyLevels(float x) {
+    return clamp01((x - BLACK_POINT) / (WHITE_POINT - BLACK_POINT));
+}
+
+__DEVICE__ float applyShadowLift(float x) {
+    if (SHADOW_LIFT == 0.0f || x <= 0.0f || x >= SHADOW_LIFT_PIVOT) {
+        return x;
+    }
+    float u = x / SHADOW_LIFT_PIVOT;
+    return x + SHADOW_LIFT * 27.0f / 4.0f * u * (1.0f - u) * (1.0f - u);
+}
+
+__DEVICE__ float encodeTransfer(float x) {
+    if (TRANSFER == 1) {
+        return x <= 0.0031308f ? 12.92f * x : 1.055f * _powf(x, 1.0f / 2.4f) - 0.055f;
+    }
+    if (TRANSFER == 2) {
+        return _powf(_fmaxf(x, 0.0f), 1.0f / TRANSFER_GAMMA);
+    }
+    if (TRANSFER == 3) {
+        x = _fmaxf(x, 0.0f);
+        if (x <= 1.0f / 12.0f) {
+            return _sqrtf(3.0f * x);
+        }
+        return 0.17883277f * _logf(12.0f * x - 0.28466892f) + 0.55991073f;
+    }
+    if (TRANSFER == 4) {
+        float p = _powf(_fmaxf(x, 0.0f) * PEAK_NITS / 10000.0f, 2610.0f / 16384.0f);
+        return _powf((3424.0f / 4096.0f + 2413.0f / 128.0f * p) / (1.0f + 2392.0f / 128.0f * p), 2523.0f / 32.0f);
+    }
+    if (TRANSFER == 5) {
+        if (x <= 0.0078125f) {
+            return 10.5402377416545f * x + 0.0729055341958355f;
+        }
+        return (_log2f(x) + 9.72f) / 17.52f;
+    }
+    return x < 0.018f ? 4.5f * x : 1.099f * _powf(x, 0.45f) - 0.099f;
+}
+
+__DEVICE__ float applyLiftGammaGain(float x, float lift, float gamma, float gain) {
+    if (lift == 0.0f && gamma == 1.0f && gain == 1.0f) {
+        return x;
+    }
+    float v = x * (gain - lift) + lift;
+    if (gamma != 1.0f && v > 0.0f) {
+        v = _powf(v, 1.0f / gamma);
+    }
+    return GAMUT_UNBOUNDED != 0 ? _fmaxf(v, 0.0f) : _fminf(_fmaxf(v, 0.0f), 1.0f);
+}
+
+__DEVICE__ float3 applySaturation(float r, float g, float b) {
+    float v = _fmaxf(r, _fmaxf(g, b));
+    if (v <= 0.0f) {
+        return make_float3(0.0f, 0.0f, 0.0f);
+    }
+    float s = (v - _fminf(r, _fminf(g, b))) / v;
+    if (s == 0.0f) {
+        return make_float3(_fminf(v, 1.0f), _fminf(v, 1.0f), _fminf(v, 1.0f));
+    }
+    float k = _fminf(s * SATURATION, 1.0f) / s;
+    return make_float3(clamp01(v - (v - r) * k), clamp01(v - (v - g) * k), clamp01(v - (v - b) * k));
+}
+
+__DEVICE__ float applyContrast(float x) {
+    return clamp01((x - CONTRAST_PIVOT) * CONTRAST + CONTRAST_PIVOT);
+}
+
+__DEVICE__ float overlay(float base, float lum) {
+    base = 0.5f * (base + lum);
+    if (base < 0.5f) {
+        return 2.0f * base * lum;
+    }
+    return 1.0f - 2.0f * (1.0f - base) * (1.0f - lum);
+}
+
+__DEVICE__ float3 applyLook(float r, float g, float b) {
+    if (LOOK == 1) {
+        float lum = LUMA_R * r + LUMA_G * g + LUMA_B * b;
+        float w = smoothstep(TEAL_ORANGE_PIVOT - TEAL_ORANGE_WIDTH / 2.0f, TEAL_ORANGE_PIVOT + TEAL_ORANGE_WIDTH / 2.0f, lum);
+        float mix = 0.3f * LOOK_STRENGTH;
+        r = (1.0f - mix) * r + mix * r * ((1.0f - w) * 0.95f + w * (1.0f + TEAL_HIGHLIGHT_WARMTH));
+        g = (1.0f - mix) * g + mix * g * ((1.0f - w) * 1.03f + w * 1.0f);
+        b = (1.0f - mix) * b + mix * b * ((1.0f - w) * (1.0f + TEAL_SHADOW_BLUE) + w * 0.95f);
+        return make_float3(_fminf(r, 1.0f), _fminf(g, 1.0f), _fminf(b, 1.0f));
+    }
+    if (LOOK == 2) {
+        float gray = VINTAGE_FADE * LOOK_STRENGTH;
+        r = (1.0f - gray) * r * (1.0f + VINTAGE_WARMTH * LOOK_STRENGTH) + gray * 0.5f;
+        g = (1.0f - gray) * g + gray * 0.5f;
+        b = (1.0f - gray) * b * (1.0f - VINTAGE_WARMTH * LOOK_STRENGTH) + gray * 0.5f;
+        return make_float3(_fminf(r, 1.0f), _fminf(g, 1.0f), _fminf(b, 1.0f));
+    }
+    if (LOOK == 3) {
+        float intensity = LOOK_INTENSITY * LOOK_STRENGTH;
+        float lum = LUMA_R * r + LUMA_G * g + LUMA_B * b;
+        float lr = overlay(r, lum);
+        float lg = overlay(g, lum);
+        float lb = overlay(b, lum);
+        float l = LUMA_R * lr + LUMA_G * lg + LUMA_B * lb;
+        if (l > 0.0f) {
+            lr *= lum / l;
+            lg *= lum / l;
+            lb *= lum / l;
+        }
+        r = (1.0f - intensity) * r + intensity * lr;
+        g = (1.0f - intensity) * g + intensity * lg;
+        b = (1.0f - intensity) * b + intensity * lb;
+        return make_float3(clamp01(r), clamp01(g), clamp01(b));
+    }
+    if (LOOK == 4) {
+        float3 in = make_float3(r, g, b);
+        float cr = clamp01(0.90f * r + 0.07f * g + 0.03f * b);
+        float cg = clamp01(0.05f * r + 0.90f * g + 0.05f * b);
+        float cb = clamp01(0.03f * r + 0.09f * g + 0.88f * b);
+        cr += FILM_DENSITY * 0.32f * (cr * cr * (3.0f - 2.0f * cr) - cr);
+        cg += FILM_DENSITY * 0.30f * (cg * cg * (3.0f - 2.0f * cg) - cg);
+        cb += FILM_DENSITY * 0.28f * (cb * cb * (3.0f - 2.0f * cb) - cb);
+        r = in.x + LOOK_STRENGTH * (cr - in.x);
+        g = in.y + LOOK_STRENGTH * (cg - in.y);
+        b = in.z + LOOK_STRENGTH * (cb - in.z);
+        return make_float3(clamp01(r), clamp01(g), clamp01(b));
+    }
+    return make_float3(r, g, b);
+}
+
+__DEVICE__ float3 transform(int p_Width, int p_Height, int p_X, int p_Y, float p_R, float p_G, float p_B)
+{
+    // Step 1: Decode to linear light and white balance.
+    float r = decodeInput(p_R) * WB_R;
+    float g = decodeInput(p_G) * WB_G;
+    float b = decodeInput(p_B) * WB_B;
+
+    // Step 2: Tone map, apply the knee, and convert to the target primaries.
+    if (TONE_MAP != 0) {
+        r = toneMap(r); g = toneMap(g); b = toneMap(b);
+    }
+    r = applyKnee(r); g = applyKnee(g); b = applyKnee(b);
+    float3 c = convertGamut(r, g, b);
+    r = c.x; g = c.y; b = c.z;
+    if (BLACK_POINT != 0.0f || WHITE_POINT != 1.0f) {
+        r = applyLevels(r); g = applyLevels(g); b = applyLevels(b);
+    }
+
+    // Step 3: Encode, with the shadow lift, lift/gamma/gain, saturation, and contrast.
+    if (SHADOW_LIFT_LINEAR == 1) {
+        r = applyShadowLift(r); g = applyShadowLift(g); b = applyShadowLift(b);
+    }
+    r = encodeTransfer(r); g = encodeTransfer(g); b = encodeTransfer(b);
+    if (SHADOW_LIFT_LINEAR == 0) {
+        r = applyShadowLift(r); g = applyShadowLift(g); b = applyShadowLift(b);
+    }
+    r = applyLiftGammaGain(r, LIFT_R, GAMMA_R, GAIN_R);
+    g = applyLiftGammaGain(g, LIFT_G, GAMMA_G, GAIN_G);
+    b = applyLiftGammaGain(b, LIFT_B, GAMMA_B, GAIN_B);
+    if (SATURATION != 1.0f) {
+        c = applySaturation(r, g, b);
+        r = c.x; g = c.y; b = c.z;
+    }
+    if (CONTRAST != 1.0f) {
+        r = applyContrast(r); g = applyContrast(g); b = applyContrast(b);
+    }
+
+    // Step 4: Apply the creative look, then scale white to white.
+    c = applyLook(r, g, b);
+    if (NORMALIZE_WHITE == 1) {
+        c = make_float3(c.x * NORMALIZE_R, c.y * NORMALIZE_G, c.z * NORMALIZE_B);
+        if (SOFT_CLIP == 0) {
+            c = make_float3(_fminf(c.x, 1.0f), _fminf(c.y, 1.0f), _fminf(c.z, 1.0f));
+        }
+    }
+    if (SOFT_CLIP == 1) {
+        c = make_float3(softClip(c.x), softClip(c.y), softClip(c.z));
+    }
+    return c;
+}
+`
@@ -0,0 +1,42 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateDCTLNamesTarget(t *testing.T) {
+	for _, tc := range []struct {
+		edit func(c *Config)
+		want string
+	}{
+		{func(c *Config) {}, "Rec.709"},
+		{func(c *Config) { c.Target = "srgb" }, "sRGB"},
+		{func(c *Config) { c.Target = "p3d65" }, "P3-D65"},
+		{func(c *Config) { c.Target = "appleReference" }, "Apple Reference Mode"},
+		{func(c *Config) { c.TargetColorSpace = "acescct" }, "ACEScct"},
+	} {
+		src := GenerateDCTL(defaultConfig(t, tc.edit))
+		header, _, _ := strings.Cut(src, "\n")
+		if want := "// Generated DCTL for Apple Log to " + tc.want + " conversion"; header != want {
+			t.Errorf("header = %q, want %q", header, want)
+		}
+	}
+}
+
+func TestGenerateDCTLDefinesConfig(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) {
+		c.Look, c.ExposureStops, c.KneeStart = "warmVintage", 1, 0.75
+	})
+	src := GenerateDCTL(cfg)
+	for _, want := range []string{
+		"__DEVICE__ float3 transform(int p_Width, int p_Height, int p_X, int p_Y, float p_R, float p_G, float p_B)",
+		"#define LOOK 2\n",
+		"#define EXPOSURE_GAIN 2.0f\n",
+		"#define KNEE_START 0.75f\n",
+	} {
+		if !strings.Contains(src, want) {
+			t.Errorf("generated DCTL is missing %q", want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// CubeDiff summarizes how far two cubes' outputs differ, as measured by
+// DiffCubes.
+type CubeDiff struct {
+	Size    int        // Grid size compared along each axis
+	Max     [3]float64 // Largest absolute difference per channel: red, green, blue
+	Mean    [3]float64 // Mean absolute difference per channel
+	MaxAt   [3]int     // Grid coordinate (red, green, blue) of the largest difference in any channel
+	MaxIn   [3]float64 // Input value at MaxAt
+	Largest float64    // Largest absolute difference in any channel
+
+	// Nodes holds the largest absolute channel difference at each compared
+	// node, in the order of Cube.Data for a cube of Size.
+	Nodes []float64
+}
+
+// DiffCubes compares a and b, node by node, over a grid spanning a's domain
+// at the larger of the two grid sizes. Both cubes are sampled trilinearly,
+// so cubes of different sizes can be compared, and nodes shared by both
+// grids are compared exactly.
+func DiffCubes(a, b *Cube) CubeDiff {
+	size := max(a.Size, b.Size)
+	d := a.domain()
+	input := func(n int) float64 { return d[0] + float64(n)/float64(size-1)*(d[1]-d[0]) }
+	slices := make([]CubeDiff, size)
+	nodes := make([]float64, size*size*size)
+	parallelSlices(size, func(i int) {
+		s := &slices[i]
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				in := [3]float64{input(i), input(j), input(k)}
+				va, vb := a.sample(in[0], in[1], in[2]), b.sample(in[0], in[1], in[2])
+				n := &nodes[(i*size+j)*size+k]
+				for c := range va {
+					diff := math.Abs(va[c] - vb[c])
+					*n = max(*n, diff)
+					s.Mean[c] += diff
+					s.Max[c] = max(s.Max[c], diff)
+					if diff > s.Largest {
+						s.Largest, s.MaxAt, s.MaxIn = diff, [3]int{i, j, k}, in
+					}
+				}
+			}
+		}
+	})
+
+	result := CubeDiff{Size: size, Nodes: nodes}
+	for _, s := range slices {
+		for c := range result.Max {
+			result.Mean[c] += s.Mean[c]
+			result.Max[c] = max(result.Max[c], s.Max[c])
+		}
+		if s.Largest > result.Largest {
+			result.Largest, result.MaxAt, result.MaxIn = s.Largest, s.MaxAt, s.MaxIn
+		}
+	}
+	for c := range result.Mean {
+		result.Mean[c] /= float64(len(nodes))
+	}
+	return result
+}
+
+// heatColor maps t in [0,1] to a heatmap color running from black through
+// red and yellow to white.
+func heatColor(t float64) color.NRGBA {
+	t = math.Min(math.Max(t, 0), 1) * 3
+	return color.NRGBA{
+		R: to8Bit(t),
+		G: to8Bit(t - 1),
+		B: to8Bit(t - 2),
+		A: 255,
+	}
+}
+
+// RenderDiffHeatmap renders d's per-node differences as a slice montage: one
+// tile per blue slice, with red increasing to the right and green downward,
+// and tiles in increasing blue from left to right and top to bottom. Each
+// node's largest channel difference is scaled so that d.Largest is white;
+// identical cubes render every node black.
+func RenderDiffHeatmap(d CubeDiff) *image.NRGBA {
+	return sliceMontage(d.Size, func(r, g, b int) color.NRGBA {
+		if d.Largest == 0 {
+			return heatColor(0)
+		}
+		return heatColor(d.Nodes[(r*d.Size+g)*d.Size+b] / d.Largest)
+	})
+}
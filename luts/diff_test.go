@@ -0,0 +1,53 @@
+package luts
+
+import "testing"
+
+func TestDiffHeatmapFlatForIdenticalCubes(t *testing.T) {
+	cube, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size = 9 }))
+	d := DiffCubes(cube, cube)
+	if d.Largest != 0 {
+		t.Fatalf("identical cubes: largest difference %g", d.Largest)
+	}
+	img := RenderDiffHeatmap(d)
+	l := newMontageLayout(d.Size)
+	if img.Bounds() != l.bounds() {
+		t.Fatalf("heatmap bounds %v, want %v", img.Bounds(), l.bounds())
+	}
+	for b := 0; b < d.Size; b++ {
+		for g := 0; g < d.Size; g++ {
+			for r := 0; r < d.Size; r++ {
+				if p := l.node(r, g, b); img.NRGBAAt(p.X, p.Y) != heatColor(0) {
+					t.Fatalf("node (%d, %d, %d) = %v, want %v", r, g, b, img.NRGBAAt(p.X, p.Y), heatColor(0))
+				}
+			}
+		}
+	}
+}
+
+func TestDiffHeatmapHighlightsChangedNode(t *testing.T) {
+	a, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size = 9 }))
+	b := *a
+	b.Data = append([][3]float64(nil), a.Data...)
+	hot := [3]int{2, 5, 7}
+	b.Data[a.index(hot[0], hot[1], hot[2])][1] += 0.25
+
+	d := DiffCubes(a, &b)
+	if d.MaxAt != hot || !near(d.Largest, 0.25, 1e-12) {
+		t.Fatalf("largest difference %g at %v, want 0.25 at %v", d.Largest, d.MaxAt, hot)
+	}
+	img := RenderDiffHeatmap(d)
+	l := newMontageLayout(d.Size)
+	for bl := 0; bl < d.Size; bl++ {
+		for g := 0; g < d.Size; g++ {
+			for r := 0; r < d.Size; r++ {
+				want := heatColor(0)
+				if [3]int{r, g, bl} == hot {
+					want = heatColor(1)
+				}
+				if p := l.node(r, g, bl); img.NRGBAAt(p.X, p.Y) != want {
+					t.Errorf("node (%d, %d, %d) = %v, want %v", r, g, bl, img.NRGBAAt(p.X, p.Y), want)
+				}
+			}
+		}
+	}
+}
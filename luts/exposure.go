@@ -0,0 +1,54 @@
+package luts
+
+import "math"
+
+// Parameters of the optimal exposure search. Exposure offsets are searched on
+// a log2 (stops) scale around the neutral offset of 1.0.
+const (
+	exposureSearchStops   = 2.0  // Search from -2 to +2 stops
+	exposureSearchStep    = 0.01 // Resolution of the search, in stops
+	exposureSearchSamples = 256  // Neutral ramp samples evaluated per candidate
+	exposureClipLevel     = 1 - 0.5/255
+	exposureCrushLevel    = 0.5 / 255
+)
+
+// exposureCost returns the fractions of a neutral input ramp that the
+// pipeline clips to white and crushes to black under cfg.
+func exposureCost(cfg Config) (clipped, crushed float64) {
+	for i := 0; i < exposureSearchSamples; i++ {
+		x := float64(i) / float64(exposureSearchSamples-1)
+		r, g, b := processPixel(cfg, x, x, x)
+		if max(r, g, b) >= exposureClipLevel {
+			clipped++
+		}
+		if max(r, g, b) <= exposureCrushLevel {
+			crushed++
+		}
+	}
+	return clipped / exposureSearchSamples, crushed / exposureSearchSamples
+}
+
+// OptimalExposure searches for the exposure offset that minimizes the
+// combined highlight clipping and shadow crushing of the neutral transfer for
+// cfg's look and gamut. When a range of offsets ties, the middle of the range
+// (in stops) is chosen so the result sits centrally between both limits.
+// It returns the offset with the clipped and crushed fractions it produces.
+func OptimalExposure(cfg Config) (offset, clipped, crushed float64) {
+	bestCost := math.Inf(1)
+	var bestFirst, bestLast float64
+	for stops := -exposureSearchStops; stops <= exposureSearchStops+1e-9; stops += exposureSearchStep {
+		cfg.ExposureOffset = math.Exp2(stops)
+		clip, crush := exposureCost(cfg)
+		cost := clip + crush
+		switch {
+		case cost < bestCost-1e-12:
+			bestCost = cost
+			bestFirst, bestLast = stops, stops
+		case math.Abs(cost-bestCost) <= 1e-12:
+			bestLast = stops
+		}
+	}
+	cfg.ExposureOffset = math.Exp2((bestFirst + bestLast) / 2)
+	clipped, crushed = exposureCost(cfg)
+	return cfg.ExposureOffset, clipped, crushed
+}
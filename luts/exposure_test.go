@@ -0,0 +1,58 @@
+package luts
+
+import (
+	"math"
+	"testing"
+)
+
+func TestOptimalExposureCentersTies(t *testing.T) {
+	// With linear input, any offset from 2^-2 (the end of the search) up to
+	// just below 1.0 keeps white unclipped and crushes only the black sample,
+	// so the search should land in the middle of that range in stops.
+	cfg := defaultConfig(t, func(c *Config) { c.InputEncoding = "linear" })
+	offset, clipped, crushed := OptimalExposure(cfg)
+	if clipped != 0 || crushed != 1.0/exposureSearchSamples {
+		t.Errorf("clipped %g, crushed %g, want 0 and only the black sample", clipped, crushed)
+	}
+	if stops := math.Log2(offset); !near(stops, -1, 0.02) {
+		t.Errorf("offset %g (%.3f stops), want the middle of [-2, 0) stops", offset, stops)
+	}
+}
+
+func TestOptimalExposureNotWorseThanNeutral(t *testing.T) {
+	for _, look := range []string{"none", "tealOrange", "filmPrint"} {
+		cfg := defaultConfig(t, func(c *Config) { c.Look = look })
+		_, clipped, crushed := OptimalExposure(cfg)
+		neutralClip, neutralCrush := exposureCost(cfg)
+		if clipped+crushed > neutralClip+neutralCrush {
+			t.Errorf("%s: optimal cost %g exceeds the neutral offset's %g", look, clipped+crushed, neutralClip+neutralCrush)
+		}
+	}
+}
+
+func TestNormalizeExposureKeepsGrayRampIncreasing(t *testing.T) {
+	ramp := func(normalize bool) []float64 {
+		cfg := defaultConfig(t, func(c *Config) { c.ExposureOffset, c.NormalizeExposure = 1.5, normalize })
+		out := make([]float64, 257)
+		for i := range out {
+			v := float64(i) / float64(len(out)-1)
+			_, out[i], _ = processPixel(cfg, v, v, v)
+		}
+		return out
+	}
+	norm := ramp(true)
+	for i := 1; i < len(norm); i++ {
+		if norm[i] <= norm[i-1] {
+			t.Fatalf("normalized ramp step %d: %g, not above %g", i, norm[i], norm[i-1])
+		}
+	}
+	if top := norm[len(norm)-1]; top > 1 {
+		t.Errorf("normalized ramp ends at %g, above 1.0", top)
+	}
+
+	// Without the rolloff the same offset clips the top of the ramp flat.
+	plain := ramp(false)
+	if plain[len(plain)-2] != plain[len(plain)-1] {
+		t.Errorf("plain ramp ends %g, %g; want the offset to clip it", plain[len(plain)-2], plain[len(plain)-1])
+	}
+}
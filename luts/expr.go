@@ -0,0 +1,383 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Limits that keep user-supplied look expressions cheap to evaluate. The
+// language has no loops, calls out to nothing but the math helpers below, and
+// cannot perform I/O, so bounding its size bounds its cost per LUT node.
+const (
+	maxLookExprLen   = 4096
+	maxLookExprNodes = 1000
+)
+
+// lookExprFuncs are the functions callable from a look expression, keyed by
+// name with the number of arguments they take.
+var lookExprFuncs = map[string]struct {
+	arity int
+	fn    func(args []float64) float64
+}{
+	"abs":   {1, func(a []float64) float64 { return math.Abs(a[0]) }},
+	"sqrt":  {1, func(a []float64) float64 { return math.Sqrt(math.Max(a[0], 0)) }},
+	"min":   {2, func(a []float64) float64 { return math.Min(a[0], a[1]) }},
+	"max":   {2, func(a []float64) float64 { return math.Max(a[0], a[1]) }},
+	"pow":   {2, func(a []float64) float64 { return math.Pow(math.Max(a[0], 0), a[1]) }},
+	"clamp": {3, func(a []float64) float64 { return math.Min(math.Max(a[0], a[1]), a[2]) }},
+	"mix":   {3, func(a []float64) float64 { return a[0] + (a[1]-a[0])*a[2] }},
+}
+
+// exprNode evaluates one node of a compiled look expression against the
+// variable slots.
+type exprNode func(vars []float64) float64
+
+// lookProgram is a compiled look expression: a list of assignments executed
+// in order. Slots 0-3 hold r, g, b, and luma; further slots are temporaries.
+type lookProgram struct {
+	stmts []lookAssign
+	slots int
+}
+
+type lookAssign struct {
+	slot int
+	expr exprNode
+}
+
+// apply runs the program on one display-encoded RGB value and clamps the result to [0,1].
+func (p *lookProgram) apply(r, g, b float64) (float64, float64, float64) {
+	vars := make([]float64, p.slots)
+	vars[0], vars[1], vars[2] = r, g, b
+	vars[3] = 0.2126*r + 0.7152*g + 0.0722*b
+	for _, s := range p.stmts {
+		vars[s.slot] = s.expr(vars)
+	}
+	clamp := func(v float64) float64 {
+		if math.IsNaN(v) {
+			return 0
+		}
+		return math.Min(math.Max(v, 0), 1)
+	}
+	return clamp(vars[0]), clamp(vars[1]), clamp(vars[2])
+}
+
+// compileLookExpr parses a look expression such as "r = r*1.1; b = b*0.9".
+// Statements are assignments separated by semicolons or newlines. Expressions
+// support numbers, + - * / and ** operators, parentheses, the variables r, g,
+// b, and luma (Rec.709 luminance of the input), temporaries assigned earlier
+// in the expression, and the functions in lookExprFuncs.
+func compileLookExpr(src string) (*lookProgram, error) {
+	if len(src) > maxLookExprLen {
+		return nil, fmt.Errorf("look_expr is longer than %d characters", maxLookExprLen)
+	}
+	toks, err := lexLookExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{
+		toks:  toks,
+		slots: map[string]int{"r": 0, "g": 1, "b": 2, "luma": 3},
+	}
+	prog, err := p.program()
+	if err != nil {
+		return nil, fmt.Errorf("look_expr: %w", err)
+	}
+	return prog, nil
+}
+
+type exprToken struct {
+	kind string // "num", "ident", "op", or "end"
+	text string
+	num  float64
+	pos  int
+}
+
+func lexLookExpr(src string) ([]exprToken, error) {
+	var toks []exprToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case c == '\n' || c == ';':
+			toks = append(toks, exprToken{kind: "op", text: ";", pos: i})
+			i++
+		case unicode.IsSpace(c):
+			i++
+		case unicode.IsDigit(c) || c == '.':
+			j := i
+			for j < len(src) && (unicode.IsDigit(rune(src[j])) || src[j] == '.') {
+				j++
+			}
+			if j < len(src) && (src[j] == 'e' || src[j] == 'E') {
+				j++
+				if j < len(src) && (src[j] == '+' || src[j] == '-') {
+					j++
+				}
+				for j < len(src) && unicode.IsDigit(rune(src[j])) {
+					j++
+				}
+			}
+			v, err := strconv.ParseFloat(src[i:j], 64)
+			if err != nil {
+				return nil, fmt.Errorf("look_expr: invalid number %q at offset %d", src[i:j], i)
+			}
+			toks = append(toks, exprToken{kind: "num", text: src[i:j], num: v, pos: i})
+			i = j
+		case unicode.IsLetter(c) || c == '_':
+			j := i
+			for j < len(src) && (unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j])) || src[j] == '_') {
+				j++
+			}
+			toks = append(toks, exprToken{kind: "ident", text: src[i:j], pos: i})
+			i = j
+		case strings.HasPrefix(src[i:], "**"):
+			toks = append(toks, exprToken{kind: "op", text: "**", pos: i})
+			i += 2
+		case strings.ContainsRune("+-*/(),=", c):
+			toks = append(toks, exprToken{kind: "op", text: string(c), pos: i})
+			i++
+		default:
+			return nil, fmt.Errorf("look_expr: unexpected character %q at offset %d", c, i)
+		}
+	}
+	return append(toks, exprToken{kind: "end", pos: len(src)}), nil
+}
+
+type exprParser struct {
+	toks  []exprToken
+	pos   int
+	nodes int
+	slots map[string]int
+}
+
+func (p *exprParser) peek() exprToken { return p.toks[p.pos] }
+
+func (p *exprParser) next() exprToken {
+	t := p.toks[p.pos]
+	if t.kind != "end" {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(text string) bool {
+	t := p.peek()
+	return t.kind == "op" && t.text == text
+}
+
+func (p *exprParser) expect(text string) error {
+	if !p.isOp(text) {
+		return p.errorf("expected %q", text)
+	}
+	p.next()
+	return nil
+}
+
+func (p *exprParser) errorf(format string, args ...any) error {
+	t := p.peek()
+	found := t.text
+	if t.kind == "end" {
+		found = "end of expression"
+	}
+	return fmt.Errorf("%s at offset %d (found %q)", fmt.Sprintf(format, args...), t.pos, found)
+}
+
+// node counts a new AST node against maxLookExprNodes.
+func (p *exprParser) node(n exprNode) (exprNode, error) {
+	p.nodes++
+	if p.nodes > maxLookExprNodes {
+		return nil, fmt.Errorf("expression exceeds %d operations", maxLookExprNodes)
+	}
+	return n, nil
+}
+
+func (p *exprParser) program() (*lookProgram, error) {
+	prog := &lookProgram{}
+	for {
+		for p.isOp(";") {
+			p.next()
+		}
+		if p.peek().kind == "end" {
+			break
+		}
+		name := p.next()
+		if name.kind != "ident" {
+			return nil, fmt.Errorf("expected a variable name at offset %d", name.pos)
+		}
+		if name.text == "luma" {
+			return nil, fmt.Errorf("luma is read-only (offset %d)", name.pos)
+		}
+		if _, ok := lookExprFuncs[name.text]; ok {
+			return nil, fmt.Errorf("cannot assign to function %s (offset %d)", name.text, name.pos)
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		expr, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		slot, ok := p.slots[name.text]
+		if !ok {
+			slot = len(p.slots)
+			p.slots[name.text] = slot
+		}
+		prog.stmts = append(prog.stmts, lookAssign{slot: slot, expr: expr})
+		if !p.isOp(";") && p.peek().kind != "end" {
+			return nil, p.errorf("expected ';' or newline")
+		}
+	}
+	if len(prog.stmts) == 0 {
+		return nil, fmt.Errorf("no assignments")
+	}
+	prog.slots = len(p.slots)
+	return prog, nil
+}
+
+// expr := term (('+' | '-') term)*
+func (p *exprParser) expr() (exprNode, error) {
+	left, err := p.term()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("+") || p.isOp("-") {
+		op := p.next().text
+		right, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "+" {
+			left, err = p.node(func(v []float64) float64 { return l(v) + right(v) })
+		} else {
+			left, err = p.node(func(v []float64) float64 { return l(v) - right(v) })
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// term := unary (('*' | '/') unary)*
+func (p *exprParser) term() (exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp("*") || p.isOp("/") {
+		op := p.next().text
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		if op == "*" {
+			left, err = p.node(func(v []float64) float64 { return l(v) * right(v) })
+		} else {
+			left, err = p.node(func(v []float64) float64 { return l(v) / right(v) })
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// unary := '-' unary | power
+func (p *exprParser) unary() (exprNode, error) {
+	if p.isOp("-") {
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return p.node(func(v []float64) float64 { return -operand(v) })
+	}
+	if p.isOp("+") {
+		p.next()
+		return p.unary()
+	}
+	return p.power()
+}
+
+// power := primary ['**' unary]
+func (p *exprParser) power() (exprNode, error) {
+	base, err := p.primary()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isOp("**") {
+		return base, nil
+	}
+	p.next()
+	exp, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	return p.node(func(v []float64) float64 { return math.Pow(math.Max(base(v), 0), exp(v)) })
+}
+
+// primary := number | variable | function '(' args ')' | '(' expr ')'
+func (p *exprParser) primary() (exprNode, error) {
+	t := p.peek()
+	switch {
+	case t.kind == "num":
+		p.next()
+		n := t.num
+		return p.node(func([]float64) float64 { return n })
+	case t.kind == "ident":
+		p.next()
+		if f, ok := lookExprFuncs[t.text]; ok {
+			return p.call(t, f.arity, f.fn)
+		}
+		slot, ok := p.slots[t.text]
+		if !ok {
+			return nil, fmt.Errorf("unknown variable %q at offset %d", t.text, t.pos)
+		}
+		return p.node(func(v []float64) float64 { return v[slot] })
+	case p.isOp("("):
+		p.next()
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return inner, nil
+	}
+	return nil, p.errorf("expected a number, variable, or '('")
+}
+
+func (p *exprParser) call(name exprToken, arity int, fn func([]float64) float64) (exprNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []exprNode
+	for !p.isOp(")") {
+		if len(args) > 0 {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	p.next()
+	if len(args) != arity {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d (offset %d)", name.text, arity, len(args), name.pos)
+	}
+	return p.node(func(v []float64) float64 {
+		vals := make([]float64, len(args))
+		for i, a := range args {
+			vals[i] = a(v)
+		}
+		return fn(vals)
+	})
+}
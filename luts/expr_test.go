@@ -0,0 +1,62 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+// warmVintageExpr is the warmVintage look at full strength with its default
+// params, written as a look expression.
+const warmVintageExpr = "r = min(r*1.05*0.9 + 0.05, 1); g = g*0.9 + 0.05; b = b*0.95*0.9 + 0.05"
+
+func TestLookExprReproducesWarmVintage(t *testing.T) {
+	prog, err := compileLookExpr(warmVintageExpr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, in := range [][3]float64{{0, 0, 0}, {0.2, 0.4, 0.6}, {0.5, 0.5, 0.5}, {0.99, 0.7, 0.1}, {1, 1, 1}} {
+		r, g, b := prog.apply(in[0], in[1], in[2])
+		wr, wg, wb := ApplyWarmVintage(in[0], in[1], in[2], 1)
+		if got, want := [3]float64{r, g, b}, [3]float64{wr, wg, wb}; !nearRGB(got, want, 1e-12) {
+			t.Errorf("%v: expression gives %v, warmVintage %v", in, got, want)
+		}
+	}
+
+	expr := defaultConfig(t, func(c *Config) { c.LookExpr = warmVintageExpr })
+	look := defaultConfig(t, func(c *Config) { c.Look = "warmVintage" })
+	for _, in := range [][3]float64{{0.1, 0.2, 0.3}, {0.6, 0.5, 0.4}} {
+		r, g, b := processPixel(expr, in[0], in[1], in[2])
+		wr, wg, wb := processPixel(look, in[0], in[1], in[2])
+		if got, want := [3]float64{r, g, b}, [3]float64{wr, wg, wb}; !nearRGB(got, want, 1e-12) {
+			t.Errorf("%v: look_expr config gives %v, warmVintage config %v", in, got, want)
+		}
+	}
+}
+
+func TestLookExprTemporariesAndFunctions(t *testing.T) {
+	prog, err := compileLookExpr("k = clamp(luma, 0, 1)\nr = mix(r, k, 0.5); g = sqrt(g); b = 2 ** -1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, g, b := prog.apply(0.2, 0.25, 0.8)
+	luma := 0.2126*0.2 + 0.7152*0.25 + 0.0722*0.8
+	if want := [3]float64{0.2 + (luma-0.2)*0.5, 0.5, 0.5}; !nearRGB([3]float64{r, g, b}, want, 1e-12) {
+		t.Errorf("got %v, want %v", [3]float64{r, g, b}, want)
+	}
+}
+
+func TestLookExprRejected(t *testing.T) {
+	for _, src := range []string{
+		"r = ",
+		"r = q * 2",
+		"r = min(r)",
+		"r = os.Exit(1)",
+		"r = (g",
+		strings.Repeat("r = r + 1; ", 500), // Too long
+		"r = " + strings.Repeat("1+", 1500) + "1", // Too many nodes
+	} {
+		if _, err := compileLookExpr(src); err == nil {
+			t.Errorf("compileLookExpr(%.40q) succeeded", src)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Gamut compression parameters, after the ACES reference gamut compression.
+// Distances from the achromatic axis are measured as a fraction of the
+// largest channel: 0 is neutral, 1 is on the gamut boundary, and anything
+// above 1 has a negative channel.
+const (
+	gamutThreshold = 0.8 // Distances below this are left untouched
+	gamutLimit     = 1.3 // Distance that is compressed exactly onto the boundary
+	gamutPower     = 1.2 // Curve aggressiveness between threshold and limit
+)
+
+// rec2020ToRec709Linear applies the Rec2020ToRec709 matrix without clipping.
+func rec2020ToRec709Linear(r, g, b float64) (float64, float64, float64) {
+	return multiplyMatrix(matRec2020ToRec709, r, g, b)
+}
+
+// rec709Matrix returns the Rec.2020 to Rec.709 linear matrix: Matrix when
+// set, otherwise the built-in one.
+func (c *Config) rec709Matrix() [9]float64 {
+	if len(c.Matrix) != 9 {
+		return matRec2020ToRec709
+	}
+	return [9]float64(c.Matrix)
+}
+
+// matrixRowTolerance is how far a row of a custom matrix may sum from 1.0
+// before MatrixWarnings reports it.
+const matrixRowTolerance = 0.01
+
+// MatrixWarnings reports rows of cfg.Matrix that do not sum to roughly 1.0.
+// A matrix between two white-point-matched spaces maps white to white, so
+// such rows usually mean the coefficients were normalized wrongly.
+func MatrixWarnings(cfg Config) []string {
+	if len(cfg.Matrix) != 9 {
+		return nil
+	}
+	var warnings []string
+	for row := 0; row < 3; row++ {
+		sum := cfg.Matrix[3*row] + cfg.Matrix[3*row+1] + cfg.Matrix[3*row+2]
+		if math.Abs(sum-1) > matrixRowTolerance {
+			warnings = append(warnings, fmt.Sprintf("matrix row %d sums to %.4f, not 1.0; check its normalization", row+1, sum))
+		}
+	}
+	return warnings
+}
+
+// Plausibility limits for a custom matrix. Conversions between real RGB
+// spaces have coefficients of a few units at most and a determinant near 1,
+// so anything beyond these limits is almost certainly a typo.
+const (
+	maxMatrixCoefficient = 10.0
+	minMatrixDeterminant = 1e-3
+)
+
+// validateMatrix checks that a custom Matrix has 9 coefficients, none of them
+// implausibly large, and is far enough from singular to be inverted reliably.
+// It also rejects a Matrix the conversion would not use: only Rec.2020 input
+// converted to a target with Rec.709 primaries goes through it.
+func (c *Config) validateMatrix() error {
+	if len(c.Matrix) == 0 {
+		return nil
+	}
+	if len(c.Matrix) != 9 {
+		return fmt.Errorf("matrix must have 9 values (row-major 3x3), got %d", len(c.Matrix))
+	}
+	if t, _ := c.displayTarget(); t.Primaries != "rec709" {
+		return fmt.Errorf("matrix replaces the Rec.2020 to Rec.709 conversion and cannot be used with a target whose primaries are %s", t.Primaries)
+	}
+	if strings.EqualFold(c.InputEncoding, "srgb") {
+		return fmt.Errorf("matrix cannot be used with input_encoding srgb, whose Rec.709 primaries need no conversion")
+	}
+	for i, v := range c.Matrix {
+		if !(math.Abs(v) <= maxMatrixCoefficient) {
+			return fmt.Errorf("matrix value %d (row %d, column %d) is %g; coefficients beyond ±%g are implausible", i+1, i/3+1, i%3+1, v, maxMatrixCoefficient)
+		}
+	}
+	if det := matrixDeterminant([9]float64(c.Matrix)); math.Abs(det) < minMatrixDeterminant {
+		return fmt.Errorf("matrix is singular or nearly so (determinant %g); check for repeated or zero rows", det)
+	}
+	return nil
+}
+
+// matrixDeterminant returns the determinant of the row-major 3x3 matrix m.
+func matrixDeterminant(m [9]float64) float64 {
+	return m[0]*(m[4]*m[8]-m[5]*m[7]) - m[1]*(m[3]*m[8]-m[5]*m[6]) + m[2]*(m[3]*m[7]-m[4]*m[6])
+}
+
+// invertMatrix returns the inverse of the row-major 3x3 matrix m, or false
+// if m is singular.
+func invertMatrix(m [9]float64) ([9]float64, bool) {
+	det := matrixDeterminant(m)
+	if det == 0 {
+		return [9]float64{}, false
+	}
+	return [9]float64{
+		(m[4]*m[8] - m[5]*m[7]) / det, (m[2]*m[7] - m[1]*m[8]) / det, (m[1]*m[5] - m[2]*m[4]) / det,
+		(m[5]*m[6] - m[3]*m[8]) / det, (m[0]*m[8] - m[2]*m[6]) / det, (m[2]*m[3] - m[0]*m[5]) / det,
+		(m[3]*m[7] - m[4]*m[6]) / det, (m[1]*m[6] - m[0]*m[7]) / det, (m[0]*m[4] - m[1]*m[3]) / det,
+	}, true
+}
+
+// multiplyMatrix multiplies linear RGB by the row-major 3x3 matrix m.
+func multiplyMatrix(m [9]float64, r, g, b float64) (float64, float64, float64) {
+	return m[0]*r + m[1]*g + m[2]*b,
+		m[3]*r + m[4]*g + m[5]*b,
+		m[6]*r + m[7]*g + m[8]*b
+}
+
+// preserveHue brings out-of-gamut linear RGB back into range while keeping
+// its hue: negative channels are lifted by desaturating toward the gray of
+// the same luminance (weighted by weights), and a channel above 1.0 scales
+// all three down together, so the ratios between them and the hue are kept
+// while luminance drops. Unbounded targets skip the scaling.
+func preserveHue(r, g, b float64, weights [3]float64, unbounded bool) (float64, float64, float64) {
+	if lo := min(r, g, b); lo < 0 {
+		y := luma(weights, r, g, b)
+		if y <= 0 {
+			return 0, 0, 0
+		}
+		t := y / (y - lo)
+		r, g, b = y+t*(r-y), y+t*(g-y), y+t*(b-y)
+	}
+	if hi := max(r, g, b); hi > 1 && !unbounded {
+		r, g, b = r/hi, g/hi, b/hi
+	}
+	return max(r, 0), max(g, 0), max(b, 0)
+}
+
+// compressGamut brings out-of-gamut linear RGB back into [0,1] without
+// clamping channels independently. Colors are pulled toward the achromatic
+// axis along a smooth curve, so saturated colors keep a gradient instead of
+// flattening at the boundary, and values above 1.0 are scaled down as a whole
+// so highlights keep their hue. Unbounded targets keep values above 1.0.
+func compressGamut(r, g, b float64, unbounded bool) (float64, float64, float64) {
+	ach := max(r, g, b)
+	if ach <= 0 {
+		return 0, 0, 0
+	}
+	scale := (gamutLimit - gamutThreshold) /
+		math.Pow(math.Pow((1-gamutThreshold)/(gamutLimit-gamutThreshold), -gamutPower)-1, 1/gamutPower)
+	compress := func(c float64) float64 {
+		d := (ach - c) / ach
+		if d > gamutThreshold {
+			x := (d - gamutThreshold) / scale
+			d = gamutThreshold + scale*x/math.Pow(1+math.Pow(x, gamutPower), 1/gamutPower)
+		}
+		return ach - d*ach
+	}
+	r, g, b = compress(r), compress(g), compress(b)
+	if unbounded {
+		return max(r, 0), max(g, 0), max(b, 0)
+	}
+	if ach > 1 {
+		r, g, b = r/ach, g/ach, b/ach
+	}
+	clip := func(v float64) float64 { return math.Min(math.Max(v, 0), 1) }
+	return clip(r), clip(g), clip(b)
+}
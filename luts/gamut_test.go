@@ -0,0 +1,159 @@
+package luts
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompressGamutUnboundedKeepsSuperWhites(t *testing.T) {
+	r, g, b := compressGamut(1.8, 1.8, 1.8, true)
+	if r != 1.8 || g != 1.8 || b != 1.8 {
+		t.Errorf("unbounded neutral 1.8 = %g %g %g, want it unchanged", r, g, b)
+	}
+	r, g, b = compressGamut(1.8, 1.8, 1.8, false)
+	if r != 1 || g != 1 || b != 1 {
+		t.Errorf("bounded neutral 1.8 = %g %g %g, want 1 1 1", r, g, b)
+	}
+}
+
+func TestACEScctCompressMatchesClipAtWhite(t *testing.T) {
+	top := func(mapping string) [3]float64 {
+		cfg := defaultConfig(t, func(c *Config) {
+			c.TargetColorSpace, c.GamutMapping, c.DomainMax, c.Size = "acescct", mapping, 1.5, 9
+		})
+		cube, _ := BuildCube(cfg)
+		return cube.Data[len(cube.Data)-1]
+	}
+	compress, clip := top("compress"), top("clip")
+	for c := range compress {
+		if !near(compress[c], clip[c], 1e-9) {
+			t.Errorf("channel %d: compress %g, clip %g", c, compress[c], clip[c])
+		}
+	}
+}
+
+func TestPreserveHueKeepsRatios(t *testing.T) {
+	r, g, b := preserveHue(1.5, 0.6, 0.3, lumaWeights["rec709"], false)
+	if r != 1 || !near(g/r, 0.4, 1e-12) || !near(b/r, 0.2, 1e-12) {
+		t.Errorf("preserveHue(1.5, 0.6, 0.3) = %g %g %g, want ratios 1:0.4:0.2 with red at 1", r, g, b)
+	}
+}
+
+func TestClipModeOutOfGamut(t *testing.T) {
+	build := func(edit func(c *Config)) *Cube {
+		cfg := defaultConfig(t, func(c *Config) {
+			c.Size = 9
+			edit(c)
+		})
+		cube, _ := BuildCube(cfg)
+		return cube
+	}
+	perChannel := build(func(c *Config) { c.ClipMode = "perChannel" })
+	preserve := build(func(c *Config) { c.ClipMode = "preserveHue" })
+	if clip := build(func(c *Config) {}); !slices.Equal(perChannel.Data, clip.Data) {
+		t.Error("clip_mode perChannel differs from the default per-channel clip")
+	}
+	if mapped := build(func(c *Config) { c.GamutMapping = "preserveHue" }); !slices.Equal(preserve.Data, mapped.Data) {
+		t.Error("clip_mode preserveHue differs from gamut_mapping preserveHue")
+	}
+
+	// Saturated Rec.2020 red leaves Rec.709 with green and blue below zero
+	// and red above one; the two modes bring it back in different ways.
+	node := perChannel.index(8, 2, 2)
+	hard, hue := perChannel.Data[node], preserve.Data[node]
+	if nearRGB(hard, hue, 1e-6) {
+		t.Errorf("out-of-gamut red: perChannel %v and preserveHue %v agree", hard, hue)
+	}
+	if hard[0] != 1 {
+		t.Errorf("out-of-gamut red: perChannel red %g, want it clipped to 1", hard[0])
+	}
+
+	for _, tc := range []struct{ mode, mapping, want string }{
+		{"perChannel", "compress", `clip_mode "perChannel" conflicts with gamut_mapping "compress"`},
+		{"preserveHue", "clip", `clip_mode "preserveHue" conflicts with gamut_mapping "clip"`},
+		{"hue", "", `unknown clip_mode "hue"`},
+	} {
+		cfg := Config{ClipMode: tc.mode, GamutMapping: tc.mapping}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("clip_mode %s with gamut_mapping %q: error %v, want %q", tc.mode, tc.mapping, err, tc.want)
+		}
+	}
+}
+
+func TestValidateRejectsUnusedMatrix(t *testing.T) {
+	m := matRec2020ToRec709[:]
+	for _, tc := range []struct {
+		name string
+		edit func(c *Config)
+		ok   bool
+	}{
+		{"rec709", func(c *Config) {}, true},
+		{"srgb target", func(c *Config) { c.Target = "srgb" }, true},
+		{"p3d65", func(c *Config) { c.Target = "p3d65" }, false},
+		{"appleReference", func(c *Config) { c.Target = "appleReference" }, false},
+		{"acescct", func(c *Config) { c.TargetColorSpace = "acescct" }, false},
+		{"srgb input", func(c *Config) { c.InputEncoding = "srgb" }, false},
+	} {
+		var cfg Config
+		cfg.Matrix = append([]float64(nil), m...)
+		tc.edit(&cfg)
+		cfg.SetDefaults()
+		if err := cfg.Validate(); (err == nil) != tc.ok {
+			t.Errorf("%s: Validate() = %v, want ok %v", tc.name, err, tc.ok)
+		}
+	}
+}
+
+func TestSchemaBoundsMatrixLength(t *testing.T) {
+	matrix := ConfigSchema()["properties"].(map[string]any)["matrix"].(map[string]any)
+	if matrix["minItems"] != 9 || matrix["maxItems"] != 9 {
+		t.Errorf("matrix schema %v, want exactly 9 items", matrix)
+	}
+}
+
+func TestCompressKeepsSaturatedRedOffTheBoundary(t *testing.T) {
+	target := displayTargets["rec709"]
+	convert := func(mapping string, r, g, b float64) [3]float64 {
+		cfg := defaultConfig(t, func(c *Config) { c.GamutMapping = mapping })
+		r, g, b = convertGamut(cfg, target, r, g, b)
+		return [3]float64{r, g, b}
+	}
+	clipped, compressed := convert("clip", 0.7, 0.02, 0.01), convert("compress", 0.7, 0.02, 0.01)
+	if clipped[1] != 0 || clipped[2] != 0 {
+		t.Fatalf("clip: saturated red = %v, want green and blue clipped to 0", clipped)
+	}
+	for c, v := range compressed {
+		if v <= 0 || v > 1 {
+			t.Errorf("compress: channel %d of saturated red = %g, want in (0, 1]", c, v)
+		}
+	}
+	// Two reds that clip to the same green keep distinct values when compressed.
+	if a, b := convert("compress", 0.7, 0.01, 0.01), convert("compress", 0.7, 0.03, 0.01); a[1] == b[1] {
+		t.Errorf("compress: green %g for both reds, want a gradient", a[1])
+	}
+}
+
+func TestValidateRejectsSingularMatrix(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		matrix []float64
+		want   string
+	}{
+		{"repeated row", []float64{1, 0, 0, 1, 0, 0, 0, 0, 1}, "matrix is singular or nearly so (determinant 0)"},
+		{"zero row", []float64{1, 0, 0, 0, 0, 0, 0, 0, 1}, "matrix is singular or nearly so (determinant 0)"},
+		{"nearly singular", []float64{1, 0, 0, 0, 1, 0, 0, 0, 1e-4}, "matrix is singular or nearly so (determinant 0.0001)"},
+	} {
+		cfg := Config{Matrix: tc.matrix}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: Validate() = %v, want an error containing %q", tc.name, err, tc.want)
+		}
+	}
+	cfg := Config{Matrix: []float64{1, 0, 0, 0, 1, 0, 0, 0, 1}}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("identity matrix: %v", err)
+	}
+}
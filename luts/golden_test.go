@@ -0,0 +1,74 @@
+package luts
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden from the current output")
+
+// TestGolden generates every config in testdata/golden and compares the
+// result byte for byte with the .cube file of the same name. Run
+// "go test ./luts -run TestGolden -update" after an intended output change
+// and commit the rewritten files with it.
+func TestGolden(t *testing.T) {
+	configs, err := filepath.Glob(filepath.Join("testdata", "golden", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) == 0 {
+		t.Fatal("no golden configs in testdata/golden")
+	}
+	for _, path := range configs {
+		name := strings.TrimSuffix(filepath.Base(path), ".json")
+		t.Run(name, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var cfg Config
+			if err := json.Unmarshal(data, &cfg); err != nil {
+				t.Fatalf("parsing config: %v", err)
+			}
+			lut, err := Generate(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			golden := strings.TrimSuffix(path, ".json") + ".cube"
+			if *update {
+				if err := os.WriteFile(golden, []byte(lut), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal([]byte(lut), want) {
+				got := strings.Split(lut, "\n")
+				for n, line := range strings.Split(string(want), "\n") {
+					if n >= len(got) || got[n] != line {
+						t.Fatalf("output differs from %s at line %d: got %q, want %q (run with -update if the change is intended)",
+							golden, n+1, lineAt(got, n), line)
+					}
+				}
+				t.Fatalf("output differs from %s: %d lines, want %d (run with -update if the change is intended)",
+					golden, len(got), strings.Count(string(want), "\n")+1)
+			}
+		})
+	}
+}
+
+// lineAt returns lines[n], or "" past the end.
+func lineAt(lines []string, n int) string {
+	if n < len(lines) {
+		return lines[n]
+	}
+	return ""
+}
@@ -0,0 +1,77 @@
+package luts
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"math"
+)
+
+// HaldLevel returns the HALD CLUT level closest to a cube of the given size.
+// A level L image holds a cube of L*L nodes per axis in a square of L*L*L
+// pixels per side.
+func HaldLevel(size int) int {
+	return max(2, int(math.Round(math.Sqrt(float64(size)))))
+}
+
+// RenderHald renders the conversion into a HALD CLUT: the identity HALD image
+// of the level derived from cfg.Size, with every pixel replaced by the
+// processed color of its identity coordinate. Red steps fastest along each
+// row, then green, then blue. Pixels are stored with 16 bits per channel.
+func RenderHald(cfg Config) *image.NRGBA64 {
+	level := HaldLevel(cfg.Size)
+	cubeCfg := cfg
+	cubeCfg.Size = level * level
+	cube, _ := BuildCube(cubeCfg)
+
+	n := cubeCfg.Size
+	side := level * level * level
+	img := image.NewNRGBA64(image.Rect(0, 0, side, side))
+	to16 := func(v float64) uint16 {
+		return uint16(math.Round(math.Min(math.Max(v, 0), 1) * 0xffff))
+	}
+	for b := 0; b < n; b++ {
+		for g := 0; g < n; g++ {
+			for r := 0; r < n; r++ {
+				p := (b*n+g)*n + r
+				v := cube.Data[cube.index(r, g, b)]
+				img.SetNRGBA64(p%side, p/side, color.NRGBA64{R: to16(v[0]), G: to16(v[1]), B: to16(v[2]), A: 0xffff})
+			}
+		}
+	}
+	return img
+}
+
+// formatHald returns cfg's HALD CLUT encoded as PNG.
+func formatHald(cfg Config) (string, error) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, RenderHald(cfg)); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// haldNodes decodes a HALD CLUT PNG into cube nodes with red as the slowest
+// axis, as ValidateLUT expects, and returns them with the cube size.
+func haldNodes(data string) ([][3]float64, int, error) {
+	img, err := png.Decode(bytes.NewReader([]byte(data)))
+	if err != nil {
+		return nil, 0, fmt.Errorf("decoding HALD image: %w", err)
+	}
+	bounds := img.Bounds()
+	side := bounds.Dx()
+	level := int(math.Round(math.Cbrt(float64(side))))
+	if bounds.Dy() != side || level*level*level != side {
+		return nil, 0, fmt.Errorf("HALD image is %dx%d, not a square of a level cubed", side, bounds.Dy())
+	}
+	n := level * level
+	nodes := make([][3]float64, n*n*n)
+	for p := range nodes {
+		r, g, b := p%n, (p/n)%n, p/(n*n)
+		c := color.NRGBA64Model.Convert(img.At(bounds.Min.X+p%side, bounds.Min.Y+p/side)).(color.NRGBA64)
+		nodes[(r*n+g)*n+b] = [3]float64{float64(c.R) / 0xffff, float64(c.G) / 0xffff, float64(c.B) / 0xffff}
+	}
+	return nodes, n, nil
+}
@@ -0,0 +1,42 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHaldLevel(t *testing.T) {
+	for _, tc := range []struct{ size, want int }{{2, 2}, {4, 2}, {9, 3}, {16, 4}, {17, 4}, {33, 6}, {64, 8}, {65, 8}} {
+		if got := HaldLevel(tc.size); got != tc.want {
+			t.Errorf("HaldLevel(%d) = %d, want %d", tc.size, got, tc.want)
+		}
+	}
+}
+
+func TestHaldRoundTrip(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Size, c.Look = 16, "tealOrange" })
+	if b := RenderHald(cfg).Bounds(); b.Dx() != 64 || b.Dy() != 64 {
+		t.Errorf("level 4 HALD image is %dx%d, want 64x64", b.Dx(), b.Dy())
+	}
+	png, err := formatHald(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nodes, size, err := haldNodes(png)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cube, _ := BuildCube(cfg)
+	if size != cube.Size || len(nodes) != len(cube.Data) {
+		t.Fatalf("decoded %d nodes of size %d, want %d of size %d", len(nodes), size, len(cube.Data), cube.Size)
+	}
+	for n := range nodes {
+		if !nearRGB(nodes[n], cube.Data[n], 0.5/0xffff) {
+			t.Fatalf("node %d decoded as %v, want %v to 16 bits", n, nodes[n], cube.Data[n])
+		}
+	}
+
+	if _, _, err := haldNodes("not a png"); err == nil || !strings.Contains(err.Error(), "decoding HALD image") {
+		t.Errorf("non-PNG data: error %v", err)
+	}
+}
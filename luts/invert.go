@@ -0,0 +1,77 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// matRec709ToRec2020 is the inverse of the built-in Rec2020ToRec709 matrix.
+var matRec709ToRec2020 = [9]float64{
+	0.627471, 0.329203, 0.043326,
+	0.068848, 0.920072, 0.011079,
+	0.016261, 0.087597, 0.896143,
+}
+
+// rec709InverseOETF decodes a Rec.709 encoded value to linear light.
+func rec709InverseOETF(v float64) float64 {
+	if v < 4.5*0.018 {
+		return v / 4.5
+	}
+	return math.Pow((v+0.099)/1.099, 1/0.45)
+}
+
+// linearToAppleLog is the inverse of appleLogDecode, encoding linear light
+// with the same approximate curve.
+func linearToAppleLog(linear float64) float64 {
+	return math.Pow(math.Max(linear, 0), 1/1.5)
+}
+
+// invertPixel runs a Rec.709 display value back through the base conversion
+// in reverse: the inverse Rec.709 OETF, the Rec.709 to Rec.2020 matrix (the
+// inverse of Matrix when set), and the Apple Log encode, undoing the exposure
+// offset and then the exposure stops (with NormalizeExposure, the
+// highlight rolloff and then both exposures in linear light). The result is
+// clipped to [0,1]; display values outside what the forward conversion can
+// produce have no exact inverse.
+func invertPixel(cfg Config, r, g, b float64) (float64, float64, float64) {
+	m := matRec709ToRec2020
+	if len(cfg.Matrix) == 9 {
+		m, _ = invertMatrix(cfg.rec709Matrix()) // Validate rejects singular matrices
+	}
+	linR, linG, linB := multiplyMatrix(m,
+		rec709InverseOETF(r), rec709InverseOETF(g), rec709InverseOETF(b))
+	encode := func(v float64) float64 {
+		if cfg.NormalizeExposure {
+			start, span, a := exposureRolloff(cfg)
+			return math.Min(math.Max(linearToAppleLog(unrollHighlight(v, start, span, a)/exposureGain(cfg)), 0), 1)
+		}
+		if cfg.ExposureStops != 0 {
+			v = appleLogDecode(linearToAppleLog(v)/cfg.ExposureOffset) / math.Exp2(cfg.ExposureStops)
+			return math.Min(math.Max(linearToAppleLog(v), 0), 1)
+		}
+		return math.Min(math.Max(linearToAppleLog(v)/cfg.ExposureOffset, 0), 1)
+	}
+	return encode(linR), encode(linG), encode(linB)
+}
+
+// validateInvert checks that an inverted config only uses stages that
+// invertPixel can reverse.
+func (c *Config) validateInvert() error {
+	if !c.Invert {
+		return nil
+	}
+	if t, _ := c.displayTarget(); t.Primaries != "rec709" || t.Transfer != "rec709" {
+		return fmt.Errorf("invert requires the rec709 target")
+	}
+	if !strings.EqualFold(c.InputEncoding, "appleLog") {
+		return fmt.Errorf("invert requires the appleLog input encoding")
+	}
+	if !strings.EqualFold(c.Look, "none") || c.ZoneLooks.enabled() || c.LookExpr != "" || c.LookPair || c.curvesEnabled() || c.Contrast != nil {
+		return fmt.Errorf("invert cannot be combined with creative looks, RGB curves, or contrast")
+	}
+	if !strings.EqualFold(c.ToneMap, "none") || c.KneeStart < 1 || c.BlackPoint != 0 || c.WhitePoint != 1 {
+		return fmt.Errorf("invert cannot be combined with tone_map, knee_start, black_point, or white_point")
+	}
+	return nil
+}
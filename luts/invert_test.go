@@ -0,0 +1,18 @@
+package luts
+
+import "testing"
+
+func TestInverseCubeUndoesForward(t *testing.T) {
+	forward, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size = 33 }))
+	inverse, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.Invert = 33, true }))
+	composed := ComposeCubes(forward, inverse)
+	for _, in := range [][3]float64{{0.1, 0.1, 0.1}, {0.3, 0.25, 0.2}, {0.5, 0.55, 0.6}, {0.8, 0.75, 0.7}, {0.95, 0.95, 0.95}} {
+		v := forward.sample(in[0], in[1], in[2])
+		if got := inverse.sample(v[0], v[1], v[2]); !nearRGB(got, in, 1e-3) {
+			t.Errorf("inverse of forward %v = %v", in, got)
+		}
+		if got := composed.sample(in[0], in[1], in[2]); !nearRGB(got, in, 1e-3) {
+			t.Errorf("composed forward and inverse map %v to %v", in, got)
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// LookFunc transforms one display-encoded RGB value. The config supplies any
+// tunables the look reads, such as LookStrength, LookIntensity, and Params.
+type LookFunc func(cfg Config, r, g, b float64) (float64, float64, float64)
+
+// lookDef is a registered creative look.
+type lookDef struct {
+	Name    string             // Display name, e.g. "tealOrange"
+	Apply   LookFunc           // Applies the look
+	Inverse LookFunc           // Exact analytic inverse of Apply, or nil if there is none
+	Params  map[string]float64 // Tunables the look reads from Config.Params, with their defaults
+}
+
+// Default parameters of the built-in looks, as read through Config.param.
+var (
+	tealOrangeParams = map[string]float64{
+		"shadow_blue_boost": 0.1, // Blue added to shadows, as a fraction of the channel
+		"highlight_warmth":  0.1, // Red added to highlights, as a fraction of the channel
+	}
+	warmVintageParams = map[string]float64{
+		"warmth": 0.05, // Red added and blue removed, as a fraction of the channel
+		"fade":   0.1,  // Share of the blend toward mid-gray, 0..1 exclusive
+	}
+	filmPrintParams = map[string]float64{
+		"density": 1.0, // Depth of the density curves; 0 leaves only the channel coupling
+	}
+)
+
+var (
+	lookRegistry = map[string]lookDef{} // Keyed by lowercased name
+	lookOrder    []string               // Display names in registration order
+)
+
+// registerLook adds a look to the registry under name (matched
+// case-insensitively). params declares the Config.Params entries the look
+// reads, with their defaults, and may be nil. inverse may be nil when the
+// look has no analytic inverse.
+func registerLook(name string, params map[string]float64, apply, inverse LookFunc) {
+	key := strings.ToLower(name)
+	if _, exists := lookRegistry[key]; !exists {
+		lookOrder = append(lookOrder, name)
+	}
+	lookRegistry[key] = lookDef{Name: name, Apply: apply, Inverse: inverse, Params: params}
+}
+
+// param returns Params[name], or the entry of defaults when it is unset.
+func (c *Config) param(name string, defaults map[string]float64) float64 {
+	if v, ok := c.Params[name]; ok {
+		return v
+	}
+	return defaults[name]
+}
+
+// lookParamNames returns the sorted names of the parameters declared by all
+// registered looks.
+func lookParamNames() []string {
+	var names []string
+	for _, l := range lookRegistry {
+		for name := range l.Params {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validateParams rejects Params entries that no registered look reads, so a
+// misspelled name is not silently ignored, and values that are not finite.
+// Entries for looks other than Look are allowed, as zone looks may read them.
+func (c *Config) validateParams() error {
+	valid := lookParamNames()
+	for name, v := range c.Params {
+		i := sort.SearchStrings(valid, name)
+		if i == len(valid) || valid[i] != name {
+			return fmt.Errorf("unknown params entry %q (valid: %s)", name, strings.Join(valid, ", "))
+		}
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("params %s must be finite, got %g", name, v)
+		}
+	}
+	if fade := c.param("fade", warmVintageParams); fade < 0 || fade >= 1 {
+		return fmt.Errorf("params fade must be in [0, 1), got %g", fade)
+	}
+	return nil
+}
+
+// findLook returns the registered look with the given name.
+func findLook(name string) (lookDef, bool) {
+	l, ok := lookRegistry[strings.ToLower(name)]
+	return l, ok
+}
+
+// LookNames returns the names of all registered looks in registration order.
+func LookNames() []string {
+	return append([]string(nil), lookOrder...)
+}
+
+func init() {
+	identity := func(_ Config, r, g, b float64) (float64, float64, float64) { return r, g, b }
+	registerLook("none", nil, identity, identity)
+	registerLook("tealOrange", tealOrangeParams, func(cfg Config, r, g, b float64) (float64, float64, float64) {
+		return applyTealOrange(r, g, b, cfg.lookStrength(), cfg.TealOrangePivot, cfg.TealOrangeWidth, cfg.lumaWeights(),
+			cfg.param("shadow_blue_boost", tealOrangeParams), cfg.param("highlight_warmth", tealOrangeParams))
+	}, nil)
+	registerLook("warmVintage", warmVintageParams, func(cfg Config, r, g, b float64) (float64, float64, float64) {
+		return applyWarmVintage(r, g, b, cfg.lookStrength(), cfg.param("warmth", warmVintageParams), cfg.param("fade", warmVintageParams))
+	}, func(cfg Config, r, g, b float64) (float64, float64, float64) {
+		return invertWarmVintage(r, g, b, cfg.lookStrength(), cfg.param("warmth", warmVintageParams), cfg.param("fade", warmVintageParams))
+	})
+	registerLook("bleachBypass", nil, func(cfg Config, r, g, b float64) (float64, float64, float64) {
+		return ApplyBleachBypass(r, g, b, cfg.LookIntensity*cfg.lookStrength(), cfg.lumaWeights())
+	}, nil)
+	registerLook("filmPrint", filmPrintParams, func(cfg Config, r, g, b float64) (float64, float64, float64) {
+		return applyFilmPrint(r, g, b, cfg.lookStrength(), cfg.param("density", filmPrintParams))
+	}, nil)
+}
+
+// Numeric look inversion settings.
+const lookInverseIterations = 64
+
+// invertLookNumeric approximates the input that fn maps to (r, g, b) by
+// fixed-point iteration, for looks that do not provide an analytic inverse.
+// It converges for looks that stay close to identity and clamps to [0,1].
+func invertLookNumeric(fn LookFunc, cfg Config, r, g, b float64) (float64, float64, float64) {
+	clamp := func(v float64) float64 { return math.Min(math.Max(v, 0), 1) }
+	xr, xg, xb := r, g, b
+	for i := 0; i < lookInverseIterations; i++ {
+		fr, fg, fb := fn(cfg, xr, xg, xb)
+		xr = clamp(xr + (r - fr))
+		xg = clamp(xg + (g - fg))
+		xb = clamp(xb + (b - fb))
+	}
+	return xr, xg, xb
+}
+
+// buildLookCube applies fn alone to an identity grid of cfg.Size, giving a
+// display-to-display LUT of just the look.
+func buildLookCube(cfg Config, fn LookFunc) *Cube {
+	size := cfg.Size
+	cube := &Cube{Size: size, Data: make([][3]float64, 0, size*size*size)}
+	for i := 0; i < size; i++ {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				r, g, b := fn(cfg, float64(i)/float64(size-1), float64(j)/float64(size-1), float64(k)/float64(size-1))
+				cube.Data = append(cube.Data, [3]float64{r, g, b})
+			}
+		}
+	}
+	return cube
+}
+
+// pairLook returns the look written by look_pair, falling back to "none"
+// for an unknown name.
+func pairLook(cfg Config) lookDef {
+	l, ok := findLook(cfg.Look)
+	if !ok {
+		l, _ = findLook("none")
+	}
+	return l
+}
+
+// GenerateLookPair creates a LUT of the config's look alone and a LUT of its
+// inverse, so the look can be applied and later removed. Looks without an
+// analytic inverse fall back to numeric inversion, reported by numeric.
+func GenerateLookPair(cfg Config) (lookLUT, inverseLUT string, numeric bool) {
+	l := pairLook(cfg)
+	inverse := l.Inverse
+	if inverse == nil {
+		numeric = true
+		inverse = func(cfg Config, r, g, b float64) (float64, float64, float64) {
+			return invertLookNumeric(l.Apply, cfg, r, g, b)
+		}
+	}
+	inverseCfg := cfg
+	inverseCfg.Title = cubeTitle(cfg) + " inverse"
+	return FormatCube(cfg, buildLookCube(cfg, l.Apply)),
+		FormatCube(inverseCfg, buildLookCube(cfg, inverse)),
+		numeric
+}
@@ -0,0 +1,258 @@
+package luts
+
+import (
+	"math"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// nearRGB reports whether each channel of got is within tol of want.
+func nearRGB(got, want [3]float64, tol float64) bool {
+	for c := range got {
+		if !near(got[c], want[c], tol) {
+			return false
+		}
+	}
+	return true
+}
+
+func TestBleachBypassPinned(t *testing.T) {
+	weights := lumaWeights["rec709"]
+	for _, tc := range []struct {
+		in, want  [3]float64
+		intensity float64
+	}{
+		{[3]float64{0.5, 0.5, 0.5}, [3]float64{0.5, 0.5, 0.5}, 1},
+		{[3]float64{0.8, 0.2, 0.1}, [3]float64{0.600753, 0.249016, 0.201160}, 1},
+		{[3]float64{0.8, 0.2, 0.1}, [3]float64{0.700376, 0.224508, 0.150580}, 0.5},
+		{[3]float64{0.8, 0.2, 0.1}, [3]float64{0.8, 0.2, 0.1}, 0},
+	} {
+		r, g, b := ApplyBleachBypass(tc.in[0], tc.in[1], tc.in[2], tc.intensity, weights)
+		if got := [3]float64{r, g, b}; !nearRGB(got, tc.want, 1e-6) {
+			t.Errorf("ApplyBleachBypass(%v, %g) = %v, want %v", tc.in, tc.intensity, got, tc.want)
+		}
+	}
+}
+
+func TestBleachBypassDesaturatesAndKeepsLuminance(t *testing.T) {
+	weights := lumaWeights["rec709"]
+	saturation := func(c [3]float64) float64 {
+		hi, lo := max(c[0], c[1], c[2]), min(c[0], c[1], c[2])
+		return (hi - lo) / hi
+	}
+	for _, in := range [][3]float64{
+		{0.8, 0.2, 0.1}, {0.1, 0.6, 0.9}, {0.9, 0.1, 0.7}, {0.2, 0.9, 0.1}, {0.95, 0.6, 0.05},
+		{0.9, 0.4, 0.3}, {0.3, 0.57, 0.5},
+	} {
+		r, g, b := ApplyBleachBypass(in[0], in[1], in[2], 1, weights)
+		out := [3]float64{r, g, b}
+		if got, was := saturation(out), saturation(in); got > 0.85*was {
+			t.Errorf("%v: saturation %g of %v, want below %g", in, got, out, 0.85*was)
+		}
+		if got, was := luma(weights, r, g, b), luma(weights, in[0], in[1], in[2]); !near(got, was, 1e-12) {
+			t.Errorf("%v: luminance %g of %v, want the input's %g", in, got, out, was)
+		}
+	}
+}
+
+func TestP3WeightsShiftTealOrangeBalance(t *testing.T) {
+	look, _ := findLook("tealOrange")
+	rec709 := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	p3 := defaultConfig(t, func(c *Config) { c.Look, c.TargetColorSpace = "tealOrange", "p3d65" })
+	if p3.lumaWeights() != lumaWeights["p3d65"] {
+		t.Fatalf("p3d65 target uses luma weights %v", p3.lumaWeights())
+	}
+
+	// Gray has the same luminance under both sets of weights, up to their
+	// rounding.
+	gr, gg, gb := look.Apply(rec709, 0.5, 0.5, 0.5)
+	pr, pg, pb := look.Apply(p3, 0.5, 0.5, 0.5)
+	if !nearRGB([3]float64{gr, gg, gb}, [3]float64{pr, pg, pb}, 1e-5) {
+		t.Errorf("gray: rec709 %v, p3d65 %v, want equal", [3]float64{gr, gg, gb}, [3]float64{pr, pg, pb})
+	}
+
+	// P3 weighs red more, so a warm color near the pivot reads brighter and
+	// takes more of the orange highlight treatment and less of the teal.
+	r709, _, b709 := look.Apply(rec709, 0.7, 0.45, 0.3)
+	rP3, _, bP3 := look.Apply(p3, 0.7, 0.45, 0.3)
+	if rP3 <= r709 || bP3 >= b709 {
+		t.Errorf("warm color: rec709 red %g blue %g, p3d65 red %g blue %g; want more red and less blue with P3 weights", r709, b709, rP3, bP3)
+	}
+}
+
+func TestLookInverseComposesToIdentity(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Look = "warmVintage" })
+	l, _ := findLook("warmVintage")
+	if l.Inverse == nil {
+		t.Fatal("warmVintage has no analytic inverse")
+	}
+	for _, in := range [][3]float64{{0, 0, 0}, {0.2, 0.4, 0.6}, {0.5, 0.5, 0.5}, {0.9, 0.3, 0.8}} {
+		r, g, b := l.Apply(cfg, in[0], in[1], in[2])
+		r, g, b = l.Inverse(cfg, r, g, b)
+		if got := [3]float64{r, g, b}; !nearRGB(got, in, 1e-12) {
+			t.Errorf("inverse of warmVintage %v = %v", in, got)
+		}
+	}
+
+	_, _, numeric := GenerateLookPair(cfg)
+	if numeric {
+		t.Error("GenerateLookPair reported numeric inversion for warmVintage")
+	}
+	if _, _, numeric := GenerateLookPair(defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })); !numeric {
+		t.Error("GenerateLookPair did not report numeric inversion for tealOrange")
+	}
+}
+
+func TestNumericLookInverse(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	l, _ := findLook("tealOrange")
+	for _, in := range [][3]float64{{0.2, 0.4, 0.6}, {0.7, 0.5, 0.3}} {
+		r, g, b := l.Apply(cfg, in[0], in[1], in[2])
+		r, g, b = invertLookNumeric(l.Apply, cfg, r, g, b)
+		if got := [3]float64{r, g, b}; !nearRGB(got, in, 1e-6) {
+			t.Errorf("numeric inverse of tealOrange %v = %v", in, got)
+		}
+	}
+}
+
+func TestFilmPrintKeepsGrayNeutralAndAddsContrast(t *testing.T) {
+	gray := func(v float64) [3]float64 {
+		r, g, b := ApplyFilmPrint(v, v, v, 1)
+		return [3]float64{r, g, b}
+	}
+	for _, v := range []float64{0.05, 0.2, 0.35, 0.5, 0.65, 0.8, 0.95} {
+		out := gray(v)
+		if spread := max(out[0], out[1], out[2]) - min(out[0], out[1], out[2]); spread > 0.005 {
+			t.Errorf("gray %g maps to %v, a cast of %g", v, out, spread)
+		}
+	}
+	if got := gray(0.5); !nearRGB(got, [3]float64{0.5, 0.5, 0.5}, 1e-12) {
+		t.Errorf("mid-gray maps to %v, want it held", got)
+	}
+	if s := (gray(0.6)[1] - gray(0.4)[1]) / 0.2; s <= 1.1 {
+		t.Errorf("midtone slope %g, want contrast above 1", s)
+	}
+	if lo, hi := gray(0.2)[1], gray(0.8)[1]; lo >= 0.2 || hi <= 0.8 {
+		t.Errorf("gray 0.2 and 0.8 map to %g and %g, want deeper shadows and brighter highlights", lo, hi)
+	}
+}
+
+func TestLookStrength(t *testing.T) {
+	at := func(look string, strength *float64, in [3]float64) [3]float64 {
+		cfg := defaultConfig(t, func(c *Config) { c.Look, c.LookStrength = look, strength })
+		r, g, b := processPixel(cfg, in[0], in[1], in[2])
+		return [3]float64{r, g, b}
+	}
+	strength := func(v float64) *float64 { return &v }
+	for _, look := range []string{"tealOrange", "warmVintage", "bleachBypass", "filmPrint"} {
+		for _, in := range [][3]float64{{0.2, 0.3, 0.4}, {0.7, 0.5, 0.3}} {
+			none, full := at("none", nil, in), at(look, nil, in)
+			if got := at(look, strength(0), in); got != none {
+				t.Errorf("%s at strength 0: %v, want the none output %v", look, got, none)
+			}
+			if got := at(look, strength(1), in); got != full {
+				t.Errorf("%s at strength 1: %v, want the default output %v", look, got, full)
+			}
+			half := at(look, strength(0.5), in)
+			for c := range half {
+				lo, hi := min(none[c], full[c]), max(none[c], full[c])
+				if half[c] < lo-1e-12 || half[c] > hi+1e-12 || (lo != hi && (half[c] == lo || half[c] == hi)) {
+					t.Errorf("%s at strength 0.5: channel %d = %g, want strictly between %g and %g", look, c, half[c], lo, hi)
+				}
+			}
+		}
+	}
+	// The teal & orange blend is linear in the strength.
+	in := [3]float64{0.2, 0.3, 0.4}
+	none, full, half := at("none", nil, in), at("tealOrange", nil, in), at("tealOrange", strength(0.5), in)
+	for c := range half {
+		if want := (none[c] + full[c]) / 2; !near(half[c], want, 1e-12) {
+			t.Errorf("tealOrange at strength 0.5: channel %d = %g, want %g", c, half[c], want)
+		}
+	}
+}
+
+func TestLookParamsOverrideDefaults(t *testing.T) {
+	pixels := [][3]float64{{0.15, 0.2, 0.25}, {0.5, 0.45, 0.4}, {0.85, 0.75, 0.65}}
+	at := func(look string, params map[string]float64) [][3]float64 {
+		cfg := defaultConfig(t, func(c *Config) { c.Look, c.Params = look, params })
+		l, _ := findLook(look)
+		var out [][3]float64
+		for _, in := range pixels {
+			r, g, b := l.Apply(cfg, in[0], in[1], in[2])
+			out = append(out, [3]float64{r, g, b})
+		}
+		return out
+	}
+	for _, look := range []string{"tealOrange", "warmVintage", "filmPrint"} {
+		l, _ := findLook(look)
+		if len(l.Params) == 0 {
+			t.Fatalf("%s declares no params", look)
+		}
+		defaults := at(look, nil)
+		for name, def := range l.Params {
+			if got := at(look, map[string]float64{name: def}); !slices.Equal(got, defaults) {
+				t.Errorf("%s with %s set to its default %g: %v, want %v", look, name, def, got, defaults)
+			}
+			if got := at(look, map[string]float64{name: def + 0.05}); slices.Equal(got, defaults) {
+				t.Errorf("%s with %s %g matches the defaults %v", look, name, def+0.05, defaults)
+			}
+		}
+	}
+
+	for _, tc := range []struct {
+		params map[string]float64
+		want   string
+	}{
+		{map[string]float64{"warmht": 0.1}, `unknown params entry "warmht"`},
+		{map[string]float64{"fade": 1}, "params fade must be in [0, 1)"},
+		{map[string]float64{"density": math.Inf(1)}, "params density must be finite"},
+	} {
+		cfg := Config{Size: 2, Look: "warmVintage", Params: tc.params}
+		cfg.SetDefaults()
+		if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("params %v: error %v, want %q", tc.params, err, tc.want)
+		}
+	}
+}
+
+func TestTealOrangeShiftsShadowGreen(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	l, _ := findLook("tealOrange")
+	for _, v := range []float64{0.05, 0.1, 0.2} {
+		if _, g, _ := l.Apply(cfg, v, v, v); g <= v {
+			t.Errorf("shadow gray %g: green %g, want boosted toward teal", v, g)
+		}
+	}
+	for _, v := range []float64{0.7, 0.9} {
+		if _, g, _ := l.Apply(cfg, v, v, v); g != v {
+			t.Errorf("highlight gray %g: green %g, want unchanged", v, g)
+		}
+	}
+}
+
+func TestTealOrangeCrossFadeIsSmooth(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	l, _ := findLook("tealOrange")
+	// Sample a gray ramp across the pivot and compare neighboring slopes;
+	// a hard switch between the treatments shows up as a jump in slope.
+	const steps = 400
+	var prev [3]float64
+	var prevSlope [3]float64
+	for i := 0; i <= steps; i++ {
+		v := 0.3 + 0.4*float64(i)/steps
+		r, g, b := l.Apply(cfg, v, v, v)
+		out := [3]float64{r, g, b}
+		if i > 0 {
+			for c := range out {
+				slope := (out[c] - prev[c]) * steps / 0.4
+				if i > 1 && math.Abs(slope-prevSlope[c]) > 0.01 {
+					t.Errorf("gray %.4f: channel %d slope jumps from %g to %g", v, c, prevSlope[c], slope)
+				}
+				prevSlope[c] = slope
+			}
+		}
+		prev = out
+	}
+}
@@ -0,0 +1,1375 @@
+// Package luts generates 3D LUTs that convert Apple Log footage to display
+// color spaces, with optional creative looks. It does no file I/O: generated
+// LUTs are returned as text, or streamed to an io.Writer, for the caller to
+// write.
+package luts
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Config defines the LUT parameters.
+type Config struct {
+	Preset            string             `json:"preset"`             // Bundled preset to start from (see -presets)
+	Size              int                `json:"size"`               // Grid dimension (default 17)
+	AllowAnySize      bool               `json:"allow_any_size"`     // Accept sizes above 129 and skip the warning for uncommon sizes
+	DomainMin         float64            `json:"domain_min"`         // Lowest encoded input value spanned by the grid (default 0.0)
+	DomainMax         float64            `json:"domain_max"`         // Highest encoded input value spanned by the grid; above 1.0 keeps super-whites (default 1.0)
+	Temperature       float64            `json:"temperature"`        // White balance in Kelvin; lower is warmer (default 6500, neutral)
+	Tint              float64            `json:"tint"`               // Green-magenta white balance, -100..100; positive is more magenta (default 0)
+	RedTint           float64            `json:"red_tint"`           // Raw red multiplier in linear light, applied after temperature (default 1.0)
+	BlueTint          float64            `json:"blue_tint"`          // Raw blue multiplier in linear light, applied after temperature (default 1.0)
+	Output            string             `json:"output"`             // Output file name or template (e.g., "apple_log_{{lower .Look}}.cube")
+	Title             string             `json:"title"`              // TITLE shown by grading apps (default: output file name without extension)
+	Comment           string             `json:"comment"`            // Comment line opening .cube files (default: describes the conversion)
+	OutputDir         string             `json:"output_dir"`         // Overrides -outputDir for this config when set
+	OutputFormat      string             `json:"format"`             // Output format: "cube", "3dl", "vlt", "hald" (PNG), or "dctl" (DaVinci Resolve) (default: from the output extension, else "cube")
+	BitDepth          int                `json:"bit_depth"`          // Integer code value depth for 3dl output (default 10)
+	Separator         string             `json:"separator"`          // Value separator on cube and 3dl data lines: "space" or "tab" (default "space")
+	Precision         int                `json:"precision"`          // Decimal places of cube data values, 2..10 (default 6)
+	LineEnding        string             `json:"line_ending"`        // Line ending of text outputs: "lf" or "crlf" (default "lf")
+	Look              string             `json:"look"`               // "none", "tealOrange", "warmVintage", or "bleachBypass"
+	LookIntensity     float64            `json:"look_intensity"`     // Strength of the bleach bypass look, 0..1 (default 1.0)
+	LookStrength      *float64           `json:"look_strength"`      // How strongly the creative look is blended in, 0..1 (default 1.0)
+	TealOrangePivot   float64            `json:"teal_orange_pivot"`  // Luminance where the teal & orange look turns from teal to orange (default 0.5)
+	TealOrangeWidth   float64            `json:"teal_orange_width"`  // Luminance range of the teal & orange cross-fade around the pivot (default 0.2)
+	Params            map[string]float64 `json:"params"`             // Tunables of the creative look by name, e.g. {"warmth": 0.08}; absent ones keep the look's defaults
+	ZoneLooks         ZoneLooks          `json:"zone_looks"`         // Separate looks for shadows, midtones, and highlights (replaces look)
+	LookPair          bool               `json:"look_pair"`          // Emit the look alone plus its inverse (<output>_inverse) instead of the conversion
+	LookExpr          string             `json:"look_expr"`          // Custom look as assignments, e.g. "r = r*1.1; b = b*0.9"
+	Invert            bool               `json:"invert"`             // Generate the reverse LUT, from Rec.709 display values back to Apple Log
+	Identity          bool               `json:"identity"`           // Emit a bypass LUT whose output equals its input, ignoring every color setting (default TITLE "Identity")
+	ExposureStops     float64            `json:"exposure_stops"`     // Exposure change in stops, applied in linear light after decoding; +1 doubles (default 0)
+	ExposureOffset    float64            `json:"exposure_offset"`    // Legacy factor applied to the encoded signal before decoding; prefer exposure_stops (default 1.0)
+	NormalizeExposure bool               `json:"normalize_exposure"` // Apply exposure_offset in linear light too, then roll highlights off so the brightest input keeps its level
+	BlackPoint        float64            `json:"black_point"`        // Linear level mapped to 0 after the gamut conversion; negative values lift blacks (default 0)
+	WhitePoint        float64            `json:"white_point"`        // Linear level mapped to 1 after the gamut conversion (default 1.0)
+	ShadowLift        float64            `json:"shadow_lift"`        // Shadow lift that keeps black at 0, as peak added level (default 0)
+	ShadowLiftSpace   string             `json:"shadow_lift_space"`  // Where the shadow lift is applied: "encoded" or "linear" (default "encoded")
+	Lift              [3]float64         `json:"lift"`               // Per-channel RGB lift of the encoded signal, raising black toward this level (default [0,0,0])
+	Gamma             [3]float64         `json:"gamma"`              // Per-channel RGB gamma of the encoded signal; above 1 brightens midtones (default [1,1,1])
+	Gain              [3]float64         `json:"gain"`               // Per-channel RGB gain of the encoded signal, scaling white (default [1,1,1])
+	Saturation        *float64           `json:"saturation"`         // HSV saturation factor of the encoded signal; 0 is grayscale (default 1.0)
+	RedCurve          Curve              `json:"red_curve"`          // Red tone curve of the encoded signal as [input, output] points, e.g. [[0,0],[0.5,0.55],[1,1]] (default: identity)
+	GreenCurve        Curve              `json:"green_curve"`        // Green tone curve of the encoded signal (default: identity)
+	BlueCurve         Curve              `json:"blue_curve"`         // Blue tone curve of the encoded signal (default: identity)
+	Contrast          *float64           `json:"contrast"`           // Contrast of the encoded signal around contrast_pivot, applied before the look; 1 is unchanged (default 1.0)
+	ContrastPivot     float64            `json:"contrast_pivot"`     // Encoded level that contrast leaves in place (default 0.5)
+	InputEncoding     string             `json:"input_encoding"`     // Grid input encoding: "appleLog", "linear", or "srgb" (default "appleLog")
+	ToneMap           string             `json:"tone_map"`           // Highlight tone mapping in linear light: "none", "reinhard", or "aces" (default "none")
+	KneeStart         float64            `json:"knee_start"`         // Linear level above which highlights are softly compressed toward 1.0 (default 1.0, off)
+	KneeStrength      float64            `json:"knee_strength"`      // Knee shape; higher stays linear longer and bends more sharply, >= 1 (default 2)
+	GamutMapping      string             `json:"gamut_mapping"`      // Out-of-gamut handling: "clip", "compress", or "preserveHue" (default "clip")
+	ClipMode          string             `json:"clip_mode"`          // Alias selecting gamut_mapping "clip" ("perChannel") or "preserveHue" ("preserveHue")
+	Matrix            []float64          `json:"matrix"`             // Row-major 3x3 Rec.2020 to Rec.709 linear matrix replacing the built-in one (9 values)
+	Target            string             `json:"target"`             // Display target: "rec709" or "appleReference" (P3-D65, gamma 2.4) (default "rec709")
+	TargetColorSpace  string             `json:"target_color_space"` // Output color space: "rec709", "srgb", "p3d65", or "acescct" (replaces target)
+	OutputTransfer    string             `json:"output_transfer"`    // Encoding transfer: "rec709", "gamma" (pure power law), "hlg", or "pq" (default: the target's)
+	OutputGamma       float64            `json:"output_gamma"`       // Display gamma of the "gamma" output transfer, encoding linear^(1/output_gamma) (default 2.4)
+	PeakNits          float64            `json:"peak_nits"`          // Luminance of linear 1.0 for PQ output (default 1000)
+	OutputBlack       float64            `json:"output_black"`       // Remap the darkest output to this level, 0 <= x < 1 (0 disables)
+	NormalizeWhite    bool               `json:"normalize_white"`    // Rescale output so input white maps exactly to (1,1,1); channels pushed past 1.0 are clipped, or rolled off by a soft output_clip
+	OutputClip        string             `json:"output_clip"`        // How output values reaching 1.0 are limited: "hard" (as computed) or "soft" (rolled off from 0.9) (default "hard")
+	Dither            bool               `json:"dither"`             // Add fixed-seed triangular noise to the output to break up banding
+	DitherAmount      float64            `json:"dither_amount"`      // Peak dither amplitude as a fraction of full scale (default 1/255, one 8-bit step)
+	QuantizeBits      int                `json:"quantize_bits"`      // Quantize output to this integer bit depth (0 keeps float)
+	ShaperOnly        bool               `json:"shaper_only"`        // Emit only a 1D shaper instead of the 3D LUT
+	ShaperSize        int                `json:"shaper_size"`        // Entries in the 1D shaper; without shaper_only, a 1D pre-LUT of this size precedes the 3D LUT (0 disables; shaper_only default 1024)
+	ShaperSpace       string             `json:"shaper_space"`       // Shaper working space: "linear" or "acescct" (default "linear")
+
+	lookProgram *lookProgram // Compiled LookExpr, set by Validate
+}
+
+// SetDefaults fills in default values for fields left unset.
+func (c *Config) SetDefaults() {
+	if c.Size <= 0 {
+		c.Size = 17
+	}
+	if c.DomainMax == 0 {
+		c.DomainMax = 1.0
+	}
+	if c.Temperature == 0 {
+		c.Temperature = neutralTemperature
+	}
+	if c.RedTint == 0 {
+		c.RedTint = 1.0
+	}
+	if c.BlueTint == 0 {
+		c.BlueTint = 1.0
+	}
+	if c.Output == "" {
+		c.Output = "output.cube"
+	}
+	if c.Look == "" {
+		c.Look = "none"
+	}
+	if c.LookIntensity == 0 {
+		c.LookIntensity = 1.0
+	}
+	if c.TealOrangePivot == 0 {
+		c.TealOrangePivot = 0.5
+	}
+	if c.TealOrangeWidth == 0 {
+		c.TealOrangeWidth = 0.2
+	}
+	if c.ExposureOffset == 0 {
+		c.ExposureOffset = 1.0
+	}
+	if c.ZoneLooks.enabled() {
+		c.ZoneLooks.setDefaults()
+	}
+	if c.Target == "" && c.TargetColorSpace == "" {
+		c.Target = "rec709"
+	}
+	if c.OutputGamma == 0 {
+		c.OutputGamma = 2.4
+	}
+	if c.PeakNits == 0 {
+		c.PeakNits = 1000
+	}
+	if c.OutputFormat == "" {
+		c.OutputFormat = "cube"
+		switch ext := strings.ToLower(path.Ext(c.Output)); ext {
+		case ".3dl", ".vlt":
+			c.OutputFormat = ext[1:]
+		case ".png":
+			c.OutputFormat = "hald"
+		case ".dctl":
+			c.OutputFormat = "dctl"
+		}
+	}
+	if c.BitDepth == 0 {
+		c.BitDepth = 10
+	}
+	if c.Precision == 0 {
+		c.Precision = 6
+	}
+	c.Precision = min(max(c.Precision, minPrecision), maxPrecision)
+	if c.Separator == "" {
+		c.Separator = "space"
+	}
+	if c.LineEnding == "" {
+		c.LineEnding = "lf"
+	}
+	if c.Gamma == ([3]float64{}) {
+		c.Gamma = [3]float64{1, 1, 1}
+	}
+	if c.Gain == ([3]float64{}) {
+		c.Gain = [3]float64{1, 1, 1}
+	}
+	if c.WhitePoint == 0 {
+		c.WhitePoint = 1.0
+	}
+	if c.ContrastPivot == 0 {
+		c.ContrastPivot = 0.5
+	}
+	if c.ShadowLiftSpace == "" {
+		c.ShadowLiftSpace = "encoded"
+	}
+	if c.KneeStart == 0 {
+		c.KneeStart = 1.0
+	}
+	if c.KneeStrength == 0 {
+		c.KneeStrength = 2
+	}
+	if c.ToneMap == "" {
+		c.ToneMap = "none"
+	}
+	if c.GamutMapping == "" {
+		c.GamutMapping = "clip"
+		if strings.EqualFold(c.ClipMode, "preserveHue") {
+			c.GamutMapping = "preserveHue"
+		}
+	}
+	if c.OutputClip == "" {
+		c.OutputClip = "hard"
+	}
+	if c.InputEncoding == "" {
+		c.InputEncoding = "appleLog"
+	}
+	if c.Dither && c.DitherAmount == 0 {
+		c.DitherAmount = 1.0 / 255
+	}
+	if c.ShaperOnly && c.ShaperSize == 0 {
+		c.ShaperSize = 1024
+	}
+	if c.ShaperSpace == "" {
+		c.ShaperSpace = "linear"
+	}
+}
+
+// Validate reports config values that cannot be used to generate a LUT.
+// It also compiles LookExpr so it is parsed once per config.
+func (c *Config) Validate() error {
+	if c.Size < minSize {
+		return fmt.Errorf("size must be at least %d, got %d", minSize, c.Size)
+	}
+	if c.Size > maxSize && !c.AllowAnySize {
+		return fmt.Errorf("size must be at most %d, got %d (set allow_any_size for larger grids)", maxSize, c.Size)
+	}
+	if c.Target != "" && c.TargetColorSpace != "" {
+		return fmt.Errorf("set either target or target_color_space, not both")
+	}
+	if _, err := c.displayTarget(); err != nil {
+		return err
+	}
+	if _, ok := findLook(c.Look); !ok {
+		return fmt.Errorf("unknown look %q (valid: %s)", c.Look, strings.Join(LookNames(), ", "))
+	}
+	switch strings.ToLower(c.OutputTransfer) {
+	case "", "rec709", "gamma", "hlg", "pq":
+	default:
+		return fmt.Errorf("unknown output_transfer %q (valid: rec709, gamma, hlg, pq)", c.OutputTransfer)
+	}
+	if c.OutputGamma <= 0 {
+		return fmt.Errorf("output_gamma must be positive, got %g", c.OutputGamma)
+	}
+	if c.ExposureOffset <= 0 {
+		return fmt.Errorf("exposure_offset must be positive, got %g", c.ExposureOffset)
+	}
+	if math.Abs(c.ExposureStops) > maxExposureStops {
+		return fmt.Errorf("exposure_stops must be between -%g and %g, got %g", maxExposureStops, maxExposureStops, c.ExposureStops)
+	}
+	if c.PeakNits <= 0 || c.PeakNits > pqMaxNits {
+		return fmt.Errorf("peak_nits must be in (0, %g], got %g", pqMaxNits, c.PeakNits)
+	}
+	if c.LookExpr != "" {
+		prog, err := compileLookExpr(c.LookExpr)
+		if err != nil {
+			return err
+		}
+		c.lookProgram = prog
+	}
+	for ch, g := range c.Gamma {
+		if g <= 0 {
+			return fmt.Errorf("gamma values must be positive, got %g for channel %d", g, ch)
+		}
+	}
+	switch strings.ToLower(c.InputEncoding) {
+	case "applelog", "linear", "srgb":
+	default:
+		return fmt.Errorf("unknown input_encoding %q (valid: appleLog, linear, srgb)", c.InputEncoding)
+	}
+	if err := c.validateMatrix(); err != nil {
+		return err
+	}
+	if err := c.validateParams(); err != nil {
+		return err
+	}
+	if err := c.validateCurves(); err != nil {
+		return err
+	}
+	if err := c.validateToneMap(); err != nil {
+		return err
+	}
+	if err := c.validateKnee(); err != nil {
+		return err
+	}
+	if c.Identity && (c.Invert || c.LookPair || c.ShaperOnly || c.ShaperSize > 0) {
+		return fmt.Errorf("identity cannot be combined with invert, look_pair, shaper_only, or shaper_size")
+	}
+	if err := c.validateInvert(); err != nil {
+		return err
+	}
+	switch strings.ToLower(c.GamutMapping) {
+	case "clip", "compress", "preservehue":
+	default:
+		return fmt.Errorf("unknown gamut_mapping %q (valid: clip, compress, preserveHue)", c.GamutMapping)
+	}
+	if c.ClipMode != "" {
+		var mapping string
+		switch strings.ToLower(c.ClipMode) {
+		case "perchannel":
+			mapping = "clip"
+		case "preservehue":
+			mapping = "preserveHue"
+		default:
+			return fmt.Errorf("unknown clip_mode %q (valid: perChannel, preserveHue)", c.ClipMode)
+		}
+		if !strings.EqualFold(c.GamutMapping, mapping) {
+			return fmt.Errorf("clip_mode %q conflicts with gamut_mapping %q", c.ClipMode, c.GamutMapping)
+		}
+	}
+	switch strings.ToLower(c.OutputClip) {
+	case "hard", "soft":
+	default:
+		return fmt.Errorf("unknown output_clip %q (valid: hard, soft)", c.OutputClip)
+	}
+	switch strings.ToLower(c.ShaperSpace) {
+	case "linear", "acescct":
+	default:
+		return fmt.Errorf("unknown shaper_space %q (valid: linear, acescct)", c.ShaperSpace)
+	}
+	switch strings.ToLower(c.OutputFormat) {
+	case "cube":
+	case "3dl":
+		if c.ShaperOnly || c.ShaperSize > 0 {
+			return fmt.Errorf("shaper_only and shaper_size require the cube format")
+		}
+		if c.BitDepth < 8 || c.BitDepth > 16 {
+			return fmt.Errorf("bit_depth must be between 8 and 16, got %d", c.BitDepth)
+		}
+		if c.DomainMin != 0 || c.DomainMax != 1 {
+			return fmt.Errorf("the 3dl format supports only the default [0, 1] domain")
+		}
+	case "vlt":
+		if c.ShaperOnly {
+			return fmt.Errorf("shaper_only requires the cube format")
+		}
+		if c.Size != vltSize {
+			return fmt.Errorf("the vlt format supports only size %d, got %d", vltSize, c.Size)
+		}
+	case "hald":
+		if c.ShaperOnly || c.ShaperSize > 0 || c.LookPair {
+			return fmt.Errorf("shaper_only, shaper_size, and look_pair cannot be combined with the hald format")
+		}
+		if c.DomainMin != 0 || c.DomainMax != 1 {
+			return fmt.Errorf("the hald format supports only the default [0, 1] domain")
+		}
+	case "dctl":
+		if err := c.validateDCTL(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown format %q (valid: cube, 3dl, vlt, hald, dctl)", c.OutputFormat)
+	}
+	switch strings.ToLower(c.Separator) {
+	case "space", "tab":
+	default:
+		return fmt.Errorf("unknown separator %q (valid: space, tab)", c.Separator)
+	}
+	switch strings.ToLower(c.LineEnding) {
+	case "lf", "crlf":
+	default:
+		return fmt.Errorf("unknown line_ending %q (valid: lf, crlf)", c.LineEnding)
+	}
+	switch strings.ToLower(c.ShadowLiftSpace) {
+	case "encoded", "linear":
+	default:
+		return fmt.Errorf("unknown shadow_lift_space %q (valid: encoded, linear)", c.ShadowLiftSpace)
+	}
+	if maxLift := shadowLiftMax(c.ShadowLiftSpace); c.ShadowLift < 0 || c.ShadowLift > maxLift {
+		return fmt.Errorf("shadow_lift must be between 0 and %.3f in %s space, got %g", maxLift, c.ShadowLiftSpace, c.ShadowLift)
+	}
+	if c.BlackPoint >= c.WhitePoint {
+		return fmt.Errorf("black_point (%g) must be below white_point (%g)", c.BlackPoint, c.WhitePoint)
+	}
+	if err := c.validateWhiteBalance(); err != nil {
+		return err
+	}
+	if c.DomainMin >= c.DomainMax {
+		return fmt.Errorf("domain_min (%g) must be below domain_max (%g)", c.DomainMin, c.DomainMax)
+	}
+	if s := c.lookStrength(); s < 0 || s > 1 {
+		return fmt.Errorf("look_strength must be between 0 and 1, got %g", s)
+	}
+	if c.Saturation != nil && *c.Saturation < 0 {
+		return fmt.Errorf("saturation must not be negative, got %g", *c.Saturation)
+	}
+	if c.Contrast != nil && *c.Contrast < 0 {
+		return fmt.Errorf("contrast must not be negative, got %g", *c.Contrast)
+	}
+	if c.ContrastPivot <= 0 || c.ContrastPivot >= 1 {
+		return fmt.Errorf("contrast_pivot must be in (0, 1), got %g", c.ContrastPivot)
+	}
+	if c.TealOrangePivot <= 0 || c.TealOrangePivot >= 1 {
+		return fmt.Errorf("teal_orange_pivot must be in (0, 1), got %g", c.TealOrangePivot)
+	}
+	if c.TealOrangeWidth <= 0 || c.TealOrangeWidth > 1 {
+		return fmt.Errorf("teal_orange_width must be in (0, 1], got %g", c.TealOrangeWidth)
+	}
+	if c.OutputBlack < 0 || c.OutputBlack >= 1 {
+		return fmt.Errorf("output_black must be in [0, 1), got %g", c.OutputBlack)
+	}
+	if c.DitherAmount < 0 || c.DitherAmount > 0.1 {
+		return fmt.Errorf("dither_amount must be between 0 and 0.1, got %g", c.DitherAmount)
+	}
+	if c.QuantizeBits < 0 || c.QuantizeBits > 16 {
+		return fmt.Errorf("quantize_bits must be between 0 and 16, got %d", c.QuantizeBits)
+	}
+	if c.ZoneLooks.enabled() {
+		if err := c.ZoneLooks.validate(); err != nil {
+			return err
+		}
+	}
+	if c.LookPair && (c.ShaperOnly || c.LookExpr != "" || c.ZoneLooks.enabled()) {
+		return fmt.Errorf("look_pair cannot be combined with shaper_only, look_expr, or zone_looks")
+	}
+	if c.ShaperSize < 0 || (c.ShaperOnly && c.ShaperSize < 2) || c.ShaperSize == 1 {
+		return fmt.Errorf("shaper_size must be at least 2, got %d", c.ShaperSize)
+	}
+	if c.ShaperSize > 0 && !c.ShaperOnly && (c.LookPair || strings.EqualFold(c.OutputFormat, "vlt")) {
+		return fmt.Errorf("a shaper pre-LUT (shaper_size) cannot be combined with look_pair or the vlt format")
+	}
+	return nil
+}
+
+// lookStrength returns LookStrength, or 1.0 when it is unset.
+func (c *Config) lookStrength() float64 {
+	if c.LookStrength == nil {
+		return 1.0
+	}
+	return *c.LookStrength
+}
+
+// AppleLogToLinear approximates the decoding of Apple Log to linear light.
+// This is a simplified function; in practice, use the official curve.
+func AppleLogToLinear(x float64, exposureOffset float64) float64 {
+	// Apply an exposure offset and clip to [0,1]
+	return appleLogDecode(min(x*exposureOffset, 1))
+}
+
+// appleLogDecode is the unclipped curve behind AppleLogToLinear.
+func appleLogDecode(v float64) float64 {
+	// A simple power function to approximate the inverse log curve.
+	// (Note: This is a rough approximation.)
+	return math.Pow(math.Max(v, 0), 1.5)
+}
+
+// srgbToLinear applies the piecewise sRGB EOTF, decoding an sRGB encoded
+// value to linear light.
+func srgbToLinear(x float64) float64 {
+	if x <= 0.04045 {
+		return x / 12.92
+	}
+	return math.Pow((x+0.055)/1.055, 2.4)
+}
+
+// decodeInput converts an encoded grid value to linear light according to the
+// configured input encoding. ExposureStops scales the decoded linear light
+// first; the legacy exposure offset then scales the encoded signal, which is
+// clipped to 1.0, or to DomainMax when the domain extends into super-whites,
+// and is decoded again. Without stops the offset is applied to the grid
+// value directly, as it always has been. With NormalizeExposure, both scale the decoded light
+// and are followed by the highlight rolloff of exposureRolloff, so raising
+// the exposure compresses the top of the range instead of clipping it.
+func decodeInput(cfg Config, x float64) float64 {
+	if cfg.NormalizeExposure {
+		start, span, a := exposureRolloff(cfg)
+		return rollOffHighlight(decodeSignal(cfg, min(x, max(cfg.DomainMax, 1)))*exposureGain(cfg), start, span, a)
+	}
+	top := max(cfg.DomainMax, 1)
+	if cfg.ExposureStops == 0 {
+		return decodeSignal(cfg, min(x*cfg.ExposureOffset, top))
+	}
+	v := decodeSignal(cfg, min(x, top)) * math.Exp2(cfg.ExposureStops)
+	if cfg.ExposureOffset != 1 {
+		v = decodeSignal(cfg, min(encodeSignal(cfg, v)*cfg.ExposureOffset, top))
+	}
+	return v
+}
+
+// decodeSignal applies the decoding curve of the configured input encoding.
+func decodeSignal(cfg Config, v float64) float64 {
+	switch strings.ToLower(cfg.InputEncoding) {
+	case "linear":
+		return v
+	case "srgb":
+		return srgbToLinear(v)
+	default:
+		return appleLogDecode(v)
+	}
+}
+
+// encodeSignal is the inverse of decodeSignal.
+func encodeSignal(cfg Config, v float64) float64 {
+	switch strings.ToLower(cfg.InputEncoding) {
+	case "linear":
+		return v
+	case "srgb":
+		return srgbOETF(v)
+	default:
+		return linearToAppleLog(v)
+	}
+}
+
+// normalizeExposureStart is where the NormalizeExposure rolloff begins, as a
+// fraction of the decoded level of the brightest input.
+const normalizeExposureStart = 0.5
+
+// exposureGain returns the linear-light gain NormalizeExposure applies: the
+// exposure offset and stops combined.
+func exposureGain(cfg Config) float64 {
+	return cfg.ExposureOffset * math.Exp2(cfg.ExposureStops)
+}
+
+// exposureRolloff returns the parameters of the NormalizeExposure rolloff for
+// rollOffHighlight. Exposed levels up to start pass through; above it, the
+// range up to the exposed brightest input is compressed so that input lands
+// on its unexposed level, which is 1.0 for the default domain. Exposure that
+// does not raise the brightest input past that level is left untouched
+// (a is 0).
+func exposureRolloff(cfg Config) (start, span, a float64) {
+	peak := decodeSignal(cfg, max(cfg.DomainMax, 1))
+	start = normalizeExposureStart * peak
+	span = peak - start
+	if t := (exposureGain(cfg)*peak - start) / span; t > 1 {
+		a = (t - 1) / t
+	}
+	return start, span, a
+}
+
+// rollOffHighlight compresses v above start along t/(1+a*t), with t the
+// distance past start in units of span. The slope stays continuous at start,
+// and a chosen by exposureRolloff maps the exposed peak onto start+span.
+func rollOffHighlight(v, start, span, a float64) float64 {
+	if a == 0 || v <= start {
+		return v
+	}
+	t := (v - start) / span
+	return start + span*t/(1+a*t)
+}
+
+// unrollHighlight is the inverse of rollOffHighlight. Levels at or beyond
+// what the rolloff can reach map to +Inf.
+func unrollHighlight(v, start, span, a float64) float64 {
+	if a == 0 || v <= start {
+		return v
+	}
+	y := (v - start) / span
+	if a*y >= 1 {
+		return math.Inf(1)
+	}
+	return start + span*y/(1-a*y)
+}
+
+// linearToACEScct encodes linear light using the ACEScct curve (log section
+// with a linear toe below the breakpoint).
+func linearToACEScct(linear float64) float64 {
+	if linear <= 0.0078125 {
+		return 10.5402377416545*linear + 0.0729055341958355
+	}
+	return (math.Log2(linear) + 9.72) / 17.52
+}
+
+// Rec2020ToRec709 converts Rec.2020 linear values to Rec.709 linear using a 3x3 matrix.
+func Rec2020ToRec709(r, g, b float64) (float64, float64, float64) {
+	// Matrix coefficients (approximation)
+	r709, g709, b709 := rec2020ToRec709Linear(r, g, b)
+	// Clip values to [0,1]
+	if r709 < 0 {
+		r709 = 0
+	}
+	if g709 < 0 {
+		g709 = 0
+	}
+	if b709 < 0 {
+		b709 = 0
+	}
+	if r709 > 1 {
+		r709 = 1
+	}
+	if g709 > 1 {
+		g709 = 1
+	}
+	if b709 > 1 {
+		b709 = 1
+	}
+	return r709, g709, b709
+}
+
+// Rec709OETF applies the Rec.709 opto-electronic transfer function.
+func Rec709OETF(linear float64) float64 {
+	if linear < 0.018 {
+		return 4.5 * linear
+	}
+	return 1.099*math.Pow(linear, 0.45) - 0.099
+}
+
+// PQ (SMPTE ST 2084) constants.
+const (
+	pqM1 = 2610.0 / 16384
+	pqM2 = 2523.0 / 4096 * 128
+	pqC1 = 3424.0 / 4096
+	pqC2 = 2413.0 / 4096 * 32
+	pqC3 = 2392.0 / 4096 * 32
+
+	pqMaxNits = 10000.0 // Luminance encoded by a PQ signal of 1.0
+)
+
+// PQInverseEOTF encodes a linear value as an SMPTE ST 2084 (PQ) signal.
+// Linear 1.0 is displayed at peakNits.
+func PQInverseEOTF(linear, peakNits float64) float64 {
+	y := math.Max(linear, 0) * peakNits / pqMaxNits
+	p := math.Pow(y, pqM1)
+	return math.Pow((pqC1+pqC2*p)/(1+pqC3*p), pqM2)
+}
+
+// HLG (ARIB STD-B67) constants.
+const (
+	hlgA = 0.17883277
+	hlgB = 0.28466892 // 1 - 4a
+	hlgC = 0.55991073 // 0.5 - a*ln(4a)
+)
+
+// HLGOETF encodes a linear scene value in [0,1] as an ARIB STD-B67 (HLG) signal.
+func HLGOETF(linear float64) float64 {
+	linear = math.Max(linear, 0)
+	if linear <= 1.0/12 {
+		return math.Sqrt(3 * linear)
+	}
+	return hlgA*math.Log(12*linear-hlgB) + hlgC
+}
+
+// shadowLiftPivot returns the level at which the shadow lift has tapered to
+// no effect: mid-gray in the given space.
+func shadowLiftPivot(space string) float64 {
+	if strings.EqualFold(space, "linear") {
+		return 0.18
+	}
+	return 0.5
+}
+
+// shadowLiftMax returns the largest shadow lift that keeps the curve
+// monotonic in the given space.
+func shadowLiftMax(space string) float64 {
+	return shadowLiftPivot(space) / 2.25
+}
+
+// applyShadowLift raises values below pivot along a curve anchored at 0, so
+// pure black stays black, that adds at most lift (at pivot/3) and tapers
+// smoothly to no change at pivot. Values at or above pivot are unchanged.
+func applyShadowLift(x, lift, pivot float64) float64 {
+	if lift == 0 || x <= 0 || x >= pivot {
+		return x
+	}
+	u := x / pivot
+	return x + lift*27/4*u*(1-u)*(1-u)
+}
+
+// applyLevels rescales a linear value so black maps to 0 and white to 1,
+// clamping the result to [0,1].
+func applyLevels(x, black, white float64) float64 {
+	return math.Min(math.Max((x-black)/(white-black), 0), 1)
+}
+
+// applyLiftGammaGain applies the lift/gamma/gain formula to an encoded value:
+// lift sets the level black maps to, gain the level white maps to, and the
+// result is raised to 1/gamma and clamped to [0,1]; unbounded targets keep
+// values above 1.0. Neutral values (0, 1, 1) return x unchanged.
+func applyLiftGammaGain(x, lift, gamma, gain float64, unbounded bool) float64 {
+	if lift == 0 && gamma == 1 && gain == 1 {
+		return x
+	}
+	v := x*(gain-lift) + lift
+	if gamma != 1 && v > 0 {
+		v = math.Pow(v, 1/gamma)
+	}
+	if unbounded {
+		return max(v, 0)
+	}
+	return math.Min(math.Max(v, 0), 1)
+}
+
+// applyContrast scales an encoded value's distance from pivot by contrast
+// and clamps the result to [0,1].
+func applyContrast(x, contrast, pivot float64) float64 {
+	return math.Min(math.Max((x-pivot)*contrast+pivot, 0), 1)
+}
+
+// applySaturation scales the HSV saturation of an encoded RGB value by factor,
+// keeping hue and value, and clamps the result to [0,1]. Saturation is capped
+// at 1, so boosted colors stop at the edge of the gamut instead of changing
+// hue. In RGB terms each channel moves away from the value (the largest
+// channel) in proportion to the saturation change.
+func applySaturation(r, g, b, factor float64) (float64, float64, float64) {
+	v := max(r, g, b)
+	if v <= 0 {
+		return 0, 0, 0
+	}
+	s := (v - min(r, g, b)) / v
+	if s == 0 {
+		return min(v, 1), min(v, 1), min(v, 1)
+	}
+	k := min(s*factor, 1) / s
+	scale := func(c float64) float64 { return math.Min(math.Max(v-(v-c)*k, 0), 1) }
+	return scale(r), scale(g), scale(b)
+}
+
+// ApplyTealOrange applies a simplified teal & orange look: shadows are pushed
+// toward teal (less red, a little more green, more blue) and highlights toward
+// orange (more red, less blue, green untouched). The two treatments cross-fade
+// with a smoothstep over width, centered on the luminance pivot, so gradients
+// through the pivot stay seamless. Luminance is weighted by weights, those
+// of the output primaries. strength scales the blend toward the modified
+// values; at 0 the input is returned unchanged.
+func ApplyTealOrange(r, g, b, strength, pivot, width float64, weights [3]float64) (float64, float64, float64) {
+	return applyTealOrange(r, g, b, strength, pivot, width, weights,
+		tealOrangeParams["shadow_blue_boost"], tealOrangeParams["highlight_warmth"])
+}
+
+// applyTealOrange is ApplyTealOrange with the blue added to shadows and the
+// red added to highlights, as fractions of the channel, given explicitly.
+func applyTealOrange(r, g, b, strength, pivot, width float64, weights [3]float64, shadowBlue, highlightWarmth float64) (float64, float64, float64) {
+	// Compute luminance
+	lum := luma(weights, r, g, b)
+	// Share of the highlight treatment, 0 in shadows and 1 in highlights
+	w := smoothstep(pivot-width/2, pivot+width/2, lum)
+	rNew := r * ((1-w)*0.95 + w*(1+highlightWarmth))
+	gNew := g * ((1-w)*1.03 + w*1.0)
+	bNew := b * ((1-w)*(1+shadowBlue) + w*0.95)
+	// Blend the original with the modified values
+	mix := 0.3 * strength // Share of the modified values at full strength is 0.3
+	r = (1-mix)*r + mix*rNew
+	g = (1-mix)*g + mix*gNew
+	b = (1-mix)*b + mix*bNew
+	return min(r, 1), min(g, 1), min(b, 1)
+}
+
+// ApplyWarmVintage applies a simplified warm vintage look. strength scales
+// the tint and the contrast reduction; at 0 the input is returned unchanged.
+func ApplyWarmVintage(r, g, b, strength float64) (float64, float64, float64) {
+	return applyWarmVintage(r, g, b, strength, warmVintageParams["warmth"], warmVintageParams["fade"])
+}
+
+// applyWarmVintage is ApplyWarmVintage with the tint and the blend toward
+// mid-gray at full strength given explicitly.
+func applyWarmVintage(r, g, b, strength, warmth, fade float64) (float64, float64, float64) {
+	// Apply a subtle warm tint: increase red slightly, decrease blue
+	r = r * (1 + warmth*strength)
+	b = b * (1 - warmth*strength)
+	// Optionally, lower contrast gently by blending with mid-gray (0.5)
+	gray := fade * strength
+	r = (1-gray)*r + gray*0.5
+	g = (1-gray)*g + gray*0.5
+	b = (1-gray)*b + gray*0.5
+	if r > 1 {
+		r = 1
+	}
+	if g > 1 {
+		g = 1
+	}
+	if b > 1 {
+		b = 1
+	}
+	return r, g, b
+}
+
+// InvertWarmVintage is the exact inverse of ApplyWarmVintage at the same
+// strength for values the look produces without clipping. Results are
+// clamped to [0,1].
+func InvertWarmVintage(r, g, b, strength float64) (float64, float64, float64) {
+	return invertWarmVintage(r, g, b, strength, warmVintageParams["warmth"], warmVintageParams["fade"])
+}
+
+// invertWarmVintage is the inverse of applyWarmVintage with the same
+// parameters.
+func invertWarmVintage(r, g, b, strength, warmth, fade float64) (float64, float64, float64) {
+	gray := fade * strength
+	r = (r - gray*0.5) / (1 - gray) / (1 + warmth*strength)
+	g = (g - gray*0.5) / (1 - gray)
+	b = (b - gray*0.5) / (1 - gray) / (1 - warmth*strength)
+	clamp := func(v float64) float64 { return math.Min(math.Max(v, 0), 1) }
+	return clamp(r), clamp(g), clamp(b)
+}
+
+// ApplyBleachBypass applies a simplified bleach bypass look, like skipping
+// the bleach bath. Each channel is pulled halfway toward the luminance and
+// a luminance layer is overlaid onto it, which mutes saturation while the
+// overlay keeps some contrast between the channels. The result is rescaled
+// to the input's luminance so only the color changes. intensity blends
+// between the original (0) and the full look (1), and weights are the
+// luminance weights of the output primaries.
+func ApplyBleachBypass(r, g, b, intensity float64, weights [3]float64) (float64, float64, float64) {
+	lum := luma(weights, r, g, b)
+	// Overlay blend of the luminance layer onto each half-desaturated channel.
+	overlay := func(base float64) float64 {
+		base = (base + lum) / 2
+		if base < 0.5 {
+			return 2 * base * lum
+		}
+		return 1 - 2*(1-base)*(1-lum)
+	}
+	lr, lg, lb := overlay(r), overlay(g), overlay(b)
+	// Rescale the look to the input luminance.
+	if l := luma(weights, lr, lg, lb); l > 0 {
+		k := lum / l
+		lr, lg, lb = lr*k, lg*k, lb*k
+	}
+	r = (1-intensity)*r + intensity*lr
+	g = (1-intensity)*g + intensity*lg
+	b = (1-intensity)*b + intensity*lb
+	if r < 0 {
+		r = 0
+	}
+	if g < 0 {
+		g = 0
+	}
+	if b < 0 {
+		b = 0
+	}
+	if r > 1 {
+		r = 1
+	}
+	if g > 1 {
+		g = 1
+	}
+	if b > 1 {
+		b = 1
+	}
+	return r, g, b
+}
+
+// filmPrintCoupling is the dye-layer crosstalk of the filmPrint look: each
+// channel picks up a little density from its neighbors, as in a print stock.
+// Rows sum to 1 so neutrals stay neutral.
+var filmPrintCoupling = [9]float64{
+	0.90, 0.07, 0.03,
+	0.05, 0.90, 0.05,
+	0.03, 0.09, 0.88,
+}
+
+// filmPrintContrast is how far each channel of the filmPrint look moves
+// toward its S-shaped density curve. The small spread between the layers
+// gives the print its character without a visible cast.
+var filmPrintContrast = [3]float64{0.32, 0.30, 0.28}
+
+// ApplyFilmPrint applies a simplified print-film look in the display-encoded
+// domain: the channels are coupled by filmPrintCoupling, then each follows an
+// S-shaped density curve that deepens shadows and rolls off highlights while
+// holding mid-gray. strength blends between the input (0) and the full look
+// (1). Results are clamped to [0,1].
+func ApplyFilmPrint(r, g, b, strength float64) (float64, float64, float64) {
+	return applyFilmPrint(r, g, b, strength, filmPrintParams["density"])
+}
+
+// applyFilmPrint is ApplyFilmPrint with the depth of the density curves
+// given explicitly as a multiple of filmPrintContrast.
+func applyFilmPrint(r, g, b, strength, density float64) (float64, float64, float64) {
+	in := [3]float64{r, g, b}
+	cr, cg, cb := multiplyMatrix(filmPrintCoupling, r, g, b)
+	var out [3]float64
+	for c, v := range [3]float64{cr, cg, cb} {
+		v = math.Min(math.Max(v, 0), 1)
+		v += density * filmPrintContrast[c] * (v*v*(3-2*v) - v)
+		out[c] = math.Min(math.Max(in[c]+strength*(v-in[c]), 0), 1)
+	}
+	return out[0], out[1], out[2]
+}
+
+// quantize rounds v to the nearest code value of an integer encoding with the
+// given bit depth and returns it renormalized to [0,1].
+func quantize(v float64, bits int) float64 {
+	maxCode := float64(int(1)<<bits - 1)
+	return math.Round(v*maxCode) / maxCode
+}
+
+// Panasonic VariCam .vlt files are 17-point cubes of 10-bit code values.
+const (
+	vltSize = 17
+	vltBits = 10
+)
+
+// Bounds for Config.Size. A grid needs at least two nodes per axis to span
+// its domain; sizes above maxSize require AllowAnySize.
+const (
+	minSize = 2
+	maxSize = 129
+)
+
+// recommendedSizes are the grid sizes grading apps and hardware LUT boxes
+// commonly accept.
+var recommendedSizes = []int{17, 33, 65}
+
+// SizeWarnings reports a 3D LUT size that is not one of recommendedSizes,
+// since some hardware rejects other sizes. It is silent with AllowAnySize
+// and for formats whose size is fixed or unused.
+func SizeWarnings(cfg Config) []string {
+	if cfg.AllowAnySize || cfg.ShaperOnly || slices.Contains(recommendedSizes, cfg.Size) {
+		return nil
+	}
+	switch strings.ToLower(cfg.OutputFormat) {
+	case "hald", "dctl", "vlt":
+		return nil
+	}
+	return []string{fmt.Sprintf("size %d is not one of the common sizes 17, 33, or 65; some hardware LUT boxes only accept those", cfg.Size)}
+}
+
+// maxExposureStops bounds Config.ExposureStops in either direction.
+const maxExposureStops = 16.0
+
+// Bounds for Config.Precision; values outside are clamped.
+const (
+	minPrecision = 2
+	maxPrecision = 10
+)
+
+// separator returns the string placed between values on a cube data line.
+func (c *Config) separator() string {
+	if strings.EqualFold(c.Separator, "tab") {
+		return "\t"
+	}
+	return " "
+}
+
+// Newline returns the line ending of text outputs selected by LineEnding.
+func (c *Config) Newline() string {
+	if strings.EqualFold(c.LineEnding, "crlf") {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// withNewlines returns text written with "\n" line endings in LineEnding.
+func (c *Config) withNewlines(s string) string {
+	if c.Newline() == "\n" {
+		return s
+	}
+	return strings.ReplaceAll(s, "\n", "\r\n")
+}
+
+// lineWriter returns w, translating the "\n" line endings the formatters
+// write to "\r\n" when LineEnding is "crlf".
+func (c *Config) lineWriter(w io.Writer) io.Writer {
+	if c.Newline() == "\n" {
+		return w
+	}
+	return crlfWriter{w}
+}
+
+// crlfWriter writes "\r\n" for each "\n" written to it.
+type crlfWriter struct {
+	w io.Writer
+}
+
+func (cw crlfWriter) Write(p []byte) (int, error) {
+	if _, err := cw.w.Write(bytes.ReplaceAll(p, []byte("\n"), []byte("\r\n"))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// cubeComment returns the comment line that opens a .cube file.
+func cubeComment(cfg Config) string {
+	if cfg.Comment != "" {
+		return "# " + strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(cfg.Comment) + "\n"
+	}
+	if cfg.Identity {
+		return "# Generated identity LUT (output equals input)\n"
+	}
+	if cfg.Invert {
+		return "# Generated inverse LUT for Rec.709 to Apple Log conversion\n"
+	}
+	if t, err := cfg.displayTarget(); err == nil && t.Transfer == "acescct" {
+		return "# Generated LUT for Apple Log to ACEScct conversion\n"
+	}
+	return "# Generated Cinematic LUT for Apple Log to Rec.709 conversion\n"
+}
+
+// formatTriplet formats one cube data line in fixed notation with prec
+// decimal places.
+func formatTriplet(r, g, b float64, sep string, prec int) string {
+	return fmt.Sprintf("%.*f%s%.*f%s%.*f\n", prec, r, sep, prec, g, sep, prec, b)
+}
+
+// Stats collects measurements taken while generating a LUT.
+type Stats struct {
+	QuantMaxError  float64 // Largest absolute error introduced by quantization
+	QuantMeanError float64 // Mean absolute error introduced by quantization
+	NonFinite      int     // Channel values that were NaN or infinite and were replaced
+}
+
+// processPixel runs one encoded input value through the pipeline:
+// 1. Decode from the input encoding (Apple Log by default) to linear light and white balance.
+// 2. Optionally tone map and soft-knee highlights, then convert from Rec.2020 (linear) to the target primaries (Rec.709 by default).
+// 3. Remap the linear black and white points, then apply the output transfer (the target's, the Rec.709 OETF by default), with the optional shadow lift in
+// linear light before it or in the encoded signal after it, then lift/gamma/gain, saturation, the RGB curves, and contrast.
+// 4. Optionally, apply a creative look and then the custom look expression.
+// With Invert set, the base conversion runs in reverse instead (see invertPixel),
+// and with Identity set the input is returned unchanged.
+func processPixel(cfg Config, inR, inG, inB float64) (float64, float64, float64) {
+	if cfg.Identity {
+		return inR, inG, inB
+	}
+	if cfg.Invert {
+		return invertPixel(cfg, inR, inG, inB)
+	}
+	// Step 1: Decode the input to linear light.
+	return processDecoded(cfg, decodeInput(cfg, inR), decodeInput(cfg, inG), decodeInput(cfg, inB))
+}
+
+// ProcessPixel runs one encoded input value, nominally in [0,1], through the
+// same per-pixel pipeline that BuildCube evaluates at each grid node, so
+// library callers can transform individual colors without building a cube.
+// The output stages BuildCube adds afterwards (normalize_white, output_clip,
+// output_black, dither, and quantize_bits) are not included. cfg must have passed SetDefaults and
+// Validate.
+func ProcessPixel(cfg Config, r, g, b float64) (float64, float64, float64) {
+	return processPixel(cfg, r, g, b)
+}
+
+// PixelTrace records one color at the main stages of the per-pixel pipeline,
+// as reported by TracePixel.
+type PixelTrace struct {
+	Input   [3]float64 // Encoded input
+	Decoded [3]float64 // Linear light after decoding and exposure
+	Target  [3]float64 // Linear light in the target's primaries, after white balance, tone mapping, and the gamut conversion
+	Encoded [3]float64 // After the target's transfer function
+	Graded  [3]float64 // Output of ProcessPixel, after the grading controls and looks
+	Final   [3]float64 // After the output stages BuildCube applies to the whole grid
+}
+
+// TracePixel runs one encoded input value through the pipeline like
+// ProcessPixel and returns it at each stage, for debugging the color math.
+// Identity and inverse LUTs have no such stages; for them only Input,
+// Graded, and Final are set. Final is the value BuildCube stores for the
+// input: when any of normalize_white, a soft output_clip, output_black,
+// dither, or quantize_bits is set, which depend on the whole grid, it is
+// sampled from the built cube, and so is exact only at grid nodes. cfg must
+// have passed SetDefaults and Validate.
+func TracePixel(cfg Config, r, g, b float64) PixelTrace {
+	trace := PixelTrace{Input: [3]float64{r, g, b}}
+	if cfg.Identity || cfg.Invert {
+		r, g, b = processPixel(cfg, r, g, b)
+	} else {
+		r, g, b = tracedDecoded(cfg, decodeInput(cfg, r), decodeInput(cfg, g), decodeInput(cfg, b), &trace)
+	}
+	trace.Graded = [3]float64{r, g, b}
+	if cfg.hasOutputStages() {
+		cube, _ := BuildCube(cfg)
+		trace.Final = cube.sample(trace.Input[0], trace.Input[1], trace.Input[2])
+		return trace
+	}
+	for ch, v := range trace.Graded {
+		trace.Final[ch], _ = finiteValue(v)
+	}
+	return trace
+}
+
+// hasOutputStages reports whether BuildCube changes the nodes after the
+// per-pixel pipeline, beyond replacing non-finite values.
+func (c *Config) hasOutputStages() bool {
+	if c.Identity {
+		return false
+	}
+	return c.NormalizeWhite || strings.EqualFold(c.OutputClip, "soft") || c.OutputBlack > 0 || c.Dither || c.QuantizeBits > 0
+}
+
+// processDecoded runs the pipeline of processPixel from white balance on,
+// for input already decoded to linear light.
+func processDecoded(cfg Config, linR, linG, linB float64) (float64, float64, float64) {
+	return tracedDecoded(cfg, linR, linG, linB, nil)
+}
+
+// tracedDecoded is processDecoded, also recording the intermediate values in
+// trace unless it is nil.
+func tracedDecoded(cfg Config, linR, linG, linB float64, trace *PixelTrace) (float64, float64, float64) {
+	if trace != nil {
+		trace.Decoded = [3]float64{linR, linG, linB}
+	}
+	// Step 2: Tone map and apply the highlight knee, then convert to the
+	// target's primaries (Rec.709 by default).
+	linR, linG, linB = gradeLinear(cfg, linR, linG, linB)
+	target, err := cfg.displayTarget()
+	if err != nil {
+		target = displayTargets["rec709"]
+	}
+	convR, convG, convB := convertGamut(cfg, target, linR, linG, linB)
+	if trace != nil {
+		trace.Target = [3]float64{convR, convG, convB}
+	}
+	if cfg.BlackPoint != 0 || cfg.WhitePoint != 1 {
+		convR = applyLevels(convR, cfg.BlackPoint, cfg.WhitePoint)
+		convG = applyLevels(convG, cfg.BlackPoint, cfg.WhitePoint)
+		convB = applyLevels(convB, cfg.BlackPoint, cfg.WhitePoint)
+	}
+
+	// Step 3: Encode using the target's transfer, lifting shadows on the requested side.
+	linearLift := strings.EqualFold(cfg.ShadowLiftSpace, "linear")
+	pivot := shadowLiftPivot(cfg.ShadowLiftSpace)
+	if linearLift {
+		convR = applyShadowLift(convR, cfg.ShadowLift, pivot)
+		convG = applyShadowLift(convG, cfg.ShadowLift, pivot)
+		convB = applyShadowLift(convB, cfg.ShadowLift, pivot)
+	}
+	encR := encodeTransfer(target, convR)
+	encG := encodeTransfer(target, convG)
+	encB := encodeTransfer(target, convB)
+	if trace != nil {
+		trace.Encoded = [3]float64{encR, encG, encB}
+	}
+	if !linearLift {
+		encR = applyShadowLift(encR, cfg.ShadowLift, pivot)
+		encG = applyShadowLift(encG, cfg.ShadowLift, pivot)
+		encB = applyShadowLift(encB, cfg.ShadowLift, pivot)
+	}
+	encR = applyLiftGammaGain(encR, cfg.Lift[0], cfg.Gamma[0], cfg.Gain[0], target.Unbounded)
+	encG = applyLiftGammaGain(encG, cfg.Lift[1], cfg.Gamma[1], cfg.Gain[1], target.Unbounded)
+	encB = applyLiftGammaGain(encB, cfg.Lift[2], cfg.Gamma[2], cfg.Gain[2], target.Unbounded)
+	if cfg.Saturation != nil && *cfg.Saturation != 1 {
+		encR, encG, encB = applySaturation(encR, encG, encB, *cfg.Saturation)
+	}
+	if cfg.curvesEnabled() {
+		encR, encG, encB = cfg.RedCurve.apply(encR), cfg.GreenCurve.apply(encG), cfg.BlueCurve.apply(encB)
+	}
+	if cfg.Contrast != nil && *cfg.Contrast != 1 {
+		encR = applyContrast(encR, *cfg.Contrast, cfg.ContrastPivot)
+		encG = applyContrast(encG, *cfg.Contrast, cfg.ContrastPivot)
+		encB = applyContrast(encB, *cfg.Contrast, cfg.ContrastPivot)
+	}
+
+	// Step 4: Apply creative look if specified, or one look per tonal zone.
+	if cfg.ZoneLooks.enabled() {
+		encR, encG, encB = applyZoneLooks(cfg, encR, encG, encB)
+	} else if l, ok := findLook(cfg.Look); ok {
+		encR, encG, encB = l.Apply(cfg, encR, encG, encB)
+	}
+	if cfg.LookExpr != "" {
+		prog := cfg.lookProgram
+		if prog == nil {
+			var err error
+			if prog, err = compileLookExpr(cfg.LookExpr); err != nil {
+				return encR, encG, encB
+			}
+		}
+		encR, encG, encB = prog.apply(encR, encG, encB)
+	}
+	return encR, encG, encB
+}
+
+// gradeLinear applies the white balance, tone mapping, and highlight knee to
+// decoded linear light, the stages of processPixel before the gamut
+// conversion.
+func gradeLinear(cfg Config, r, g, b float64) (float64, float64, float64) {
+	if wb := whiteBalanceGains(cfg); wb != [3]float64{1, 1, 1} {
+		r, g, b = r*wb[0], g*wb[1], b*wb[2]
+	}
+	r, g, b = applyToneMap(cfg, r, g, b)
+	r = applyKnee(r, cfg.KneeStart, cfg.KneeStrength)
+	g = applyKnee(g, cfg.KneeStart, cfg.KneeStrength)
+	b = applyKnee(b, cfg.KneeStart, cfg.KneeStrength)
+	return r, g, b
+}
+
+// whiteLevels returns the pipeline's per-channel output for input white, by
+// which normalize_white divides every node so white lands exactly on
+// (1,1,1). Channels whose white output is zero cannot be rescaled and get a
+// level of 1.
+func whiteLevels(cfg Config) [3]float64 {
+	wR, wG, wB := processPixel(cfg, 1, 1, 1)
+	levels := [3]float64{1, 1, 1}
+	for c, w := range [3]float64{wR, wG, wB} {
+		if w > 0 {
+			levels[c] = w
+		}
+	}
+	return levels
+}
+
+// Generate applies defaults to cfg, validates it, and returns the LUT as text
+// in the configured format: the 1D shaper when ShaperOnly is set, otherwise
+// the 3D LUT computed by BuildCube. For the hald format the result is the
+// PNG-encoded HALD CLUT from RenderHald, and for the dctl format the DCTL
+// source from GenerateDCTL. With LookPair it is the look LUT of
+// GenerateLookPair; the inverse comes only from GenerateLookPair.
+func Generate(cfg Config) (string, error) {
+	var builder strings.Builder
+	if err := WriteLUT(&builder, cfg); err != nil {
+		return "", err
+	}
+	return builder.String(), nil
+}
+
+// WriteLUT is Generate writing the LUT to w instead of returning it. The
+// data lines of 3D LUTs are streamed to w as they are formatted, so the
+// whole text is never held in memory; the other formats are small and are
+// written in one piece. Text formats end their lines as LineEnding selects
+// and never start with a byte order mark. Nothing is written when cfg is
+// invalid.
+func WriteLUT(w io.Writer, cfg Config) error {
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if cfg.ShaperOnly {
+		_, err := io.WriteString(w, GenerateShaper(cfg))
+		return err
+	}
+	if strings.EqualFold(cfg.OutputFormat, "hald") {
+		png, err := formatHald(cfg)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, png)
+		return err
+	}
+	if strings.EqualFold(cfg.OutputFormat, "dctl") {
+		_, err := io.WriteString(w, GenerateDCTL(cfg))
+		return err
+	}
+	if cfg.LookPair {
+		return WriteCube(w, cfg, buildLookCube(cfg, pairLook(cfg).Apply))
+	}
+	cube, _ := BuildCube(cfg)
+	return WriteCube(w, cfg, cube)
+}
+
+// FormatCube writes cube as text in the configured format.
+func FormatCube(cfg Config, cube *Cube) string {
+	var builder strings.Builder
+	WriteCube(&builder, cfg, cube) // Writes to a strings.Builder cannot fail
+	return builder.String()
+}
+
+// WriteCube is FormatCube writing to w. Red slices of the grid are formatted
+// concurrently, a batch of runtime.NumCPU() at a time, and written in order
+// through a buffer, so memory use does not grow with the grid size. Lines
+// end as LineEnding selects.
+func WriteCube(w io.Writer, cfg Config, cube *Cube) error {
+	size := cube.Size
+	builder := bufio.NewWriter(cfg.lineWriter(w))
+
+	vlt := strings.EqualFold(cfg.OutputFormat, "vlt")
+	threeDL := strings.EqualFold(cfg.OutputFormat, "3dl")
+	sep := cfg.separator()
+	if vlt {
+		sep = " " // VariCam decks expect spaces
+	}
+
+	// Integer formats store code values of this many bits.
+	bits := 0
+	switch {
+	case vlt:
+		bits = vltBits
+	case threeDL:
+		bits = cfg.BitDepth
+	}
+	maxCode := float64(int(1)<<bits - 1)
+
+	// Write LUT header
+	if vlt {
+		builder.WriteString("# panasonic vlt file version 1.0\n")
+		builder.WriteString("# source vlt file \"\"\n")
+		builder.WriteString(fmt.Sprintf("LUT_3D_SIZE %d\n\n", size))
+	} else if threeDL {
+		// Autodesk .3dl: a comment, then the input code value of each mesh
+		// point along every axis.
+		builder.WriteString(fmt.Sprintf("# %s\n", cubeTitle(cfg)))
+		mesh := make([]string, size)
+		for i := range mesh {
+			mesh[i] = strconv.Itoa(int(math.Round(float64(i) / float64(size-1) * maxCode)))
+		}
+		builder.WriteString(strings.Join(mesh, " ") + "\n")
+	} else if cube.Shaper != nil {
+		builder.WriteString(cubeComment(cfg))
+		writeShapedCubeHeader(builder, cfg, cube)
+	} else {
+		builder.WriteString(cubeComment(cfg))
+		writeCubeHeader(builder, cfg, "3D", size, cube.domain())
+	}
+
+	// Write the LUT lines: integer code values for vlt and 3dl, otherwise
+	// floats with cfg.Precision decimal places. Both orders step blue fastest.
+	sliceLen := size * size
+	batch := make([]string, min(workers, size))
+	for start := 0; start < size; start += len(batch) {
+		n := min(len(batch), size-start)
+		parallelSlices(n, func(i int) {
+			var sb strings.Builder
+			for _, v := range cube.Data[(start+i)*sliceLen : (start+i+1)*sliceLen] {
+				if bits > 0 {
+					sb.WriteString(fmt.Sprintf("%d%s%d%s%d\n",
+						int(math.Round(v[0]*maxCode)), sep, int(math.Round(v[1]*maxCode)), sep, int(math.Round(v[2]*maxCode))))
+				} else {
+					sb.WriteString(formatTriplet(v[0], v[1], v[2], sep, cfg.Precision))
+				}
+			}
+			batch[i] = sb.String()
+		})
+		for _, slice := range batch[:n] {
+			builder.WriteString(slice)
+		}
+	}
+	return builder.Flush()
+}
+
+// cubeTitle returns the TITLE for cfg: Title, "Identity" for identity LUTs,
+// or the base name of Output without its extension.
+func cubeTitle(cfg Config) string {
+	if cfg.Title != "" {
+		return cfg.Title
+	}
+	if cfg.Identity {
+		return "Identity"
+	}
+	base := path.Base(strings.ReplaceAll(cfg.Output, "\\", "/"))
+	return strings.TrimSuffix(base, path.Ext(base))
+}
+
+// quoteCubeString quotes s for a .cube keyword line. Backslashes and quotes
+// are escaped, and line breaks, which would end the line, become spaces.
+func quoteCubeString(s string) string {
+	s = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "\r\n", " ", "\n", " ", "\r", " ").Replace(s)
+	return "\"" + s + "\""
+}
+
+// writeCubeHeader writes the TITLE line, the LUT_<dim>_SIZE line, and the
+// input domain of a .cube file. The domain is written as DOMAIN_MIN and
+// DOMAIN_MAX and, when it is not [0,1], also as LUT_<dim>_INPUT_RANGE, which
+// some apps such as Baselight read instead.
+func writeCubeHeader(builder io.StringWriter, cfg Config, dim string, size int, domain [2]float64) {
+	builder.WriteString(fmt.Sprintf("TITLE %s\n", quoteCubeString(cubeTitle(cfg))))
+	builder.WriteString(fmt.Sprintf("LUT_%s_SIZE %d\n", dim, size))
+	lo, hi := formatDomain(domain[0]), formatDomain(domain[1])
+	builder.WriteString(fmt.Sprintf("DOMAIN_MIN %s %s %s\n", lo, lo, lo))
+	builder.WriteString(fmt.Sprintf("DOMAIN_MAX %s %s %s\n", hi, hi, hi))
+	if domain != [2]float64{0, 1} {
+		builder.WriteString(fmt.Sprintf("LUT_%s_INPUT_RANGE %s %s\n", dim, lo, hi))
+	}
+}
+
+// writeShapedCubeHeader writes the header and 1D section of a cube with a
+// shaper, in the combined 1D+3D layout read by Resolve: the 1D pre-LUT spans
+// the input domain and the 3D LUT spans the shaper's [0,1] output.
+func writeShapedCubeHeader(builder io.StringWriter, cfg Config, cube *Cube) {
+	d := cube.domain()
+	builder.WriteString(fmt.Sprintf("TITLE %s\n", quoteCubeString(cubeTitle(cfg))))
+	builder.WriteString(fmt.Sprintf("LUT_1D_SIZE %d\n", len(cube.Shaper)))
+	builder.WriteString(fmt.Sprintf("LUT_1D_INPUT_RANGE %s %s\n", formatDomain(d[0]), formatDomain(d[1])))
+	builder.WriteString(fmt.Sprintf("LUT_3D_SIZE %d\n", cube.Size))
+	builder.WriteString("LUT_3D_INPUT_RANGE 0.0 1.0\n")
+	for _, v := range cube.Shaper {
+		builder.WriteString(formatTriplet(v, v, v, cfg.separator(), cfg.Precision))
+	}
+}
+
+// formatDomain formats a domain bound with at least one decimal place, as in
+// "1.0" or "1.25".
+func formatDomain(v float64) string {
+	s := strconv.FormatFloat(v, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// GenerateShaper creates a 1D LUT that decodes Apple Log into the configured
+// working space, intended to precede a third-party 3D LUT.
+func GenerateShaper(cfg Config) string {
+	size := cfg.ShaperSize
+	var builder strings.Builder
+
+	appleLog := cfg // The shaper always decodes Apple Log
+	appleLog.InputEncoding = "appleLog"
+
+	builder.WriteString(fmt.Sprintf("# Generated 1D shaper for Apple Log to %s conversion\n", cfg.ShaperSpace))
+	writeCubeHeader(&builder, cfg, "1D", size, [2]float64{cfg.DomainMin, cfg.DomainMax})
+
+	for i := 0; i < size; i++ {
+		in := cfg.DomainMin + float64(i)/float64(size-1)*(cfg.DomainMax-cfg.DomainMin)
+		v := decodeInput(appleLog, in)
+		if strings.EqualFold(cfg.ShaperSpace, "acescct") {
+			v = linearToACEScct(v)
+		}
+		builder.WriteString(formatTriplet(v, v, v, cfg.separator(), cfg.Precision))
+	}
+	return cfg.withNewlines(builder.String())
+}
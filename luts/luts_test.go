@@ -0,0 +1,424 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+)
+
+// defaultConfig returns a config with defaults applied, after edit has
+// adjusted it, and fails the test if it does not validate.
+func defaultConfig(t testing.TB, edit func(c *Config)) Config {
+	t.Helper()
+	var cfg Config
+	if edit != nil {
+		edit(&cfg)
+	}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	return cfg
+}
+
+func near(a, b, tol float64) bool {
+	return math.Abs(a-b) <= tol
+}
+
+func TestProcessPixelDefaultGrays(t *testing.T) {
+	cfg := defaultConfig(t, nil)
+	for _, tc := range []struct {
+		name    string
+		in, out float64
+	}{
+		{"black", 0, 0},
+		{"18% gray", 0.318798, 0.409008}, // Decodes to linear 0.18
+		{"mid", 0.5, 0.589339},
+		{"white", 1, 1},
+	} {
+		r, g, b := processPixel(cfg, tc.in, tc.in, tc.in)
+		if got := [3]float64{r, g, b}; !nearRGB(got, [3]float64{tc.out, tc.out, tc.out}, 1e-5) {
+			t.Errorf("%s: input %g maps to %v, want %g on every channel", tc.name, tc.in, got, tc.out)
+		}
+	}
+}
+
+func TestExposureStops(t *testing.T) {
+	plain := defaultConfig(t, nil)
+	up := defaultConfig(t, func(c *Config) { c.ExposureStops = 1 })
+	zero := defaultConfig(t, func(c *Config) { c.ExposureStops = 0 })
+	for _, x := range []float64{0.1, 0.4, 0.6} {
+		base := decodeInput(plain, x)
+		if got := decodeInput(up, x); !near(got, 2*base, 1e-12) {
+			t.Errorf("+1 stop at %g: got %g, want %g", x, got, 2*base)
+		}
+		if got := decodeInput(zero, x); got != base {
+			t.Errorf("0 stops at %g: got %g, want %g", x, got, base)
+		}
+	}
+}
+
+func TestExposureStopsBeforeOffset(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.ExposureStops, c.ExposureOffset = 0.5, 1.2 })
+	for _, x := range []float64{0.1, 0.4, 0.6} {
+		stopped := appleLogDecode(x) * math.Exp2(0.5)
+		want := appleLogDecode(min(linearToAppleLog(stopped)*1.2, 1))
+		if got := decodeInput(cfg, x); !near(got, want, 1e-12) {
+			t.Errorf("decodeInput(%g) = %g, want %g", x, got, want)
+		}
+	}
+}
+
+func TestExposureOffsetAloneUnchanged(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.ExposureOffset = 1.5 })
+	for _, x := range []float64{0.1, 0.5, 0.9} {
+		if got, want := decodeInput(cfg, x), appleLogDecode(min(x*1.5, 1)); got != want {
+			t.Errorf("decodeInput(%g) = %g, want %g", x, got, want)
+		}
+	}
+}
+
+func TestInvertUndoesExposure(t *testing.T) {
+	forward := defaultConfig(t, func(c *Config) { c.ExposureStops, c.ExposureOffset = 0.5, 1.2 })
+	inverse := forward
+	inverse.Invert = true
+	for _, in := range [][3]float64{{0.2, 0.18, 0.16}, {0.4, 0.36, 0.32}} {
+		r, g, b := processPixel(forward, in[0], in[1], in[2])
+		r, g, b = processPixel(inverse, r, g, b)
+		for c, v := range [3]float64{r, g, b} {
+			if !near(v, in[c], 1e-5) {
+				t.Errorf("round trip of %v: channel %d = %g", in, c, v)
+			}
+		}
+	}
+}
+
+func TestSRGBDecode(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.InputEncoding = "srgb" })
+	if got := decodeInput(cfg, 0.5); !near(got, 0.21404114, 1e-8) {
+		t.Errorf("sRGB 0.5 decodes to %g, want 0.21404114", got)
+	}
+	if got := decodeInput(cfg, 0.02); !near(got, 0.02/12.92, 1e-15) {
+		t.Errorf("sRGB 0.02 decodes to %g, want the linear toe %g", got, 0.02/12.92)
+	}
+	for _, x := range []float64{0, 0.01, 0.04045, 0.2, 0.5, 0.9, 1} {
+		if got := srgbOETF(srgbToLinear(x)); !near(got, x, 1e-7) { // The standard breakpoints differ slightly
+			t.Errorf("sRGB round trip of %g = %g", x, got)
+		}
+	}
+}
+
+// readVLT is a minimal Panasonic .vlt reader: the two header comments, the
+// size line and a blank line, then one line of three 10-bit code values per
+// node. It returns the nodes normalized to [0,1].
+func readVLT(t *testing.T, data string) (int, [][3]float64) {
+	t.Helper()
+	lines := strings.Split(data, "\n")
+	if len(lines) < 4 || lines[0] != "# panasonic vlt file version 1.0" || !strings.HasPrefix(lines[1], "# source vlt file ") || lines[3] != "" {
+		t.Fatalf("malformed vlt header: %q", lines[:min(len(lines), 4)])
+	}
+	var size int
+	if _, err := fmt.Sscanf(lines[2], "LUT_3D_SIZE %d", &size); err != nil {
+		t.Fatalf("size line %q: %v", lines[2], err)
+	}
+	var nodes [][3]float64
+	for _, line := range lines[4:] {
+		if line == "" {
+			continue
+		}
+		var code [3]int
+		if _, err := fmt.Sscanf(line, "%d %d %d", &code[0], &code[1], &code[2]); err != nil {
+			t.Fatalf("data line %q: %v", line, err)
+		}
+		var node [3]float64
+		for c, v := range code {
+			if v < 0 || v > 1023 {
+				t.Fatalf("code value %d outside 10 bits in %q", v, line)
+			}
+			node[c] = float64(v) / 1023
+		}
+		nodes = append(nodes, node)
+	}
+	return size, nodes
+}
+
+func TestVLTOutput(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.OutputFormat, c.Size, c.Look = "vlt", 17, "tealOrange" })
+	data, err := Generate(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, nodes := readVLT(t, data)
+	if size != 17 || len(nodes) != 17*17*17 {
+		t.Fatalf("size %d with %d nodes, want 17 and %d", size, len(nodes), 17*17*17)
+	}
+	cube, _ := BuildCube(cfg)
+	for n, node := range nodes {
+		if !nearRGB(node, cube.Data[n], 0.5/1023+1e-12) {
+			t.Fatalf("node %d = %v, want %v within half a code value", n, node, cube.Data[n])
+		}
+	}
+
+	bad := Config{OutputFormat: "vlt", Size: 33}
+	bad.SetDefaults()
+	if err := bad.Validate(); err == nil {
+		t.Error("Validate accepted a size 33 vlt")
+	}
+}
+
+func TestSeparator(t *testing.T) {
+	for _, tc := range []struct {
+		format, separator, firstLine string
+	}{
+		{"cube", "space", "0.000000 0.000000 0.000000\n"},
+		{"cube", "tab", "0.000000\t0.000000\t0.000000\n"},
+		{"3dl", "space", "0 0 0\n"},
+		{"3dl", "tab", "0\t0\t0\n"},
+	} {
+		cfg := defaultConfig(t, func(c *Config) { c.OutputFormat, c.Separator, c.Size = tc.format, tc.separator, 5 })
+		data, err := Generate(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(data, "\n"+tc.firstLine) {
+			t.Errorf("%s with %s: no data line %q in\n%s", tc.format, tc.separator, tc.firstLine, data)
+		}
+		if other := map[string]string{"space": "\t", "tab": " "}[tc.separator]; strings.Contains(data[strings.Index(data, tc.firstLine):], other) {
+			t.Errorf("%s with %s: data lines contain %q", tc.format, tc.separator, other)
+		}
+		warnings, err := ValidateLUT(cfg, data)
+		if err != nil || len(warnings) != 0 {
+			t.Errorf("%s with %s: ValidateLUT = %v, %v", tc.format, tc.separator, warnings, err)
+		}
+	}
+}
+
+func TestShadowLiftKeepsBlack(t *testing.T) {
+	for _, space := range []string{"encoded", "linear"} {
+		plain := defaultConfig(t, func(c *Config) { c.ShadowLiftSpace = space })
+		lifted := defaultConfig(t, func(c *Config) { c.ShadowLiftSpace, c.ShadowLift = space, 0.05 })
+		out := func(cfg Config, x float64) float64 {
+			r, _, _ := processPixel(cfg, x, x, x)
+			return r
+		}
+		if got, want := out(lifted, 0), out(plain, 0); got != want {
+			t.Errorf("%s: black = %g, want %g", space, got, want)
+		}
+		if got, base := out(lifted, 0.2), out(plain, 0.2); got <= base {
+			t.Errorf("%s: shadow 0.2 = %g, not above %g", space, got, base)
+		}
+		if got, want := out(lifted, 0.9), out(plain, 0.9); got != want {
+			t.Errorf("%s: highlight 0.9 = %g, want unchanged %g", space, got, want)
+		}
+	}
+
+	pivot := shadowLiftPivot("encoded")
+	lift := shadowLiftMax("encoded")
+	prev := 0.0
+	for i := 1; i <= 100; i++ {
+		x := float64(i) / 100
+		v := applyShadowLift(x, lift, pivot)
+		if v < prev {
+			t.Fatalf("the largest lift is not monotonic at %g: %g after %g", x, v, prev)
+		}
+		prev = v
+	}
+	if got := applyShadowLift(pivot/3, 0.1, pivot); !near(got, pivot/3+0.1, 1e-12) {
+		t.Errorf("lift at a third of the pivot = %g, want %g", got, pivot/3+0.1)
+	}
+}
+
+func TestLiftGammaGain(t *testing.T) {
+	plain := defaultConfig(t, nil)
+	neutral := defaultConfig(t, func(c *Config) {
+		c.Lift, c.Gamma, c.Gain = [3]float64{0, 0, 0}, [3]float64{1, 1, 1}, [3]float64{1, 1, 1}
+	})
+	doubled := defaultConfig(t, func(c *Config) { c.Gain = [3]float64{2, 2, 2} })
+	for _, x := range []float64{0.1, 0.3, 0.5, 0.9} {
+		r, g, b := processPixel(plain, x, x, x)
+		nr, ng, nb := processPixel(neutral, x, x, x)
+		if nr != r || ng != g || nb != b {
+			t.Errorf("neutral lift/gamma/gain at %g: %g %g %g, want %g %g %g", x, nr, ng, nb, r, g, b)
+		}
+		dr, _, _ := processPixel(doubled, x, x, x)
+		if want := min(2*r, 1); !near(dr, want, 1e-12) {
+			t.Errorf("gain 2 at %g: %g, want %g", x, dr, want)
+		}
+	}
+
+	if got := applyLiftGammaGain(0, 0.1, 1, 1, false); got != 0.1 {
+		t.Errorf("lift 0.1 maps black to %g, want 0.1", got)
+	}
+	if got := applyLiftGammaGain(0.25, 0, 2, 1, false); got != 0.5 {
+		t.Errorf("gamma 2 maps 0.25 to %g, want 0.5", got)
+	}
+	if got := applyLiftGammaGain(0.8, 0, 1, 1.5, true); !near(got, 1.2, 1e-12) {
+		t.Errorf("gain 1.5 on an unbounded target maps 0.8 to %g, want 1.2", got)
+	}
+}
+
+func TestValidateRejectsUnknownLook(t *testing.T) {
+	cfg := Config{Look: "tealorang"}
+	cfg.SetDefaults()
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted look \"tealorang\"")
+	}
+	for _, want := range append([]string{`"tealorang"`}, LookNames()...) {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %s", err, want)
+		}
+	}
+
+	upper := defaultConfig(t, func(c *Config) { c.Look = "TEALORANGE" })
+	exact := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	r, g, b := processPixel(upper, 0.3, 0.4, 0.5)
+	er, eg, eb := processPixel(exact, 0.3, 0.4, 0.5)
+	if r != er || g != eg || b != eb {
+		t.Errorf("look TEALORANGE gives %g %g %g, want the tealOrange output %g %g %g", r, g, b, er, eg, eb)
+	}
+}
+
+func TestValidateRejectsSizeOne(t *testing.T) {
+	// A single node would put every grid coordinate at n/(size-1) = 0/0.
+	cfg := Config{Size: 1}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "size must be at least 2, got 1") {
+		t.Errorf("size 1: Validate() = %v", err)
+	}
+
+	cube, stats := BuildCube(defaultConfig(t, func(c *Config) { c.Size = 2 }))
+	if stats.NonFinite != 0 {
+		t.Errorf("size 2: %d non-finite values", stats.NonFinite)
+	}
+	if got := cube.Data[len(cube.Data)-1]; got == cube.Data[0] {
+		t.Errorf("size 2: black and white nodes are both %v", got)
+	}
+}
+
+func TestSaturation(t *testing.T) {
+	at := func(factor float64, in [3]float64) [3]float64 {
+		r, g, b := applySaturation(in[0], in[1], in[2], factor)
+		return [3]float64{r, g, b}
+	}
+	color := [3]float64{0.8, 0.6, 0.4}
+	if got := at(0, color); got != [3]float64{0.8, 0.8, 0.8} {
+		t.Errorf("saturation 0 of %v = %v, want gray at the value 0.8", color, got)
+	}
+	if got, want := at(1.5, color), [3]float64{0.8, 0.5, 0.2}; !nearRGB(got, want, 1e-12) {
+		t.Errorf("saturation 1.5 of %v = %v, want %v", color, got, want)
+	}
+
+	factor := 1.0
+	plain := defaultConfig(t, nil)
+	one := defaultConfig(t, func(c *Config) { c.Saturation = &factor })
+	zero := 0.0
+	gray := defaultConfig(t, func(c *Config) { c.Saturation = &zero })
+	for _, in := range [][3]float64{{0.2, 0.4, 0.6}, {0.7, 0.5, 0.3}} {
+		r, g, b := processPixel(one, in[0], in[1], in[2])
+		pr, pg, pb := processPixel(plain, in[0], in[1], in[2])
+		if r != pr || g != pg || b != pb {
+			t.Errorf("%v at saturation 1: %g %g %g, want the default %g %g %g", in, r, g, b, pr, pg, pb)
+		}
+		if r, g, b := processPixel(gray, in[0], in[1], in[2]); r != g || g != b {
+			t.Errorf("%v at saturation 0: %g %g %g, want gray", in, r, g, b)
+		}
+	}
+}
+
+func TestContrast(t *testing.T) {
+	const pivot = 0.5
+	for _, x := range []float64{0, 0.1, 0.35, 0.5, 0.8, 1} {
+		if got := applyContrast(x, 1, pivot); !near(got, x, 1e-15) {
+			t.Errorf("contrast 1 maps %g to %g, want it unchanged", x, got)
+		}
+	}
+	slope := func(contrast float64) float64 {
+		const h = 0.05
+		return (applyContrast(pivot+h, contrast, pivot) - applyContrast(pivot-h, contrast, pivot)) / (2 * h)
+	}
+	for _, contrast := range []float64{0.5, 1, 2} {
+		if got := applyContrast(pivot, contrast, pivot); got != pivot {
+			t.Errorf("contrast %g moves the pivot to %g", contrast, got)
+		}
+	}
+	if s := slope(2); !near(s, 2, 1e-12) || s <= slope(1) {
+		t.Errorf("contrast 2: midtone slope %g, want 2", s)
+	}
+	if s := slope(0.5); !near(s, 0.5, 1e-12) || s >= slope(1) {
+		t.Errorf("contrast 0.5: midtone slope %g, want 0.5", s)
+	}
+
+	one := 1.0
+	plain := defaultConfig(t, func(c *Config) { c.Look = "tealOrange" })
+	unity := defaultConfig(t, func(c *Config) { c.Look, c.Contrast = "tealOrange", &one })
+	for _, x := range []float64{0.2, 0.5, 0.7} {
+		r, g, b := processPixel(plain, x, 0.4, 0.3)
+		ur, ug, ub := processPixel(unity, x, 0.4, 0.3)
+		if r != ur || g != ug || b != ub {
+			t.Errorf("contrast 1 changes %g 0.4 0.3 from %g %g %g to %g %g %g", x, r, g, b, ur, ug, ub)
+		}
+	}
+}
+
+func TestBlackAndWhitePoints(t *testing.T) {
+	plain := defaultConfig(t, nil)
+	explicit := defaultConfig(t, func(c *Config) { c.BlackPoint, c.WhitePoint = 0, 1 })
+	lifted := defaultConfig(t, func(c *Config) { c.BlackPoint = -0.02 })
+	crushed := defaultConfig(t, func(c *Config) { c.BlackPoint = 0.02 })
+	for _, x := range []float64{0, 0.05, 0.15, 0.3} {
+		_, g, _ := processPixel(plain, x, x, x)
+		if _, e, _ := processPixel(explicit, x, x, x); e != g {
+			t.Errorf("gray %g at black_point 0 and white_point 1: %g, want the default %g", x, e, g)
+		}
+		if _, l, _ := processPixel(lifted, x, x, x); l <= g {
+			t.Errorf("gray %g at black_point -0.02: %g, want lifted above %g", x, l, g)
+		}
+		if _, c, _ := processPixel(crushed, x, x, x); c > g || (g > 0 && c == g) {
+			t.Errorf("gray %g at black_point 0.02: %g, want below %g", x, c, g)
+		}
+	}
+	if got := applyLevels(0.02, 0.02, 1); got != 0 {
+		t.Errorf("black_point 0.02 maps 0.02 to %g, want 0", got)
+	}
+	if got := applyLevels(0.4, 0, 0.8); got != 0.5 {
+		t.Errorf("white_point 0.8 maps 0.4 to %g, want 0.5", got)
+	}
+}
+
+func TestWriteLUTMatchesGenerate(t *testing.T) {
+	for name, edit := range map[string]func(c *Config){
+		"cube":        func(c *Config) { c.Size, c.Look = 9, "tealOrange" },
+		"3dl":         func(c *Config) { c.Size, c.OutputFormat = 9, "3dl" },
+		"vlt":         func(c *Config) { c.Size, c.OutputFormat = vltSize, "vlt" },
+		"crlf":        func(c *Config) { c.Size, c.LineEnding = 5, "crlf" },
+		"shaper_only": func(c *Config) { c.ShaperOnly, c.ShaperSize = true, 16 },
+		"dctl":        func(c *Config) { c.OutputFormat = "dctl" },
+		"hald":        func(c *Config) { c.Size, c.OutputFormat = 4, "hald" },
+		"look_pair":   func(c *Config) { c.Size, c.Look, c.LookPair = 9, "warmVintage", true },
+	} {
+		cfg := defaultConfig(t, edit)
+		var streamed strings.Builder
+		if err := WriteLUT(&streamed, cfg); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		generated, err := Generate(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if streamed.String() != generated {
+			t.Errorf("%s: WriteLUT and Generate differ", name)
+		}
+		if cfg.LookPair {
+			if look, _, _ := GenerateLookPair(cfg); generated != look {
+				t.Errorf("%s: Generate is not the look LUT of GenerateLookPair", name)
+			}
+		}
+	}
+
+	var out strings.Builder
+	if err := WriteLUT(&out, Config{Size: 1}); err == nil || out.Len() != 0 {
+		t.Errorf("invalid config: error %v, wrote %d bytes", err, out.Len())
+	}
+}
@@ -0,0 +1,75 @@
+package luts
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// montageTile is the approximate width and height in pixels of one slice in
+// a slice montage.
+const montageTile = 128
+
+// montageGap is the width in pixels of the border between montage tiles.
+const montageGap = 1
+
+// montageBackground is the color of the border between montage tiles.
+var montageBackground = color.NRGBA{R: 128, G: 128, B: 128, A: 255}
+
+// montageLayout describes how the grid nodes of a cube of Size are laid out
+// in a slice montage: one Size x Size tile per blue slice, Columns tiles per
+// row, with each node drawn as a Scale x Scale pixel square.
+type montageLayout struct {
+	Size, Columns, Rows, Scale int
+}
+
+// newMontageLayout returns the slice montage layout for a cube of size,
+// with the tiles arranged in a near-square grid.
+func newMontageLayout(size int) montageLayout {
+	cols := int(math.Ceil(math.Sqrt(float64(size))))
+	return montageLayout{
+		Size:    size,
+		Columns: cols,
+		Rows:    (size + cols - 1) / cols,
+		Scale:   max(1, montageTile/size),
+	}
+}
+
+// bounds returns the pixel bounds of the whole montage.
+func (l montageLayout) bounds() image.Rectangle {
+	tile := l.Size * l.Scale
+	return image.Rect(0, 0, l.Columns*tile+(l.Columns-1)*montageGap, l.Rows*tile+(l.Rows-1)*montageGap)
+}
+
+// node returns the top-left pixel of the node at grid coordinate (r, g, b).
+// Within a tile red increases to the right and green downward; tiles run left
+// to right, then top to bottom, in increasing blue.
+func (l montageLayout) node(r, g, b int) image.Point {
+	tile := l.Size*l.Scale + montageGap
+	return image.Pt((b%l.Columns)*tile+r*l.Scale, (b/l.Columns)*tile+g*l.Scale)
+}
+
+// sliceMontage renders the nodes of a cube of size as a slice montage, with
+// each node painted the color returned by paint.
+func sliceMontage(size int, paint func(r, g, b int) color.NRGBA) *image.NRGBA {
+	l := newMontageLayout(size)
+	img := image.NewNRGBA(l.bounds())
+	for i := 0; i < len(img.Pix); i += 4 {
+		bg := montageBackground
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = bg.R, bg.G, bg.B, bg.A
+	}
+	parallelSlices(size, func(b int) {
+		for g := 0; g < size; g++ {
+			for r := 0; r < size; r++ {
+				c := paint(r, g, b)
+				p := l.node(r, g, b)
+				for y := 0; y < l.Scale; y++ {
+					for x := 0; x < l.Scale; x++ {
+						img.SetNRGBA(p.X+x, p.Y+y, c)
+					}
+				}
+			}
+		}
+	})
+	return img
+}
@@ -0,0 +1,196 @@
+package luts
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseCube reads a 3D .cube LUT in the node order FormatCube writes (red
+// slowest, blue fastest), including cubes with a 1D shaper pre-LUT in the
+// layout writeShapedCubeHeader produces. Comments and blank lines are
+// skipped. TITLE is returned in the cube's Title, and DOMAIN_MIN/DOMAIN_MAX
+// or LUT_3D_INPUT_RANGE (or, for shaped cubes, LUT_1D_INPUT_RANGE) set its
+// domain; per-channel domains must be equal, and a cube giving both forms
+// must give the same range in each. Errors name the offending line where there is one.
+func ParseCube(r io.Reader) (*Cube, error) {
+	cube := &Cube{}
+	shaperSize := 0
+	domain := [2]float64{0, 1}
+	sawDomain := false
+	var nodes [][3]float64
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if n == 1 {
+			line = strings.TrimPrefix(line, "\uFEFF") // Byte order mark written by some Windows editors
+		}
+		line = strings.TrimSpace(line)
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var err error
+		switch fields[0] {
+		case "TITLE":
+			cube.Title = parseCubeString(strings.TrimSpace(strings.TrimPrefix(line, "TITLE")))
+		case "LUT_3D_SIZE":
+			cube.Size, err = parseSizeLine(fields)
+		case "LUT_1D_SIZE":
+			shaperSize, err = parseSizeLine(fields)
+		case "DOMAIN_MIN", "DOMAIN_MAX":
+			var v float64
+			sawDomain = true
+			if v, err = parseDomainLine(fields); err == nil && fields[0] == "DOMAIN_MIN" {
+				domain[0] = v
+			} else if err == nil {
+				domain[1] = v
+			}
+		case "LUT_1D_INPUT_RANGE", "LUT_3D_INPUT_RANGE":
+			var lo, hi float64
+			if lo, hi, err = parseRangeLine(fields); err != nil {
+				break
+			}
+			switch {
+			case fields[0] == "LUT_1D_INPUT_RANGE":
+				domain = [2]float64{lo, hi}
+			case shaperSize > 0:
+				if lo != 0 || hi != 1 {
+					err = fmt.Errorf("LUT_3D_INPUT_RANGE other than 0.0 1.0 is not supported after a 1D shaper")
+				}
+			case sawDomain && domain != [2]float64{lo, hi}:
+				err = fmt.Errorf("LUT_3D_INPUT_RANGE %g %g disagrees with DOMAIN_MIN/DOMAIN_MAX %g %g", lo, hi, domain[0], domain[1])
+			default:
+				domain = [2]float64{lo, hi}
+			}
+		default:
+			var node [3]float64
+			if node, err = parseNodeLine(fields); err == nil {
+				if cube.Size == 0 && shaperSize == 0 {
+					err = fmt.Errorf("data before LUT_3D_SIZE")
+				}
+				nodes = append(nodes, node)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if cube.Size == 0 {
+		return nil, fmt.Errorf("missing LUT_3D_SIZE")
+	}
+	if want := shaperSize + cube.Size*cube.Size*cube.Size; len(nodes) != want {
+		return nil, fmt.Errorf("expected %d entries for 1D size %d and 3D size %d, got %d", want, shaperSize, cube.Size, len(nodes))
+	}
+	if domain[0] >= domain[1] {
+		return nil, fmt.Errorf("domain minimum %g must be below its maximum %g", domain[0], domain[1])
+	}
+	cube.DomainMin, cube.DomainMax = domain[0], domain[1]
+	if shaperSize > 0 {
+		cube.Shaper = make([]float64, shaperSize)
+		for i, v := range nodes[:shaperSize] {
+			if v[0] != v[1] || v[1] != v[2] {
+				return nil, fmt.Errorf("1D entry %d differs between channels, which is not supported", i+1)
+			}
+			cube.Shaper[i] = v[0]
+		}
+	}
+	cube.Data = nodes[shaperSize:]
+	return cube, nil
+}
+
+// parseSizeLine parses a LUT_3D_SIZE or LUT_1D_SIZE line.
+func parseSizeLine(fields []string) (int, error) {
+	if len(fields) != 2 {
+		return 0, fmt.Errorf("malformed %s", fields[0])
+	}
+	size, err := strconv.Atoi(fields[1])
+	if err != nil || size < 2 {
+		return 0, fmt.Errorf("invalid %s %q", fields[0], fields[1])
+	}
+	return size, nil
+}
+
+// parseNodeLine parses a data line of three values.
+func parseNodeLine(fields []string) ([3]float64, error) {
+	var node [3]float64
+	if len(fields) != 3 {
+		return node, fmt.Errorf("expected 3 values, got %d", len(fields))
+	}
+	for c, f := range fields {
+		v, err := parseFinite(f)
+		if err != nil {
+			return node, err
+		}
+		node[c] = v
+	}
+	return node, nil
+}
+
+// parseFinite parses a number, rejecting the NaN and infinite values
+// strconv.ParseFloat accepts, which no LUT can hold.
+func parseFinite(s string) (float64, error) {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("value %q is not finite", s)
+	}
+	return v, nil
+}
+
+// parseRangeLine parses a LUT_1D_INPUT_RANGE or LUT_3D_INPUT_RANGE line.
+func parseRangeLine(fields []string) (lo, hi float64, err error) {
+	if len(fields) != 3 {
+		return 0, 0, fmt.Errorf("malformed %s", fields[0])
+	}
+	if lo, err = parseFinite(fields[1]); err == nil {
+		hi, err = parseFinite(fields[2])
+	}
+	if err != nil {
+		return 0, 0, fmt.Errorf("%s: %w", fields[0], err)
+	}
+	return lo, hi, nil
+}
+
+// parseCubeString reverses quoteCubeString. Unquoted strings, as some tools
+// write them, are returned as they are.
+func parseCubeString(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseDomainLine parses a DOMAIN_MIN or DOMAIN_MAX line, which must give the
+// same value for all three channels.
+func parseDomainLine(fields []string) (float64, error) {
+	if len(fields) != 4 {
+		return 0, fmt.Errorf("malformed %s", fields[0])
+	}
+	var v [3]float64
+	for c, f := range fields[1:] {
+		var err error
+		if v[c], err = parseFinite(f); err != nil {
+			return 0, fmt.Errorf("%s: %w", fields[0], err)
+		}
+	}
+	if v[0] != v[1] || v[1] != v[2] {
+		return 0, fmt.Errorf("%s differs between channels, which is not supported", fields[0])
+	}
+	return v[0], nil
+}
@@ -0,0 +1,77 @@
+package luts
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseCubeRoundTrip(t *testing.T) {
+	for name, edit := range map[string]func(c *Config){
+		"default":    func(c *Config) { c.Look = "tealOrange" },
+		"domain":     func(c *Config) { c.DomainMin, c.DomainMax = -0.1, 1.2 },
+		"shaper":     func(c *Config) { c.ShaperSize = 64 },
+		"tab, crlf":  func(c *Config) { c.Separator, c.LineEnding, c.Title = "tab", "crlf", "Shot 12" },
+		"precision8": func(c *Config) { c.Precision = 8 },
+	} {
+		cfg := defaultConfig(t, func(c *Config) { c.Size = 5; edit(c) })
+		cube, _ := BuildCube(cfg)
+		parsed, err := ParseCube(strings.NewReader(FormatCube(cfg, cube)))
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if parsed.Size != cube.Size || parsed.DomainMin != cfg.DomainMin || parsed.DomainMax != cfg.DomainMax {
+			t.Errorf("%s: parsed size %d domain [%g, %g], want %d [%g, %g]", name, parsed.Size, parsed.DomainMin, parsed.DomainMax, cube.Size, cfg.DomainMin, cfg.DomainMax)
+		}
+		if parsed.Title != cubeTitle(cfg) {
+			t.Errorf("%s: parsed title %q, want %q", name, parsed.Title, cubeTitle(cfg))
+		}
+		if len(parsed.Shaper) != len(cube.Shaper) {
+			t.Errorf("%s: parsed %d shaper entries, want %d", name, len(parsed.Shaper), len(cube.Shaper))
+		}
+		tol := 0.5e-6
+		if cfg.Precision == 8 {
+			tol = 0.5e-8
+		}
+		for n := range cube.Data {
+			if !nearRGB(parsed.Data[n], cube.Data[n], tol) {
+				t.Errorf("%s: node %d parsed as %v, want %v", name, n, parsed.Data[n], cube.Data[n])
+				break
+			}
+		}
+	}
+}
+
+func TestParseCubeTolerated(t *testing.T) {
+	src := "\uFEFF# written by hand\nTITLE \"Hand\"\n\nLUT_3D_SIZE 2\n# nodes\n" +
+		"0 0 0\n0 0 1\n0 1 0\n0 1 1\n\n1 0 0\n1 0 1\n1 1 0\n   1 1 1   \n"
+	cube, err := ParseCube(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cube.Title != "Hand" || cube.Size != 2 || len(cube.Data) != 8 || cube.Data[7] != [3]float64{1, 1, 1} {
+		t.Errorf("parsed %q size %d with %d nodes ending %v", cube.Title, cube.Size, len(cube.Data), cube.Data[len(cube.Data)-1])
+	}
+}
+
+func TestParseCubeErrors(t *testing.T) {
+	for _, tc := range []struct{ name, src, want string }{
+		{"truncated", "LUT_3D_SIZE 2\n0 0 0\n0 0 1\n", "expected 8 entries"},
+		{"too many", "LUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 9), "got 9"},
+		{"missing size", "0 0 0\n", "line 1: data before LUT_3D_SIZE"},
+		{"empty", "# nothing\n", "missing LUT_3D_SIZE"},
+		{"bad value", "LUT_3D_SIZE 2\n0 zero 0\n", "line 2"},
+		{"short line", "LUT_3D_SIZE 2\n0 0\n", "line 2"},
+		{"nan value", "LUT_3D_SIZE 2\n0 nan 0\n", `line 2: value "nan" is not finite`},
+		{"inf value", "LUT_3D_SIZE 2\n0 0 0\n-inf 0 0\n", `line 3: value "-inf" is not finite`},
+		{"nan domain", "DOMAIN_MIN nan nan nan\nLUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 8), `line 1: DOMAIN_MIN: value "nan" is not finite`},
+		{"inf domain", "DOMAIN_MAX inf inf inf\nLUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 8), `line 1: DOMAIN_MAX: value "inf" is not finite`},
+		{"inf range", "LUT_3D_INPUT_RANGE 0 inf\nLUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 8), `line 1: LUT_3D_INPUT_RANGE: value "inf" is not finite`},
+		{"inverted domain", "DOMAIN_MIN 1 1 1\nDOMAIN_MAX 0 0 0\nLUT_3D_SIZE 2\n" + strings.Repeat("0 0 0\n", 8), "must be below"},
+	} {
+		_, err := ParseCube(strings.NewReader(tc.src))
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package luts
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Sample is one measured input to output color pair, such as a patch read by
+// a calibration probe. Inputs and outputs are encoded values in [0,1].
+type Sample struct {
+	In, Out [3]float64
+}
+
+// sampleCornerTolerance is how close a sample input must be to a corner of
+// the unit cube to count as measuring it.
+const sampleCornerTolerance = 1e-3
+
+// ParseSamples reads measured samples from CSV rows of six numbers: the
+// input red, green, and blue followed by the output red, green, and blue. A
+// first row that is not numeric is taken as a header and skipped. Inputs
+// must lie in [0,1], and the eight corners of the input cube must be among
+// them so the fitted LUT is anchored at its extremes.
+func ParseSamples(r io.Reader) ([]Sample, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = 6
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	var samples []Sample
+	for n, record := range records {
+		if _, err := strconv.ParseFloat(strings.TrimSpace(record[0]), 64); err != nil && n == 0 {
+			continue // Header row
+		}
+		s, err := parseSampleRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", n+1, err)
+		}
+		samples = append(samples, s)
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no samples")
+	}
+	for corner := 0; corner < 8; corner++ {
+		want := [3]float64{float64(corner >> 2 & 1), float64(corner >> 1 & 1), float64(corner & 1)}
+		if !hasSampleAt(samples, want) {
+			return nil, fmt.Errorf("missing a sample at input corner %g %g %g; all 8 corners of the cube are required", want[0], want[1], want[2])
+		}
+	}
+	return samples, nil
+}
+
+// parseSampleRecord parses the six values of one CSV row.
+func parseSampleRecord(record []string) (Sample, error) {
+	var v [6]float64
+	for i, field := range record {
+		f, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return Sample{}, fmt.Errorf("column %d: %w", i+1, err)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return Sample{}, fmt.Errorf("column %d: value %g is not finite", i+1, f)
+		}
+		v[i] = f
+	}
+	s := Sample{In: [3]float64{v[0], v[1], v[2]}, Out: [3]float64{v[3], v[4], v[5]}}
+	for c, in := range s.In {
+		if in < 0 || in > 1 {
+			return Sample{}, fmt.Errorf("column %d: input %g is outside [0, 1]", c+1, in)
+		}
+	}
+	return s, nil
+}
+
+// hasSampleAt reports whether a sample input lies within
+// sampleCornerTolerance of p along every channel.
+func hasSampleAt(samples []Sample, p [3]float64) bool {
+	for _, s := range samples {
+		if math.Abs(s.In[0]-p[0]) <= sampleCornerTolerance &&
+			math.Abs(s.In[1]-p[1]) <= sampleCornerTolerance &&
+			math.Abs(s.In[2]-p[2]) <= sampleCornerTolerance {
+			return true
+		}
+	}
+	return false
+}
+
+// FitCube builds a size^3 cube over [0,1] that fits samples, as returned by
+// ParseSamples. An affine map from input to output is fitted by least
+// squares, and what it leaves over at each sample is spread across the grid
+// by inverse-distance weighting. The cube therefore reproduces a linear
+// mapping exactly and follows the measurements wherever they deviate from
+// one, passing through any sample that falls on a grid node.
+func FitCube(samples []Sample, size int) *Cube {
+	affine := fitAffine(samples)
+	residuals := make([][3]float64, len(samples))
+	for n, s := range samples {
+		fit := applyAffine(affine, s.In)
+		for c := range fit {
+			residuals[n][c] = s.Out[c] - fit[c]
+		}
+	}
+
+	cube := &Cube{Size: size, Data: make([][3]float64, size*size*size), DomainMax: 1}
+	parallelSlices(size, func(i int) {
+		for j := 0; j < size; j++ {
+			for k := 0; k < size; k++ {
+				p := [3]float64{float64(i) / float64(size-1), float64(j) / float64(size-1), float64(k) / float64(size-1)}
+				node := applyAffine(affine, p)
+				offset := interpolateResiduals(samples, residuals, p)
+				for c := range node {
+					node[c] += offset[c]
+				}
+				cube.Data[cube.index(i, j, k)] = node
+			}
+		}
+	})
+	return cube
+}
+
+// sampleIDWPower is the inverse-distance weighting exponent used by FitCube:
+// higher values make each sample's influence more local.
+const sampleIDWPower = 2
+
+// interpolateResiduals returns the inverse-distance weighted average of the
+// residuals at p, or the residual of a sample at p itself.
+func interpolateResiduals(samples []Sample, residuals [][3]float64, p [3]float64) [3]float64 {
+	var sum [3]float64
+	var weights float64
+	for n, s := range samples {
+		dr, dg, db := s.In[0]-p[0], s.In[1]-p[1], s.In[2]-p[2]
+		d2 := dr*dr + dg*dg + db*db
+		if d2 < 1e-18 {
+			return residuals[n]
+		}
+		w := 1 / math.Pow(d2, sampleIDWPower/2.0)
+		weights += w
+		for c := range sum {
+			sum[c] += w * residuals[n][c]
+		}
+	}
+	for c := range sum {
+		sum[c] /= weights
+	}
+	return sum
+}
+
+// fitAffine returns the least-squares affine map from sample inputs to
+// outputs, as one row of [r, g, b, offset] coefficients per output channel.
+// The eight corner samples ParseSamples requires keep the fit well posed.
+func fitAffine(samples []Sample) [3][4]float64 {
+	// Normal equations: (X^T X) a = X^T y, with one row [r g b 1] per sample.
+	var xtx [4][4]float64
+	var xty [3][4]float64
+	for _, s := range samples {
+		x := [4]float64{s.In[0], s.In[1], s.In[2], 1}
+		for i := range x {
+			for j := range x {
+				xtx[i][j] += x[i] * x[j]
+			}
+			for c := range xty {
+				xty[c][i] += x[i] * s.Out[c]
+			}
+		}
+	}
+	var affine [3][4]float64
+	for c := range affine {
+		affine[c] = solve4(xtx, xty[c])
+	}
+	return affine
+}
+
+// applyAffine evaluates the affine map m at input p.
+func applyAffine(m [3][4]float64, p [3]float64) [3]float64 {
+	var out [3]float64
+	for c := range out {
+		out[c] = m[c][0]*p[0] + m[c][1]*p[1] + m[c][2]*p[2] + m[c][3]
+	}
+	return out
+}
+
+// solve4 solves the 4x4 linear system a x = b by Gaussian elimination with
+// partial pivoting. A singular system yields zeros for the free unknowns.
+func solve4(a [4][4]float64, b [4]float64) [4]float64 {
+	for col := 0; col < 4; col++ {
+		pivot := col
+		for row := col + 1; row < 4; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		b[col], b[pivot] = b[pivot], b[col]
+		if a[col][col] == 0 {
+			continue
+		}
+		for row := col + 1; row < 4; row++ {
+			f := a[row][col] / a[col][col]
+			for k := col; k < 4; k++ {
+				a[row][k] -= f * a[col][k]
+			}
+			b[row] -= f * b[col]
+		}
+	}
+	var x [4]float64
+	for row := 3; row >= 0; row-- {
+		if a[row][row] == 0 {
+			continue
+		}
+		sum := b[row]
+		for k := row + 1; k < 4; k++ {
+			sum -= a[row][k] * x[k]
+		}
+		x[row] = sum / a[row][row]
+	}
+	return x
+}
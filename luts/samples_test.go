@@ -0,0 +1,56 @@
+package luts
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// linearMap is the known affine mapping the sample tests try to recover.
+func linearMap(v [3]float64) [3]float64 {
+	return [3]float64{0.1 + 0.8*v[0], 0.6*v[1] + 0.2*v[2], 0.9 - 0.5*v[2] + 0.1*v[0]}
+}
+
+// samplesCSV writes samples of f at the cube corners and a few interior
+// points, under a header row.
+func samplesCSV(f func([3]float64) [3]float64) string {
+	var sb strings.Builder
+	sb.WriteString("in_r,in_g,in_b,out_r,out_g,out_b\n")
+	points := [][3]float64{{0.3, 0.6, 0.2}, {0.5, 0.5, 0.5}, {0.8, 0.1, 0.4}}
+	for corner := 0; corner < 8; corner++ {
+		points = append(points, [3]float64{float64(corner >> 2 & 1), float64(corner >> 1 & 1), float64(corner & 1)})
+	}
+	for _, p := range points {
+		o := f(p)
+		fmt.Fprintf(&sb, "%g,%g,%g,%g,%g,%g\n", p[0], p[1], p[2], o[0], o[1], o[2])
+	}
+	return sb.String()
+}
+
+func TestFitCubeRecoversLinearMap(t *testing.T) {
+	samples, err := ParseSamples(strings.NewReader(samplesCSV(linearMap)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cube := FitCube(samples, 9)
+	for _, p := range [][3]float64{{0, 0, 0}, {1, 1, 1}, {0.25, 0.75, 0.5}, {0.6, 0.3, 0.9}} {
+		if got, want := cube.sample(p[0], p[1], p[2]), linearMap(p); !nearRGB(got, want, 1e-9) {
+			t.Errorf("fitted cube maps %v to %v, want %v", p, got, want)
+		}
+	}
+}
+
+func TestParseSamplesErrors(t *testing.T) {
+	corners := samplesCSV(linearMap)
+	for _, tc := range []struct{ name, csv, want string }{
+		{"missing corner", strings.Replace(corners, "1,1,1,", "0.9,1,1,", 1), "missing a sample at input corner 1 1 1"},
+		{"short row", corners + "0.5,0.5,0.5,0.5\n", "wrong number of fields"},
+		{"bad number", corners + "0.5,x,0.5,0.5,0.5,0.5\n", "column 2"},
+		{"input out of range", corners + "1.5,0.5,0.5,0.5,0.5,0.5\n", "input 1.5 is outside [0, 1]"},
+		{"empty", "in_r,in_g,in_b,out_r,out_g,out_b\n", "no samples"},
+	} {
+		if _, err := ParseSamples(strings.NewReader(tc.csv)); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: error %v, want one containing %q", tc.name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package luts
+
+import (
+	"reflect"
+	"strings"
+)
+
+// schemaEnums lists the accepted values of string fields, by JSON key. Names
+// are matched case-insensitively by Validate; the schema lists their
+// canonical spelling.
+func schemaEnums() map[string][]string {
+	return map[string][]string{
+		"look":               LookNames(),
+		"target":             targetNames(),
+		"target_color_space": colorSpaceNames(),
+		"output_transfer":    {"rec709", "gamma", "hlg", "pq"},
+		"input_encoding":     {"appleLog", "linear", "srgb"},
+		"gamut_mapping":      {"clip", "compress", "preserveHue"},
+		"clip_mode":          {"perChannel", "preserveHue"},
+		"output_clip":        {"hard", "soft"},
+		"tone_map":           {"none", "reinhard", "aces"},
+		"format":             {"cube", "3dl", "vlt", "hald", "dctl"},
+		"separator":          {"space", "tab"},
+		"line_ending":        {"lf", "crlf"},
+		"shadow_lift_space":  {"encoded", "linear"},
+		"shaper_space":       {"linear", "acescct"},
+	}
+}
+
+// ConfigSchema returns a JSON Schema (draft 2020-12) document describing
+// Config, for editor completion and validation of config files. Field names
+// and types come from the struct definition, defaults from SetDefaults, and
+// enums from the names Validate accepts. Unknown keys are rejected, which
+// catches misspellings such as exposureOffset.
+func ConfigSchema() map[string]any {
+	var cfg Config
+	cfg.SetDefaults()
+	cfg.ZoneLooks.setDefaults()
+	schema := structSchema(reflect.TypeOf(cfg), reflect.ValueOf(cfg), schemaEnums())
+	params := map[string]any{}
+	for _, name := range lookParamNames() {
+		params[name] = map[string]any{"type": "number"}
+	}
+	props := schema["properties"].(map[string]any)
+	props["params"] = map[string]any{
+		"type": "object", "properties": params, "additionalProperties": false,
+	}
+	// The matrix slice holds a row-major 3x3, as validateMatrix requires.
+	props["matrix"].(map[string]any)["minItems"] = 9
+	props["matrix"].(map[string]any)["maxItems"] = 9
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "loglutgen config"
+	return schema
+}
+
+// structSchema describes the JSON-tagged fields of struct type t. defaults,
+// if valid, holds a value of t whose fields are reported as defaults.
+func structSchema(t reflect.Type, defaults reflect.Value, enums map[string][]string) map[string]any {
+	props := map[string]any{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if !f.IsExported() || key == "" || key == "-" {
+			continue
+		}
+		var def reflect.Value
+		if defaults.IsValid() {
+			def = defaults.Field(i)
+		}
+		prop := typeSchema(f.Type, def, enums)
+		if values, ok := enums[key]; ok {
+			prop["enum"] = values
+		}
+		props[key] = prop
+	}
+	return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+}
+
+// typeSchema describes a value of type t, with def as its default if valid.
+func typeSchema(t reflect.Type, def reflect.Value, enums map[string][]string) map[string]any {
+	var s map[string]any
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem(), reflect.Value{}, enums) // nil means "use the default"
+	case reflect.Struct:
+		return structSchema(t, def, enums)
+	case reflect.Int:
+		s = map[string]any{"type": "integer"}
+	case reflect.Float64:
+		s = map[string]any{"type": "number"}
+	case reflect.Bool:
+		s = map[string]any{"type": "boolean"}
+	case reflect.String:
+		s = map[string]any{"type": "string"}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem(), reflect.Value{}, enums)}
+	case reflect.Array:
+		s = map[string]any{"type": "array", "items": typeSchema(t.Elem(), reflect.Value{}, enums),
+			"minItems": t.Len(), "maxItems": t.Len()}
+	default:
+		return map[string]any{}
+	}
+	if def.IsValid() && !(t.Kind() == reflect.String && def.String() == "") {
+		s["default"] = def.Interface()
+	}
+	return s
+}
@@ -0,0 +1,64 @@
+package luts
+
+import "math"
+
+// shaperInverseIterations is the number of bisection steps used to find the
+// input value behind each 3D grid coordinate of a shaped cube.
+const shaperInverseIterations = 60
+
+// shaperCurve is the neutral transfer of the plain conversion, without looks
+// or shadow lift. As a pre-LUT it spreads the 3D grid evenly over the output
+// tones, so the steep shadow region of Apple Log gets more nodes.
+func shaperCurve(cfg Config, x float64) float64 {
+	t, err := cfg.displayTarget()
+	if err != nil {
+		t = displayTargets["rec709"]
+	}
+	return encodeTransfer(t, decodeInput(cfg, x))
+}
+
+// buildShaper samples shaperCurve over the input domain, normalized so the
+// domain maps onto [0,1], into a table of cfg.ShaperSize entries. It also
+// returns the inverse of the normalized curve, which BuildCube uses to place
+// each 3D node at the input value the shaper maps onto it.
+func buildShaper(cfg Config) ([]float64, func(s float64) float64) {
+	lo, hi := cfg.DomainMin, cfg.DomainMax
+	fLo, fHi := shaperCurve(cfg, lo), shaperCurve(cfg, hi)
+	normalized := func(x float64) float64 {
+		if fHi <= fLo {
+			return (x - lo) / (hi - lo)
+		}
+		return (shaperCurve(cfg, x) - fLo) / (fHi - fLo)
+	}
+
+	table := make([]float64, cfg.ShaperSize)
+	for i := range table {
+		table[i] = normalized(lo + float64(i)/float64(cfg.ShaperSize-1)*(hi-lo))
+	}
+
+	// The curve is non-decreasing, so bisection finds the lowest input that
+	// reaches s; inputs on a clipped plateau all share the same output.
+	inverse := func(s float64) float64 {
+		a, b := lo, hi
+		for n := 0; n < shaperInverseIterations; n++ {
+			mid := (a + b) / 2
+			if normalized(mid) < s {
+				a = mid
+			} else {
+				b = mid
+			}
+		}
+		return b
+	}
+	return table, inverse
+}
+
+// shaperLookup maps an input value through the 1D table, which spans the
+// domain [lo, hi], with linear interpolation. Inputs outside are clamped.
+func shaperLookup(table []float64, lo, hi, x float64) float64 {
+	n := len(table) - 1
+	v := math.Min(math.Max((x-lo)/(hi-lo), 0), 1) * float64(n)
+	i := min(int(v), n-1)
+	f := v - float64(i)
+	return table[i] + (table[i+1]-table[i])*f
+}
@@ -0,0 +1,62 @@
+package luts
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// dataLines returns the numeric triplets of a generated LUT, skipping the
+// header and comment lines.
+func dataLines(t testing.TB, lut string) [][3]float64 {
+	t.Helper()
+	var rows [][3]float64
+	for _, line := range strings.Split(strings.TrimSpace(lut), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		var row [3]float64
+		for c, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				row[0] = math.NaN()
+				break
+			}
+			row[c] = v
+		}
+		if !math.IsNaN(row[0]) {
+			rows = append(rows, row)
+		}
+	}
+	return rows
+}
+
+func TestShaperRoundTrip(t *testing.T) {
+	acescctToLinear := func(v float64) float64 {
+		if v <= 0.155251141552511 {
+			return (v - 0.0729055341958355) / 10.5402377416545
+		}
+		return math.Exp2(v*17.52 - 9.72)
+	}
+	for _, tc := range []struct {
+		space   string
+		inverse func(v float64) float64
+	}{
+		{"linear", linearToAppleLog},
+		{"acescct", func(v float64) float64 { return linearToAppleLog(acescctToLinear(v)) }},
+	} {
+		cfg := defaultConfig(t, func(c *Config) { c.ShaperOnly, c.ShaperSize, c.ShaperSpace = true, 64, tc.space })
+		rows := dataLines(t, GenerateShaper(cfg))
+		if len(rows) != 64 {
+			t.Fatalf("%s: %d entries, want 64", tc.space, len(rows))
+		}
+		for i, row := range rows {
+			in := float64(i) / 63
+			if got := tc.inverse(row[0]); !near(got, in, 1e-4) {
+				t.Errorf("%s: entry %d inverts to %g, want %g", tc.space, i, got, in)
+			}
+		}
+	}
+}
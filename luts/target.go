@@ -0,0 +1,248 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// displayTarget is the primaries and transfer function a LUT encodes for.
+type displayTarget struct {
+	Name      string  // Display name, e.g. "Rec.709"
+	Primaries string  // "rec709", "p3d65", or "ap1"
+	Transfer  string  // "rec709" (Rec.709 OETF), "srgb" (sRGB OETF), "gamma2.4"/"gamma2.6" (pure power law), "gamma" (power law of Gamma), "hlg", "pq", or "acescct"
+	Unbounded bool    // Scene-referred working space: linear values above 1.0 are encoded rather than clipped
+	Gamma     float64 // Display gamma, for the "gamma" transfer
+	PeakNits  float64 // Luminance of linear 1.0, for the "pq" transfer
+}
+
+// displayTargets maps the lowercased Target names to what they resolve to.
+var displayTargets = map[string]displayTarget{
+	"rec709": {Name: "Rec.709", Primaries: "rec709", Transfer: "rec709"},
+	// Apple's Reference Mode on iPad Pro and Pro Display XDR shows SDR
+	// video with P3-D65 primaries and the BT.1886 (gamma 2.4) transfer.
+	"applereference": {Name: "Apple Reference Mode", Primaries: "p3d65", Transfer: "gamma2.4"},
+	// Web delivery uses the sRGB primaries (shared with Rec.709) and the
+	// piecewise sRGB curve.
+	"srgb": {Name: "sRGB", Primaries: "rec709", Transfer: "srgb"},
+	// Theatrical P3 is mastered for a gamma 2.6 projector; this uses the
+	// D65 white point rather than DCI's greenish one.
+	"p3d65": {Name: "P3-D65", Primaries: "p3d65", Transfer: "gamma2.6"},
+	// ACEScct is a grading working space for VFX interchange, not a
+	// display: AP1 primaries with the ACEScct log curve, which encodes
+	// linear values well above 1.0.
+	"acescct": {Name: "ACEScct", Primaries: "ap1", Transfer: "acescct", Unbounded: true},
+}
+
+// targetNames returns the accepted Target values, sorted.
+func targetNames() []string {
+	names := []string{"rec709", "appleReference", "srgb", "p3d65"}
+	sort.Strings(names)
+	return names
+}
+
+// colorSpaceNames returns the accepted TargetColorSpace values.
+func colorSpaceNames() []string {
+	return []string{"rec709", "srgb", "p3d65", "acescct"}
+}
+
+// displayTarget resolves the target the config encodes for: TargetColorSpace
+// when it is set, otherwise Target, with its transfer replaced by
+// OutputTransfer when that is set.
+func (c *Config) displayTarget() (displayTarget, error) {
+	t, err := c.colorSpaceTarget()
+	if err != nil {
+		return displayTarget{}, err
+	}
+	if c.OutputTransfer != "" {
+		t.Transfer = strings.ToLower(c.OutputTransfer)
+	}
+	t.Gamma = c.OutputGamma
+	t.PeakNits = c.PeakNits
+	return t, nil
+}
+
+// colorSpaceTarget returns the target named by TargetColorSpace or Target.
+func (c *Config) colorSpaceTarget() (displayTarget, error) {
+	if c.TargetColorSpace == "" {
+		return resolveTarget(c.Target)
+	}
+	for _, name := range colorSpaceNames() {
+		if strings.EqualFold(c.TargetColorSpace, name) {
+			return displayTargets[name], nil
+		}
+	}
+	return displayTarget{}, fmt.Errorf("unknown target_color_space %q (valid: %s)",
+		c.TargetColorSpace, strings.Join(colorSpaceNames(), ", "))
+}
+
+// resolveTarget looks up the display target named by cfg.Target.
+func resolveTarget(name string) (displayTarget, error) {
+	t, ok := displayTargets[strings.ToLower(name)]
+	if !ok {
+		return displayTarget{}, fmt.Errorf("unknown target %q (valid: %s)", name, strings.Join(targetNames(), ", "))
+	}
+	return t, nil
+}
+
+// TargetWarnings reports settings that suit display output but not the
+// scene-referred working space the config targets, such as a creative look
+// with target_color_space "acescct": the looks and curves assume display
+// encoded values and clip to [0,1].
+func TargetWarnings(cfg Config) []string {
+	t, err := cfg.displayTarget()
+	if err != nil || !t.Unbounded {
+		return nil
+	}
+	var warnings []string
+	if !strings.EqualFold(cfg.Look, "none") || cfg.ZoneLooks.enabled() || cfg.LookExpr != "" {
+		warnings = append(warnings, fmt.Sprintf("creative looks are designed for display output; %s is a working space, so grade the look downstream instead", cfg.TargetColorSpace))
+	}
+	return warnings
+}
+
+// lumaWeights maps each Primaries value to the luminance (Y) weights of its
+// red, green, and blue primaries.
+var lumaWeights = map[string][3]float64{
+	"rec709": {0.2126, 0.7152, 0.0722},
+	"p3d65":  {0.228975, 0.691739, 0.079287},
+	"ap1":    {0.272229, 0.674082, 0.053690},
+}
+
+// lumaWeights returns the luminance weights of the primaries the config
+// encodes for, which the looks use to tell shadows from highlights.
+func (c *Config) lumaWeights() [3]float64 {
+	t, err := c.displayTarget()
+	if err != nil {
+		return lumaWeights["rec709"]
+	}
+	return lumaWeights[t.Primaries]
+}
+
+// luma returns the weighted sum of r, g, and b.
+func luma(w [3]float64, r, g, b float64) float64 {
+	return w[0]*r + w[1]*g + w[2]*b
+}
+
+// Linear RGB conversion matrices, row-major.
+var (
+	matRec2020ToRec709 = [9]float64{
+		1.660, -0.587, -0.073,
+		-0.124, 1.132, -0.008,
+		-0.018, -0.100, 1.118,
+	}
+	matRec2020ToP3D65 = [9]float64{
+		1.343578, -0.282179, -0.061399,
+		-0.065297, 1.075788, -0.010491,
+		0.002822, -0.019598, 1.016776,
+	}
+	matRec709ToP3D65 = [9]float64{
+		0.822462, 0.177538, 0.000000,
+		0.033194, 0.966806, 0.000000,
+		0.017083, 0.072397, 0.910520,
+	}
+	// The AP1 matrices include a Bradford adaptation from D65 to the ACES
+	// white point.
+	matRec2020ToAP1 = [9]float64{
+		0.974895, 0.019599, 0.005506,
+		0.002180, 0.995535, 0.002285,
+		0.004797, 0.024532, 0.970671,
+	}
+	matRec709ToAP1 = [9]float64{
+		0.613097, 0.339523, 0.047379,
+		0.070194, 0.916354, 0.013452,
+		0.020616, 0.109570, 0.869815,
+	}
+)
+
+// applyMatrix multiplies linear RGB by the row-major 3x3 matrix m and clips
+// the result to [0,1].
+func applyMatrix(m [9]float64, r, g, b float64) (float64, float64, float64) {
+	clip := func(v float64) float64 { return math.Min(math.Max(v, 0), 1) }
+	return clip(m[0]*r + m[1]*g + m[2]*b),
+		clip(m[3]*r + m[4]*g + m[5]*b),
+		clip(m[6]*r + m[7]*g + m[8]*b)
+}
+
+// gamutMatrix returns the linear matrix from the input primaries (Rec.2020,
+// or Rec.709 for sRGB input) to the target's primaries, or false when they
+// already match.
+func gamutMatrix(cfg Config, t displayTarget) ([9]float64, bool) {
+	srgbIn := strings.EqualFold(cfg.InputEncoding, "srgb")
+	switch {
+	case t.Primaries == "p3d65" && srgbIn:
+		return matRec709ToP3D65, true
+	case t.Primaries == "p3d65":
+		return matRec2020ToP3D65, true
+	case t.Primaries == "ap1" && srgbIn:
+		return matRec709ToAP1, true
+	case t.Primaries == "ap1":
+		return matRec2020ToAP1, true
+	case srgbIn:
+		return [9]float64{1, 0, 0, 0, 1, 0, 0, 0, 1}, false
+	}
+	return cfg.rec709Matrix(), true
+}
+
+// convertGamut converts linear RGB from the input primaries to the target's
+// primaries (see gamutMatrix). Out-of-gamut results are clipped per channel,
+// compressed when GamutMapping is "compress", or brought into range with
+// their hue kept when it is "preserveHue". Unbounded targets only clip
+// negative values, keeping highlights above 1.0.
+func convertGamut(cfg Config, t displayTarget, r, g, b float64) (float64, float64, float64) {
+	m, convert := gamutMatrix(cfg, t)
+	if strings.EqualFold(cfg.GamutMapping, "preserveHue") && convert {
+		r, g, b = multiplyMatrix(m, r, g, b)
+		return preserveHue(r, g, b, cfg.lumaWeights(), t.Unbounded)
+	}
+	if strings.EqualFold(cfg.GamutMapping, "compress") {
+		if convert {
+			r, g, b = multiplyMatrix(m, r, g, b)
+		}
+		return compressGamut(r, g, b, t.Unbounded)
+	}
+	if !convert {
+		return r, g, b
+	}
+	if t.Unbounded {
+		r, g, b = multiplyMatrix(m, r, g, b)
+		return max(r, 0), max(g, 0), max(b, 0)
+	}
+	return applyMatrix(m, r, g, b)
+}
+
+// gammaEncode applies a pure power-law encoding, the inverse of a display
+// gamma such as BT.1886's 2.4.
+func gammaEncode(linear, gamma float64) float64 {
+	return math.Pow(math.Max(linear, 0), 1/gamma)
+}
+
+// srgbOETF applies the piecewise sRGB encoding, the inverse of srgbToLinear.
+func srgbOETF(linear float64) float64 {
+	if linear <= 0.0031308 {
+		return 12.92 * linear
+	}
+	return 1.055*math.Pow(linear, 1/2.4) - 0.055
+}
+
+// encodeTransfer applies the target's transfer function to a linear value.
+func encodeTransfer(t displayTarget, linear float64) float64 {
+	switch t.Transfer {
+	case "gamma2.4":
+		return gammaEncode(linear, 2.4)
+	case "gamma2.6":
+		return gammaEncode(linear, 2.6)
+	case "gamma":
+		return gammaEncode(linear, t.Gamma)
+	case "srgb":
+		return srgbOETF(linear)
+	case "hlg":
+		return HLGOETF(linear)
+	case "pq":
+		return PQInverseEOTF(linear, t.PeakNits)
+	case "acescct":
+		return linearToACEScct(linear)
+	}
+	return Rec709OETF(linear)
+}
@@ -0,0 +1,82 @@
+package luts
+
+import (
+	"math"
+	"testing"
+)
+
+func TestAppleReferenceTarget(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) { c.Target = "appleReference" })
+	target, err := cfg.displayTarget()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Primaries != "p3d65" || target.Transfer != "gamma2.4" {
+		t.Errorf("appleReference resolves to %s primaries with the %s transfer, want p3d65 and gamma2.4", target.Primaries, target.Transfer)
+	}
+	for _, x := range []float64{0.1, 0.3, 0.5, 0.8} {
+		r, g, b := processPixel(cfg, x, x, x)
+		if !near(r, g, 1e-5) || !near(g, b, 1e-5) {
+			t.Errorf("gray %g maps to %g %g %g, want neutral", x, r, g, b)
+		}
+		if want := gammaEncode(appleLogDecode(x), 2.4); !near(g, want, 1e-4) {
+			t.Errorf("gray %g maps to %g, want the gamma 2.4 encoding %g", x, g, want)
+		}
+	}
+}
+
+func TestACEScctEncoding(t *testing.T) {
+	if got := linearToACEScct(0.18); !near(got, 0.4136, 1e-4) {
+		t.Errorf("ACEScct(0.18) = %.6f, want 0.4136", got)
+	}
+	// The linear toe below the break inverts exactly and meets the log
+	// section at the break.
+	const brk = 0.0078125
+	decodeToe := func(v float64) float64 { return (v - 0.0729055341958355) / 10.5402377416545 }
+	for _, x := range []float64{0, 0.0005, 0.002, 0.005, brk} {
+		if got := decodeToe(linearToACEScct(x)); !near(got, x, 1e-12) {
+			t.Errorf("toe round trip of %g = %g", x, got)
+		}
+	}
+	if toe, log := linearToACEScct(brk), (math.Log2(brk)+9.72)/17.52; !near(toe, log, 1e-6) {
+		t.Errorf("at the break the toe gives %.8f and the log section %.8f", toe, log)
+	}
+
+	cfg := defaultConfig(t, func(c *Config) { c.TargetColorSpace = "acescct" })
+	for _, x := range []float64{0.1, 0.3, 0.5, 0.8} {
+		r, g, b := processPixel(cfg, x, x, x)
+		if !near(r, g, 1e-5) || !near(g, b, 1e-5) {
+			t.Errorf("gray %g maps to %g %g %g, want neutral", x, r, g, b)
+		}
+		if want := linearToACEScct(appleLogDecode(x)); !near(g, want, 1e-4) {
+			t.Errorf("gray %g maps to %g, want the ACEScct encoding %g", x, g, want)
+		}
+	}
+}
+
+func TestGammaTransferDiffersInTheToe(t *testing.T) {
+	rec709 := defaultConfig(t, nil)
+	gamma := defaultConfig(t, func(c *Config) { c.OutputTransfer, c.OutputGamma = "gamma", 2.4 })
+	tested := 0
+	for x := 0.05; x < 0.5; x += 0.01 {
+		lin := appleLogDecode(x)
+		if lin <= 0 || lin >= 0.018 { // Only the Rec.709 linear segment
+			continue
+		}
+		tested++
+		_, g709, _ := processPixel(rec709, x, x, x)
+		_, gGamma, _ := processPixel(gamma, x, x, x)
+		if !near(g709, 4.5*lin, 1e-5) {
+			t.Errorf("rec709: input %g (linear %g) encodes to %g, want the linear toe %g", x, lin, g709, 4.5*lin)
+		}
+		if want := gammaEncode(lin, 2.4); !near(gGamma, want, 1e-5) {
+			t.Errorf("gamma 2.4: input %g (linear %g) encodes to %g, want %g", x, lin, gGamma, want)
+		}
+		if gGamma <= g709+0.01 {
+			t.Errorf("input %g (linear %g): gamma 2.4 gives %g, not clearly above the Rec.709 toe %g", x, lin, gGamma, g709)
+		}
+	}
+	if tested == 0 {
+		t.Fatal("no input decodes into the Rec.709 linear segment")
+	}
+}
@@ -0,0 +1,88 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// toneMaps maps the lowercased ToneMap names to their operators, which take
+// and return linear RGB.
+var toneMaps = map[string]func(r, g, b float64) (float64, float64, float64){
+	"reinhard": reinhardToneMap,
+	"aces":     acesToneMap,
+}
+
+// reinhardToneMap applies the Reinhard operator x/(1+x) per channel. It
+// compresses all of [0,∞) into [0,1), mapping 1.0 to 0.5.
+func reinhardToneMap(r, g, b float64) (float64, float64, float64) {
+	op := func(x float64) float64 {
+		x = max(x, 0)
+		return x / (1 + x)
+	}
+	return op(r), op(g), op(b)
+}
+
+// acesToneMap applies Krzysztof Narkowicz's fit of the ACES filmic curve per
+// channel: a toe in the shadows and a shoulder that approaches 1.0.
+func acesToneMap(r, g, b float64) (float64, float64, float64) {
+	op := func(x float64) float64 {
+		x = max(x, 0)
+		return min((x*(2.51*x+0.03))/(x*(2.43*x+0.59)+0.14), 1)
+	}
+	return op(r), op(g), op(b)
+}
+
+// applyToneMap runs linear RGB through cfg's tone mapping operator, if any.
+func applyToneMap(cfg Config, r, g, b float64) (float64, float64, float64) {
+	if op, ok := toneMaps[strings.ToLower(cfg.ToneMap)]; ok {
+		return op(r, g, b)
+	}
+	return r, g, b
+}
+
+// validateToneMap checks that ToneMap names a known operator.
+func (c *Config) validateToneMap() error {
+	if _, ok := toneMaps[strings.ToLower(c.ToneMap)]; !ok && !strings.EqualFold(c.ToneMap, "none") {
+		return fmt.Errorf("unknown tone_map %q (valid: none, reinhard, aces)", c.ToneMap)
+	}
+	return nil
+}
+
+// applyKnee applies a soft highlight shoulder to a linear value: values up to
+// start pass through, and the rest of [start,∞) is compressed into
+// [start,1) along t/(1+t^p)^(1/p), which leaves the slope continuous at the
+// knee. Higher strength p keeps values linear longer and bends more sharply.
+// A start of 1.0 or more disables the knee.
+func applyKnee(x, start, strength float64) float64 {
+	if start >= 1 || x <= start {
+		return x
+	}
+	t := (x - start) / (1 - start)
+	return start + (1-start)*t/math.Pow(1+math.Pow(t, strength), 1/strength)
+}
+
+// Soft output clip shoulder, applied with applyKnee to encoded output values
+// when OutputClip is "soft": values above softClipStart roll off toward 1.0
+// instead of running flat into the clamp.
+const (
+	softClipStart    = 0.9
+	softClipStrength = 2.0
+)
+
+// softClip rolls encoded value v off toward 1.0 above softClipStart; 1.0
+// itself lands at about 0.97.
+func softClip(v float64) float64 {
+	return applyKnee(v, softClipStart, softClipStrength)
+}
+
+// validateKnee checks the KneeStart and KneeStrength ranges.
+func (c *Config) validateKnee() error {
+	if c.KneeStart <= 0 {
+		return fmt.Errorf("knee_start must be positive, got %g", c.KneeStart)
+	}
+	if c.KneeStrength < 1 {
+		return fmt.Errorf("knee_strength must be at least 1, got %g", c.KneeStrength)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package luts
+
+import "testing"
+
+func TestToneMapCompressesHighlights(t *testing.T) {
+	for _, name := range []string{"reinhard", "aces"} {
+		cfg := defaultConfig(t, func(c *Config) { c.ToneMap = name })
+		r, g, b := applyToneMap(cfg, 4, 4, 4)
+		if r >= 1 || r <= 0 || g != r || b != r {
+			t.Errorf("%s maps linear 4.0 to %g %g %g, want one value in (0, 1)", name, r, g, b)
+		}
+		if lo, _, _ := applyToneMap(cfg, 2, 2, 2); lo >= r {
+			t.Errorf("%s maps 2.0 to %g, not below the %g of 4.0", name, lo, r)
+		}
+	}
+	cfg := defaultConfig(t, func(c *Config) { c.ToneMap = "none" })
+	if r, g, b := applyToneMap(cfg, 4, 0.5, 0); r != 4 || g != 0.5 || b != 0 {
+		t.Errorf("none maps 4 0.5 0 to %g %g %g", r, g, b)
+	}
+}
+
+func TestKneeCompressesOnlyAboveStart(t *testing.T) {
+	const start = 0.8
+	for _, x := range []float64{0, 0.3, 0.79, start} {
+		if got := applyKnee(x, start, 2); got != x {
+			t.Errorf("applyKnee(%g) = %g, want unchanged below the knee", x, got)
+		}
+	}
+	prev := start
+	for _, x := range []float64{0.81, 0.9, 1, 1.5, 3, 10} {
+		got := applyKnee(x, start, 2)
+		if got <= prev || got >= x || got >= 1 {
+			t.Errorf("applyKnee(%g) = %g, want in (%g, min(%g, 1))", x, got, prev, x)
+		}
+		prev = got
+	}
+	if got := applyKnee(2, 1, 2); got != 2 {
+		t.Errorf("knee_start 1.0 maps 2 to %g, want it off", got)
+	}
+}
+
+func TestSoftOutputClipRollsOffAboveOne(t *testing.T) {
+	// Two stops over pushes the top of the grid past 1.0, where hard clips
+	// flat at 1.0 and soft must roll the values off below it.
+	hard, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.ExposureStops = 9, 2 }))
+	soft, _ := BuildCube(defaultConfig(t, func(c *Config) { c.Size, c.ExposureStops, c.OutputClip = 9, 2, "soft" }))
+	over := 0
+	for n, h := range hard.Data {
+		for ch := range h {
+			s := soft.Data[n][ch]
+			switch {
+			case h[ch] <= softClipStart && s != h[ch]:
+				t.Errorf("node %d channel %d: soft %g, want the hard %g below the shoulder", n, ch, s, h[ch])
+			case h[ch] > softClipStart && (s >= h[ch] || s >= 1):
+				t.Errorf("node %d channel %d: soft %g, want below both the hard %g and 1.0", n, ch, s, h[ch])
+			}
+			if h[ch] == 1 {
+				over++
+			}
+		}
+	}
+	if over == 0 {
+		t.Fatal("no hard node clips at 1.0; the test does not reach the clip")
+	}
+
+	// Along the gray axis soft never turns back down, however far over.
+	prev := 0.0
+	for i := range hard.Size {
+		n := hard.index(i, i, i)
+		if soft.Data[n][1] < prev {
+			t.Errorf("soft gray node %d = %g, below the previous %g", i, soft.Data[n][1], prev)
+		}
+		prev = soft.Data[n][1]
+	}
+}
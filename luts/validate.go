@@ -0,0 +1,143 @@
+package luts
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// maxReversalsReported caps the reversals listed per channel by ValidateLUT;
+// any beyond it are summarized in a count.
+const maxReversalsReported = 5
+
+// ValidateLUT parses LUT text generated for cfg and reports every place where
+// a channel decreases along its own axis (red along the red axis, and so on).
+// Such reversals survive tetrahedral and trilinear interpolation alike and
+// show up as banding or inverted gradients. 3D LUTs, 1D shapers, combined
+// files with a 1D pre-LUT, and HALD CLUT images are checked; sizes are taken from the LUT header,
+// falling back to cfg.
+func ValidateLUT(cfg Config, data string) ([]string, error) {
+	if strings.EqualFold(cfg.OutputFormat, "hald") {
+		nodes, size, err := haldNodes(data)
+		if err != nil {
+			return nil, err
+		}
+		return cubeReversals(nodes, size), nil
+	}
+	size1, size3 := 0, cfg.Size
+	if cfg.ShaperOnly {
+		size1, size3 = cfg.ShaperSize, 0
+	}
+	meshLine := strings.EqualFold(cfg.OutputFormat, "3dl")
+	var nodes [][3]float64
+	for n, line := range strings.Split(data, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		switch fields[0] {
+		case "LUT_3D_SIZE", "LUT_1D_SIZE":
+			if len(fields) != 2 {
+				return nil, fmt.Errorf("line %d: malformed %s", n+1, fields[0])
+			}
+			v, err := strconv.Atoi(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			if fields[0] == "LUT_1D_SIZE" {
+				size1 = v
+				if !strings.Contains(data, "LUT_3D_SIZE") {
+					size3 = 0
+				}
+			} else {
+				size3 = v
+			}
+			continue
+		}
+		if _, err := strconv.ParseFloat(fields[0], 64); err != nil {
+			continue // Other keywords such as TITLE or DOMAIN_MIN
+		}
+		if meshLine {
+			// The first numeric line of a .3dl lists the mesh points.
+			meshLine, size3 = false, len(fields)
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("line %d: expected 3 values, got %d", n+1, len(fields))
+		}
+		var node [3]float64
+		for c, f := range fields {
+			v, err := strconv.ParseFloat(f, 64)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n+1, err)
+			}
+			node[c] = v
+		}
+		nodes = append(nodes, node)
+	}
+
+	if want := size1 + size3*size3*size3; len(nodes) != want {
+		return nil, fmt.Errorf("expected %d entries for 1D size %d and 3D size %d, got %d", want, size1, size3, len(nodes))
+	}
+
+	var warnings []string
+	if size1 > 0 {
+		prev := func(_, n int) int { return n - 1 }
+		warnings = append(warnings, checkReversals("1D shaper", nodes[:size1], prev, strconv.Itoa)...)
+	}
+	if size3 > 0 {
+		warnings = append(warnings, cubeReversals(nodes[size1:], size3)...)
+	}
+	return warnings, nil
+}
+
+// cubeReversals runs checkReversals over the nodes of a 3D LUT of the given
+// size. Each channel steps back one node along its own axis; red is the
+// slowest-varying axis and blue the fastest.
+func cubeReversals(nodes [][3]float64, size int) []string {
+	strides := [3]int{size * size, size, 1}
+	prev := func(c, n int) int {
+		if (n/strides[c])%size == 0 {
+			return -1
+		}
+		return n - strides[c]
+	}
+	coord := func(n int) string {
+		return fmt.Sprint([3]int{n / strides[0], (n / strides[1]) % size, n % size})
+	}
+	return checkReversals("", nodes, prev, coord)
+}
+
+// checkReversals reports, per channel, the nodes whose value is below that of
+// the node before them. prev returns the index of the node before n along
+// channel c's axis, or -1 if there is none, and coord formats a node index.
+func checkReversals(label string, nodes [][3]float64, prev func(c, n int) int, coord func(n int) string) []string {
+	var warnings []string
+	for c, name := range [3]string{"red", "green", "blue"} {
+		var reversals []string
+		count := 0
+		for n := range nodes {
+			p := prev(c, n)
+			if p < 0 || nodes[n][c] >= nodes[p][c] {
+				continue
+			}
+			if count < maxReversalsReported {
+				reversals = append(reversals, fmt.Sprintf("%s->%s (%.6f->%.6f)", coord(p), coord(n), nodes[p][c], nodes[n][c]))
+			}
+			count++
+		}
+		if count == 0 {
+			continue
+		}
+		msg := fmt.Sprintf("%s channel is not monotonic along its axis at %d node(s): %s",
+			name, count, strings.Join(reversals, ", "))
+		if label != "" {
+			msg = label + " " + msg
+		}
+		if count > maxReversalsReported {
+			msg += fmt.Sprintf(", and %d more", count-maxReversalsReported)
+		}
+		warnings = append(warnings, msg)
+	}
+	return warnings
+}
@@ -0,0 +1,96 @@
+package luts
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Supported white balance temperatures, in Kelvin, bounded by the range of
+// the chromaticity approximations below.
+const (
+	minTemperature     = 1667.0
+	maxTemperature     = 25000.0
+	neutralTemperature = 6500.0
+)
+
+// tintStops is the green gain change, in stops, at a Tint of ±100.
+const tintStops = 0.5
+
+// XYZ to linear RGB matrices, row-major.
+var (
+	matXYZToRec2020 = [9]float64{
+		1.716651, -0.355671, -0.253366,
+		-0.666684, 1.616481, 0.015769,
+		0.017640, -0.042771, 0.942103,
+	}
+	matXYZToRec709 = [9]float64{
+		3.240970, -1.537383, -0.498611,
+		-0.969244, 1.875968, 0.041555,
+		0.055630, -0.203977, 1.056972,
+	}
+)
+
+// temperatureChromaticity returns the CIE 1931 xy chromaticity of light at
+// the given color temperature: the Planckian locus below 4000K (Kim et al.
+// cubic approximation) and the CIE daylight locus above.
+func temperatureChromaticity(t float64) (x, y float64) {
+	switch {
+	case t < 4000:
+		x = -0.2661239e9/(t*t*t) - 0.2343589e6/(t*t) + 0.8776956e3/t + 0.179910
+		if t < 2222 {
+			y = -1.1063814*x*x*x - 1.34811020*x*x + 2.18555832*x - 0.20219683
+		} else {
+			y = -0.9549476*x*x*x - 1.37418593*x*x + 2.09137015*x - 0.16748867
+		}
+		return x, y
+	case t <= 7000:
+		x = -4.6070e9/(t*t*t) + 2.9678e6/(t*t) + 0.09911e3/t + 0.244063
+	default:
+		x = -2.0064e9/(t*t*t) + 1.9018e6/(t*t) + 0.24748e3/t + 0.237040
+	}
+	return x, -3*x*x + 2.870*x - 0.275
+}
+
+// whiteBalanceGains returns per-channel linear gains for cfg's working
+// primaries. Temperature scales channels by the color of light at that
+// temperature relative to 6500K, so lower values warm the image; Tint moves
+// green against magenta (positive is more magenta). RedTint and BlueTint are
+// applied on top as raw multipliers.
+func whiteBalanceGains(cfg Config) [3]float64 {
+	gains := [3]float64{1, 1, 1}
+	if cfg.Temperature != neutralTemperature {
+		m := matXYZToRec2020
+		if strings.EqualFold(cfg.InputEncoding, "srgb") {
+			m = matXYZToRec709
+		}
+		white := func(t float64) (float64, float64, float64) {
+			x, y := temperatureChromaticity(t)
+			return multiplyMatrix(m, x/y, 1, (1-x-y)/y)
+		}
+		r, g, b := white(cfg.Temperature)
+		nr, ng, nb := white(neutralTemperature)
+		// Normalize to green so the overall level is kept.
+		gains = [3]float64{(r / nr) / (g / ng), 1, (b / nb) / (g / ng)}
+	}
+	if cfg.Tint != 0 {
+		gains[1] *= math.Pow(2, -tintStops*cfg.Tint/100)
+	}
+	gains[0] *= cfg.RedTint
+	gains[2] *= cfg.BlueTint
+	return gains
+}
+
+// validateWhiteBalance checks the Temperature and Tint ranges.
+func (c *Config) validateWhiteBalance() error {
+	if c.Temperature < minTemperature || c.Temperature > maxTemperature {
+		return fmt.Errorf("temperature must be between %gK and %gK, got %g", minTemperature, maxTemperature, c.Temperature)
+	}
+	if c.Tint < -100 || c.Tint > 100 {
+		return fmt.Errorf("tint must be between -100 and 100, got %g", c.Tint)
+	}
+	if c.RedTint <= 0 || c.BlueTint <= 0 {
+		return fmt.Errorf("red_tint and blue_tint must be positive")
+	}
+	return nil
+}
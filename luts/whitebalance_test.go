@@ -0,0 +1,36 @@
+package luts
+
+import "testing"
+
+func TestWhiteBalance(t *testing.T) {
+	gains := func(edit func(c *Config)) [3]float64 {
+		return whiteBalanceGains(defaultConfig(t, edit))
+	}
+	if g := gains(func(c *Config) { c.Temperature = 6500 }); g != [3]float64{1, 1, 1} {
+		t.Errorf("6500K gains = %v, want 1 1 1", g)
+	}
+	plain := defaultConfig(t, nil)
+	explicit := defaultConfig(t, func(c *Config) { c.Temperature = 6500 })
+	r, g, b := processPixel(explicit, 0.3, 0.4, 0.5)
+	pr, pg, pb := processPixel(plain, 0.3, 0.4, 0.5)
+	if r != pr || g != pg || b != pb {
+		t.Errorf("6500K output %g %g %g, want the default %g %g %g", r, g, b, pr, pg, pb)
+	}
+
+	warm := gains(func(c *Config) { c.Temperature = 3200 })
+	if warm[0] <= 1 || warm[1] != 1 || warm[2] >= 1 {
+		t.Errorf("3200K gains = %v, want red boosted and blue reduced", warm)
+	}
+	cool := gains(func(c *Config) { c.Temperature = 10000 })
+	if cool[0] >= 1 || cool[2] <= 1 {
+		t.Errorf("10000K gains = %v, want red reduced and blue boosted", cool)
+	}
+
+	tinted := gains(func(c *Config) { c.Temperature, c.RedTint, c.Tint = 3200, 1.1, 50 })
+	if !near(tinted[0], warm[0]*1.1, 1e-12) || !near(tinted[2], warm[2], 1e-12) {
+		t.Errorf("red_tint 1.1 on 3200K = %v, want red %g and blue %g", tinted, warm[0]*1.1, warm[2])
+	}
+	if tinted[1] >= 1 {
+		t.Errorf("tint 50 green gain = %g, want below 1 (toward magenta)", tinted[1])
+	}
+}
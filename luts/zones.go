@@ -0,0 +1,114 @@
+package luts
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ZoneLooks assigns a look to each tonal zone. Zones are split by the luma of
+// the display-encoded value, weighted for the output primaries, and
+// crossfaded over Softness around each boundary.
+type ZoneLooks struct {
+	Shadows        LookSpec `json:"shadows"`
+	Midtones       LookSpec `json:"midtones"`
+	Highlights     LookSpec `json:"highlights"`
+	ShadowEnd      float64  `json:"shadow_end"`      // Luma boundary between shadows and midtones (default 0.33)
+	HighlightStart float64  `json:"highlight_start"` // Luma boundary between midtones and highlights (default 0.66)
+	Softness       float64  `json:"softness"`        // Width of the crossfade around each boundary (default 0.1)
+}
+
+// LookSpec selects a registered look and its parameters for one zone.
+type LookSpec struct {
+	Look      string  `json:"look"`      // Registered look name (default "none")
+	Intensity float64 `json:"intensity"` // LookIntensity for this zone (default 1.0)
+}
+
+// enabled reports whether any zone has a look assigned.
+func (z *ZoneLooks) enabled() bool {
+	return z.Shadows.Look != "" || z.Midtones.Look != "" || z.Highlights.Look != ""
+}
+
+func (z *ZoneLooks) setDefaults() {
+	for _, spec := range []*LookSpec{&z.Shadows, &z.Midtones, &z.Highlights} {
+		if spec.Look == "" {
+			spec.Look = "none"
+		}
+		if spec.Intensity == 0 {
+			spec.Intensity = 1.0
+		}
+	}
+	if z.ShadowEnd == 0 {
+		z.ShadowEnd = 0.33
+	}
+	if z.HighlightStart == 0 {
+		z.HighlightStart = 0.66
+	}
+	if z.Softness == 0 {
+		z.Softness = 0.1
+	}
+}
+
+func (z *ZoneLooks) validate() error {
+	for _, spec := range []LookSpec{z.Shadows, z.Midtones, z.Highlights} {
+		if _, ok := findLook(spec.Look); !ok {
+			return fmt.Errorf("zone_looks: unknown look %q (valid: %s)", spec.Look, strings.Join(LookNames(), ", "))
+		}
+	}
+	if z.ShadowEnd <= 0 || z.HighlightStart >= 1 || z.ShadowEnd >= z.HighlightStart {
+		return fmt.Errorf("zone_looks: need 0 < shadow_end < highlight_start < 1, got %g and %g", z.ShadowEnd, z.HighlightStart)
+	}
+	if z.Softness < 0 || z.HighlightStart-z.ShadowEnd < z.Softness {
+		return fmt.Errorf("zone_looks: softness %g must be non-negative and no wider than the midtone zone", z.Softness)
+	}
+	return nil
+}
+
+// smoothstep is 0 below edge0, 1 above edge1, and a smooth cubic in between.
+func smoothstep(edge0, edge1, x float64) float64 {
+	if edge1 <= edge0 {
+		if x < edge0 {
+			return 0
+		}
+		return 1
+	}
+	t := min(max((x-edge0)/(edge1-edge0), 0), 1)
+	return t * t * (3 - 2*t)
+}
+
+// applyZoneLooks applies each zone's look to a display-encoded value and
+// blends the three results by the value's luma.
+func applyZoneLooks(cfg Config, r, g, b float64) (float64, float64, float64) {
+	z := cfg.ZoneLooks
+	specs := [3]LookSpec{z.Shadows, z.Midtones, z.Highlights}
+	if specs[0] == specs[1] && specs[1] == specs[2] {
+		return applyLookSpec(cfg, specs[0], r, g, b)
+	}
+
+	y := luma(cfg.lumaWeights(), r, g, b)
+	half := z.Softness / 2
+	wShadow := 1 - smoothstep(z.ShadowEnd-half, z.ShadowEnd+half, y)
+	wHigh := smoothstep(z.HighlightStart-half, z.HighlightStart+half, y)
+	weights := [3]float64{wShadow, 1 - wShadow - wHigh, wHigh}
+
+	var outR, outG, outB float64
+	for i, spec := range specs {
+		if weights[i] == 0 {
+			continue
+		}
+		lr, lg, lb := applyLookSpec(cfg, spec, r, g, b)
+		outR += weights[i] * lr
+		outG += weights[i] * lg
+		outB += weights[i] * lb
+	}
+	return outR, outG, outB
+}
+
+// applyLookSpec applies the look named by spec with its own intensity.
+func applyLookSpec(cfg Config, spec LookSpec, r, g, b float64) (float64, float64, float64) {
+	l, ok := findLook(spec.Look)
+	if !ok {
+		return r, g, b
+	}
+	cfg.LookIntensity = spec.Intensity
+	return l.Apply(cfg, r, g, b)
+}
@@ -0,0 +1,47 @@
+package luts
+
+import "testing"
+
+func TestZoneLooksUniformMatchesLook(t *testing.T) {
+	spec := LookSpec{Look: "warmVintage"}
+	zoned := defaultConfig(t, func(c *Config) { c.ZoneLooks = ZoneLooks{Shadows: spec, Midtones: spec, Highlights: spec} })
+	look := defaultConfig(t, func(c *Config) { c.Look = "warmVintage" })
+	for _, in := range [][3]float64{{0.1, 0.1, 0.1}, {0.2, 0.5, 0.3}, {0.9, 0.8, 0.7}} {
+		r, g, b := processPixel(zoned, in[0], in[1], in[2])
+		lr, lg, lb := processPixel(look, in[0], in[1], in[2])
+		if got, want := [3]float64{r, g, b}, [3]float64{lr, lg, lb}; !nearRGB(got, want, 1e-12) {
+			t.Errorf("%v: zone_looks gives %v, look %v", in, got, want)
+		}
+	}
+}
+
+func TestZoneLooksAffectOnlyTheirZone(t *testing.T) {
+	cfg := defaultConfig(t, func(c *Config) {
+		c.ZoneLooks = ZoneLooks{Shadows: LookSpec{Look: "warmVintage"}, Highlights: LookSpec{Look: "bleachBypass"}}
+	})
+	look := func(name string, v float64) [3]float64 {
+		l, _ := findLook(name)
+		r, g, b := l.Apply(cfg, v, v, v)
+		return [3]float64{r, g, b}
+	}
+	for _, tc := range []struct {
+		gray float64
+		look string
+	}{
+		{0.1, "warmVintage"},
+		{0.2, "warmVintage"},
+		{0.5, "none"},
+		{0.8, "bleachBypass"},
+	} {
+		r, g, b := applyZoneLooks(cfg, tc.gray, tc.gray, tc.gray)
+		if got, want := [3]float64{r, g, b}, look(tc.look, tc.gray); !nearRGB(got, want, 1e-12) {
+			t.Errorf("gray %g = %v, want the %s result %v", tc.gray, got, tc.look, want)
+		}
+	}
+
+	// Within the crossfade the result lies between the two zones' looks.
+	r, _, _ := applyZoneLooks(cfg, 0.33, 0.33, 0.33)
+	if warm := look("warmVintage", 0.33)[0]; !(r > 0.33 && r < warm) {
+		t.Errorf("red at the shadow boundary = %g, want between 0.33 and %g", r, warm)
+	}
+}
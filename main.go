@@ -1,254 +1,760 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
 	"io/fs"
 	"log"
-	"math"
 	"os"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"strings"
+	"sync"
+	"time"
+
+	"github.com/flaticols/loglutgen/luts"
 )
 
-// Config defines the LUT parameters.
-type Config struct {
-	Size           int     `json:"size"`            // Grid dimension (default 17)
-	RedTint        float64 `json:"red_tint"`        // Additional red multiplier (if used in creative look)
-	BlueTint       float64 `json:"blue_tint"`       // Additional blue multiplier (if used in creative look)
-	Output         string  `json:"output"`          // Output file name (e.g., "apple_log_cinematic.cube")
-	Look           string  `json:"look"`            // "none", "tealOrange", or "warmVintage"
-	ExposureOffset float64 `json:"exposure_offset"` // Factor to adjust exposure (default 1.0)
+// runOptions carries the command-line settings that apply to every config.
+type runOptions struct {
+	configDir string      // Root of the config tree; outputs mirror its subdirectories
+	outputDir string      // Directory for relative output file names
+	checksums bool        // Write a <output>.sha256 file next to each LUT
+	maxSize   int64       // Refuse to generate outputs estimated above this many bytes (0 disables)
+	exposure  bool        // Report the exposure offset that best avoids clipping and crushing
+	separator string      // Default cube data-line separator for configs that leave it unset
+	cache     *lutCache   // Skip configs whose inputs are unchanged since the last run (nil disables)
+	validate  bool        // Check each generated LUT for channel reversals before writing it
+	keep      bool        // Skip configs whose output files already exist instead of overwriting them
+	dryRun    bool        // Generate and log each output instead of writing it
+	report    bool        // Log how many grid nodes the gamut conversion and look clip
+	preview   bool        // Write a <name>.preview.png of the test chart graded by each LUT
+	flatten   *flatNames  // Write relative outputs directly into outputDir, prefixed with the config's subdirectory (nil mirrors subdirectories)
+	configSub string      // Subdirectory of configDir holding the config, for flattened names
+	written   *[]string   // Receives the paths of the files written, for -logFormat json (nil ignores them)
+	defaults  luts.Config // Run-wide defaults from the -default* flags, beneath each config's own settings
 }
 
-func (c *Config) setDefaults() {
-	if c.Size <= 0 {
-		c.Size = 17
-	}
-	if c.RedTint == 0 {
-		c.RedTint = 1.05
-	}
-	if c.BlueTint == 0 {
-		c.BlueTint = 0.95
-	}
-	if c.Output == "" {
-		c.Output = "output.cube"
+// errOutputExists reports a config skipped because its output already exists
+// and overwriting is disabled.
+var errOutputExists = errors.New("output already exists")
+
+// errUnchanged reports a config skipped by -cache because its effective
+// settings and outputs are unchanged since the last run.
+var errUnchanged = errors.New("unchanged")
+
+// lutLineBytes returns the length of one data line of three values in [0,1]
+// with the given number of decimals, e.g. 27 for "%.6f %.6f %.6f\n".
+func lutLineBytes(precision int) int64 {
+	return 3 * int64(precision+3)
+}
+
+// dctlBytes is the approximate size of generated DCTL source, which does not
+// depend on the grid size.
+const dctlBytes = 10 << 10
+
+// previewSize is the width and height in pixels of -preview images.
+const previewSize = 256
+
+// estimateOutputSize returns the approximate size in bytes of the LUT that
+// cfg would generate, without generating it.
+func estimateOutputSize(cfg luts.Config) int64 {
+	if strings.EqualFold(cfg.OutputFormat, "hald") {
+		// At most 8 bytes per pixel before PNG compression.
+		level := int64(luts.HaldLevel(cfg.Size))
+		side := level * level * level
+		return side * side * 8
 	}
-	if c.Look == "" {
-		c.Look = "none"
+	if strings.EqualFold(cfg.OutputFormat, "dctl") {
+		return dctlBytes
 	}
-	if c.ExposureOffset == 0 {
-		c.ExposureOffset = 1.0
+	line := lutLineBytes(cfg.Precision)
+	if cfg.ShaperOnly {
+		return int64(cfg.ShaperSize) * line
 	}
+	n := int64(cfg.Size)
+	return (n*n*n + int64(cfg.ShaperSize)) * line
 }
 
-// appleLogToLinear approximates the decoding of Apple Log to linear light.
-// This is a simplified function; in practice, use the official curve.
-func appleLogToLinear(x float64, exposureOffset float64) float64 {
-	// Apply an exposure offset and clip to [0,1]
-	v := min(x*exposureOffset, 1)
-	// A simple power function to approximate the inverse log curve.
-	// (Note: This is a rough approximation.)
-	return math.Pow(v, 1.5)
+// processConfigFile reads a JSON or YAML config file, generates LUT data, and writes the .cube file.
+// Configs in subdirectories of opts.configDir write relative outputs to the
+// same subdirectories of opts.outputDir, or with opts.flatten to names
+// prefixed with those subdirectories, so equal names in different folders
+// don't collide.
+func processConfigFile(configPath string, opts runOptions) error {
+	data, err := readConfigFile(configPath)
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	if opts.configDir != "" {
+		if rel, err := filepath.Rel(opts.configDir, filepath.Dir(configPath)); err == nil && rel != "." && !strings.HasPrefix(rel, "..") {
+			if opts.flatten != nil {
+				opts.configSub = rel
+			} else {
+				opts.outputDir = filepath.Join(opts.outputDir, rel)
+			}
+		}
+	}
+	return processConfig(configPath, data, opts)
 }
 
-// rec2020ToRec709 converts Rec.2020 linear values to Rec.709 linear using a 3x3 matrix.
-func rec2020ToRec709(r, g, b float64) (float64, float64, float64) {
-	// Matrix coefficients (approximation)
-	r709 := 1.660*r - 0.587*g - 0.073*b
-	g709 := -0.124*r + 1.132*g - 0.008*b
-	b709 := -0.018*r - 0.100*g + 1.118*b
-	// Clip values to [0,1]
-	if r709 < 0 {
-		r709 = 0
+// processConfig generates LUT data from a config JSON document and writes the
+// .cube file. configPath identifies the document in log messages.
+func processConfig(configPath string, data []byte, opts runOptions) error {
+	cfg, err := parseConfig(data, opts.defaults)
+	if err != nil {
+		return fmt.Errorf("parsing config: %w", err)
 	}
-	if g709 < 0 {
-		g709 = 0
+	if cfg.Separator == "" {
+		cfg.Separator = opts.separator
 	}
-	if b709 < 0 {
-		b709 = 0
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
-	if r709 > 1 {
-		r709 = 1
+	outFileName, err := expandOutputName(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
 	}
-	if g709 > 1 {
-		g709 = 1
+	cfg.Output = outFileName // The default TITLE comes from the expanded name
+	var inputHash string
+	if opts.cache != nil {
+		inputHash = configInputHash(cfg, opts)
+		if opts.cache.fresh(configPath, inputHash) {
+			return errUnchanged
+		}
 	}
-	if b709 > 1 {
-		b709 = 1
+	if est := estimateOutputSize(cfg); opts.maxSize > 0 && est > opts.maxSize {
+		return fmt.Errorf("refusing to generate: estimated output size %d bytes exceeds -maxFileSize %d (check size in the config)",
+			est, opts.maxSize)
+	}
+
+	var lutData, inverseData string
+	switch {
+	case cfg.ShaperOnly:
+		lutData = luts.GenerateShaper(cfg)
+	case strings.EqualFold(cfg.OutputFormat, "hald"):
+		if lutData, err = luts.Generate(cfg); err != nil {
+			return fmt.Errorf("rendering HALD CLUT: %w", err)
+		}
+	case strings.EqualFold(cfg.OutputFormat, "dctl"):
+		lutData = luts.GenerateDCTL(cfg)
+	case cfg.LookPair:
+		var numeric bool
+		lutData, inverseData, numeric = luts.GenerateLookPair(cfg)
+		if numeric {
+			log.Printf("Warning: %s: look %q has no analytic inverse; the inverse LUT is a numeric approximation\n",
+				configPath, cfg.Look)
+		}
+	default:
+		if opts.exposure {
+			offset, clipped, crushed := luts.OptimalExposure(cfg)
+			log.Printf("Optimal exposure_offset for %s: %.3f (clips %.1f%%, crushes %.1f%% of a neutral ramp; current %.3f)\n",
+				configPath, offset, clipped*100, crushed*100, cfg.ExposureOffset)
+		}
+		for _, w := range luts.SizeWarnings(cfg) {
+			log.Printf("Warning: %s: %s\n", configPath, w)
+		}
+		for _, w := range luts.MatrixWarnings(cfg) {
+			log.Printf("Warning: %s: %s\n", configPath, w)
+		}
+		for _, w := range luts.TargetWarnings(cfg) {
+			log.Printf("Warning: %s: %s\n", configPath, w)
+		}
+		for _, w := range luts.CheckBanding(cfg) {
+			log.Printf("Warning: %s: %s\n", configPath, w)
+		}
+		if opts.report {
+			logClipReport(configPath, luts.CountClipping(cfg))
+		}
+		cube, stats := buildCube(cfg)
+		lutData = luts.FormatCube(cfg, cube)
+		if stats.NonFinite > 0 {
+			log.Printf("Warning: %s: replaced %d NaN or infinite output value(s) with finite ones; check the config for extreme values\n",
+				configPath, stats.NonFinite)
+		}
+		if cfg.QuantizeBits > 0 {
+			log.Printf("Quantized %s to %d-bit: max error %.6f, mean error %.6f\n",
+				configPath, cfg.QuantizeBits, stats.QuantMaxError, stats.QuantMeanError)
+		}
+	}
+
+	// If not an absolute path, use the config's output directory, falling
+	// back to the global one.
+	if !filepath.IsAbs(outFileName) {
+		dir := opts.outputDir
+		if cfg.OutputDir != "" {
+			dir = cfg.OutputDir
+		}
+		if opts.flatten != nil {
+			outFileName = flattenName(opts.configSub, outFileName)
+		}
+		outFileName = filepath.Join(dir, outFileName)
+		if !opts.dryRun {
+			if err := os.MkdirAll(filepath.Dir(outFileName), os.ModePerm); err != nil {
+				return fmt.Errorf("creating output directory %s: %w", filepath.Dir(outFileName), err)
+			}
+		}
 	}
-	return r709, g709, b709
-}
 
-// rec709OETF applies the Rec.709 opto-electronic transfer function.
-func rec709OETF(linear float64) float64 {
-	if linear < 0.018 {
-		return 4.5 * linear
+	lutData = stampVersion(cfg, lutData)
+	if cfg.LookPair {
+		inverseData = stampVersion(cfg, inverseData)
+	}
+	outputs := [][2]string{{outFileName, lutData}}
+	if cfg.LookPair {
+		ext := filepath.Ext(outFileName)
+		outputs = append(outputs, [2]string{strings.TrimSuffix(outFileName, ext) + "_inverse" + ext, inverseData})
+	}
+	if opts.flatten != nil {
+		for _, out := range outputs {
+			if err := opts.flatten.claim(out[0], configPath); err != nil {
+				return err
+			}
+		}
+	}
+	if opts.keep {
+		for _, out := range outputs {
+			if _, err := os.Stat(out[0]); err == nil {
+				return fmt.Errorf("%w: %s", errOutputExists, out[0])
+			}
+		}
+	}
+	if opts.dryRun {
+		resolved, err := json.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf("encoding resolved config: %w", err)
+		}
+		log.Printf("Dry run: resolved config for %s: %s\n", configPath, resolved)
 	}
-	return 1.099*math.Pow(linear, 0.45) - 0.099
+	hashes := make(map[string]string)
+	for _, out := range outputs {
+		name, data := out[0], out[1]
+		if opts.validate && !strings.EqualFold(cfg.OutputFormat, "dctl") { // DCTL source has no nodes to check
+			warnings, err := luts.ValidateLUT(cfg, data)
+			if err != nil {
+				return fmt.Errorf("validating %s: %w", name, err)
+			}
+			for _, w := range warnings {
+				log.Printf("Warning: %s: %s\n", name, w)
+			}
+		}
+		if opts.dryRun {
+			action := "create"
+			if _, err := os.Stat(name); err == nil {
+				action = "overwrite"
+			}
+			log.Printf("Dry run: would %s %s (%d bytes)\n", action, name, len(data))
+			if opts.written != nil {
+				*opts.written = append(*opts.written, name)
+			}
+			continue
+		}
+		if err := writeOutput(name, data, opts.checksums); err != nil {
+			return fmt.Errorf("writing output file %s: %w", name, err)
+		}
+		log.Printf("LUT successfully written to %s\n", name)
+		hashes[name] = hashBytes([]byte(data))
+		if opts.checksums {
+			sum, err := os.ReadFile(name + ".sha256")
+			if err != nil {
+				return fmt.Errorf("reading checksum file: %w", err)
+			}
+			hashes[name+".sha256"] = hashBytes(sum)
+		}
+		if opts.written != nil {
+			*opts.written = append(*opts.written, name)
+		}
+	}
+	if opts.preview && !cfg.ShaperOnly { // A shaper alone has no look to preview
+		name := strings.TrimSuffix(outFileName, filepath.Ext(outFileName)) + ".preview.png"
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, luts.RenderPreview(cfg, previewSize)); err != nil {
+			return fmt.Errorf("encoding preview %s: %w", name, err)
+		}
+		if opts.dryRun {
+			log.Printf("Dry run: would write preview %s (%d bytes)\n", name, buf.Len())
+		} else {
+			if err := os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+				return fmt.Errorf("writing preview %s: %w", name, err)
+			}
+			log.Printf("Preview written to %s\n", name)
+			hashes[name] = hashBytes(buf.Bytes())
+		}
+		if opts.written != nil {
+			*opts.written = append(*opts.written, name)
+		}
+	}
+	if opts.cache != nil && !opts.dryRun {
+		opts.cache.record(configPath, inputHash, hashes)
+	}
+	return nil
 }
 
-// applyTealOrange applies a simplified teal & orange look.
-func applyTealOrange(r, g, b float64) (float64, float64, float64) {
-	// Compute luminance
-	lum := 0.2126*r + 0.7152*g + 0.0722*b
-	origR, origG, origB := r, g, b
-	if lum < 0.5 {
-		// In shadows, reduce red slightly and boost blue
-		rNew := r * 0.95
-		bNew := b * 1.1
-		// Blend the original with the modified values
-		r = 0.7*origR + 0.3*rNew
-		g = 0.7*origG + 0.3*origG // green remains similar
-		b = 0.7*origB + 0.3*bNew
-	} else {
-		// In highlights, boost red and reduce blue
-		rNew := r * 1.1
-		bNew := b * 0.95
-		r = 0.7*origR + 0.3*rNew
-		g = 0.7*origG + 0.3*origG
-		b = 0.7*origB + 0.3*bNew
-	}
-	if r > 1 {
-		r = 1
-	}
-	if g > 1 {
-		g = 1
-	}
-	if b > 1 {
-		b = 1
-	}
-	return r, g, b
+// logClipReport logs the share of grid nodes a config clips, with the
+// per-channel and per-stage breakdown.
+func logClipReport(configPath string, r luts.ClipReport) {
+	log.Printf("Clipping in %s: %d of %d nodes (%.1f%%); red %d, green %d, blue %d; %d in the gamut conversion, %d by the look\n",
+		configPath, r.Clipped, r.Nodes, 100*float64(r.Clipped)/float64(max(r.Nodes, 1)),
+		r.Channels[0], r.Channels[1], r.Channels[2], r.Gamut, r.Look)
 }
 
-// applyWarmVintage applies a simplified warm vintage look.
-func applyWarmVintage(r, g, b float64) (float64, float64, float64) {
-	// Apply a subtle warm tint: increase red slightly, decrease blue
-	r = r * 1.05
-	b = b * 0.95
-	// Optionally, lower contrast gently by blending with mid-gray (0.5)
-	r = 0.9*r + 0.1*0.5
-	g = 0.9*g + 0.1*0.5
-	b = 0.9*b + 0.1*0.5
-	if r > 1 {
-		r = 1
-	}
-	if g > 1 {
-		g = 1
-	}
-	if b > 1 {
-		b = 1
-	}
-	return r, g, b
+// buildCube builds the grid of a .cube config. Tests replace it to make a
+// config fail in ways no valid config can.
+var buildCube = luts.BuildCube
+
+// recoverConfig calls run and converts a panic into an error, logging the
+// stack, so a bug triggered by one config fails only that config instead of
+// the whole batch. source identifies the config in the log.
+func recoverConfig(source string, run func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Panic processing %s: %v\n%s", source, r, debug.Stack())
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run()
 }
 
-// generateLUT creates the LUT as a string based on the config.
-// For each input grid value (representing an Apple Log encoded value), we:
-// 1. Decode from Apple Log to linear light.
-// 2. Convert from Rec.2020 (linear) to Rec.709 (linear).
-// 3. Apply Rec.709 OETF (gamma encoding).
-// 4. Optionally, apply a creative look.
-func generateLUT(cfg Config) string {
-	size := cfg.Size
-	var builder strings.Builder
-
-	// Write LUT header
-	builder.WriteString("# Generated Cinematic LUT for Apple Log to Rec.709 conversion\n")
-	builder.WriteString(fmt.Sprintf("LUT_3D_SIZE %d\n", size))
-
-	// Loop over the 3D LUT grid.
-	for i := 0; i < size; i++ {
-		for j := 0; j < size; j++ {
-			for k := 0; k < size; k++ {
-				// Normalized input values (simulate Apple Log encoded values).
-				// These are in the range [0, 1].
-				inR := float64(i) / float64(size-1)
-				inG := float64(j) / float64(size-1)
-				inB := float64(k) / float64(size-1)
-
-				// Step 1: Decode Apple Log to linear light.
-				linR := appleLogToLinear(inR, cfg.ExposureOffset)
-				linG := appleLogToLinear(inG, cfg.ExposureOffset)
-				linB := appleLogToLinear(inB, cfg.ExposureOffset)
-
-				// Step 2: Convert from Rec.2020 (linear) to Rec.709 (linear).
-				convR, convG, convB := rec2020ToRec709(linR, linG, linB)
-
-				// Step 3: Encode using Rec.709 OETF.
-				encR := rec709OETF(convR)
-				encG := rec709OETF(convG)
-				encB := rec709OETF(convB)
-
-				// Step 4: Apply creative look if specified.
-				switch strings.ToLower(cfg.Look) {
-				case "tealorange":
-					encR, encG, encB = applyTealOrange(encR, encG, encB)
-				case "warmvintage":
-					encR, encG, encB = applyWarmVintage(encR, encG, encB)
-				}
-
-				// Write the LUT line with 6 decimal places.
-				builder.WriteString(fmt.Sprintf("%.6f %.6f %.6f\n", encR, encG, encB))
+// runJobs calls fn for each index below n on up to jobs goroutines at once
+// and returns when all calls have finished.
+func runJobs(n, jobs int, fn func(i int)) {
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(max(jobs, 1), n); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				fn(i)
 			}
-		}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		next <- i
 	}
-	return builder.String()
+	close(next)
+	wg.Wait()
 }
 
-// processConfigFile reads a config JSON file, generates LUT data, and writes the .cube file.
-func processConfigFile(configPath, outputDir string) {
-	data, err := os.ReadFile(configPath)
+// writeOutput writes the LUT data to path. With checksums enabled the SHA-256
+// is computed while writing and stored in path+".sha256" in the format
+// expected by "sha256sum -c".
+func writeOutput(path, data string, checksums bool) error {
+	if !checksums {
+		return os.WriteFile(path, []byte(data), 0644)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	if err != nil {
-		log.Printf("Error reading config file %s: %v\n", configPath, err)
-		return
+		return err
 	}
-	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		log.Printf("Error parsing JSON in %s: %v\n", configPath, err)
-		return
+	hash := sha256.New()
+	if _, err := io.WriteString(io.MultiWriter(f, hash), data); err != nil {
+		f.Close()
+		return err
 	}
-	cfg.setDefaults()
+	if err := f.Close(); err != nil {
+		return err
+	}
+	sum := fmt.Sprintf("%x  %s\n", hash.Sum(nil), filepath.Base(path))
+	return os.WriteFile(path+".sha256", []byte(sum), 0644)
+}
 
-	lutData := generateLUT(cfg)
+// loadSingleConfig reads, parses, and validates the config file at path for
+// the single-config modes, on top of the run-wide defaults. An empty path
+// yields the defaults alone.
+func loadSingleConfig(path string, defaults luts.Config) (luts.Config, error) {
+	cfg := defaults
+	if path != "" {
+		data, err := readConfigFile(path)
+		if err != nil {
+			return cfg, fmt.Errorf("reading config file %s: %w", path, err)
+		}
+		if cfg, err = parseConfig(data, defaults); err != nil {
+			return cfg, fmt.Errorf("parsing config %s: %w", path, err)
+		}
+	}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return cfg, nil
+}
 
-	// Determine the output file name.
-	outFileName := cfg.Output
-	// If not an absolute path, use the output directory.
-	if !filepath.IsAbs(outFileName) {
-		outFileName = filepath.Join(outputDir, outFileName)
+// generateToStdout reads one config document from r and writes the generated
+// LUT to w, for use in pipelines. separator applies when the config leaves it
+// unset, as do the run-wide defaults.
+func generateToStdout(r io.Reader, w io.Writer, separator string, defaults luts.Config) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("reading config: %w", err)
+	}
+	cfg, err := parseConfig(data, defaults)
+	if err != nil {
+		return fmt.Errorf("parsing config: %w", err)
 	}
+	if cfg.Separator == "" {
+		cfg.Separator = separator
+	}
+	cfg.SetDefaults()
+	lutData, err := luts.Generate(cfg)
+	if err != nil {
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	_, err = io.WriteString(w, stampVersion(cfg, lutData))
+	return err
+}
 
-	if err := os.WriteFile(outFileName, []byte(lutData), 0644); err != nil {
-		log.Printf("Error writing output file %s: %v\n", outFileName, err)
-		return
+// writePNG encodes img as a PNG file at path.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
 	}
-	log.Printf("LUT successfully written to %s\n", outFileName)
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
 }
 
 func main() {
 	// Command-line flags for directories.
 	configDir := flag.String("configDir", "configs", "Directory containing JSON config files")
 	outputDir := flag.String("outputDir", "output", "Directory to write the generated .cube files")
+	listPresets := flag.Bool("presets", false, "List the bundled presets and exit")
+	showVersion := flag.Bool("version", false, "Print the version, commit, and build date and exit")
+	printSchema := flag.Bool("schema", false, "Print a JSON Schema for config files and exit")
+	compose := flag.Bool("compose", false, "Bake two .cube files into one: -compose first.cube second.cube output.cube")
+	diff := flag.Bool("diff", false, "Compare two .cube files and print their per-channel differences: -diff first.cube second.cube")
+	diffImage := flag.String("diffImage", "", "Also write a heatmap PNG of the per-node differences between the -diff LUTs to this path (implies -diff)")
+	diffTolerance := flag.Float64("diffTolerance", -1, "With -diff, exit with status 1 when the largest difference exceeds this (negative disables)")
+	fitCSV := flag.String("csv", "", "Fit a LUT to the measured samples in this CSV file (rows of in_r,in_g,in_b,out_r,out_g,out_b): -csv samples.csv output.cube")
+	fitSize := flag.Int("fitSize", 33, "Grid size of the LUT built by -csv")
+	listLooks := flag.Bool("listLooks", false, "List the registered looks and exit")
+	fromCSV := flag.String("fromCSV", "", "Generate one LUT per row of a look-pack CSV instead of walking configDir")
+	checksums := flag.Bool("checksums", false, "Write a <output>.sha256 checksum file next to each LUT")
+	contactSheet := flag.String("contactSheet", "", "Render the test chart through every look into this PNG and exit")
+	sheetConfig := flag.String("sheetConfig", "", "Config file supplying exposure and other settings for -contactSheet")
+	sheetTileSize := flag.Int("sheetTileSize", 256, "Tile size in pixels for -contactSheet")
+	applyImage := flag.String("applyImage", "", "Grade -inputImage through the LUT of -applyConfig, write the result to this PNG, and exit")
+	inputImage := flag.String("inputImage", "", "PNG or TIFF frame (Apple Log encoded) to grade with -applyImage")
+	sample := flag.String("sample", "", `Print the pipeline stages for one encoded input color given as "r,g,b", e.g. -sample 0.5,0.4,0.3`)
+	sampleConfig := flag.String("sampleConfig", "", "Config file for -sample (defaults apply when unset)")
+	applyConfig := flag.String("applyConfig", "", "Config file for the LUT used by -applyImage (defaults apply when unset)")
+	markClipping := flag.Bool("markClipping", false, "With -applyImage, paint pixels magenta where a channel clips at 1.0 and green where one clips at 0.0")
+	sheetColumns := flag.Int("sheetColumns", 4, "Number of tile columns for -contactSheet")
+	optimizeExposure := flag.Bool("optimizeExposure", false, "Report the exposure_offset that minimizes combined clipping and crushing for each config")
+	separator := flag.String("separator", "space", `Separator between values on cube and 3dl data lines: "space" or "tab" (configs may override)`)
+	validate := flag.Bool("validate", false, "Check each generated LUT for channels that decrease along their own axis")
+	useCache := flag.Bool("cache", false, "Skip configs unchanged since the last run, tracked in "+cacheFileName+" in outputDir")
+	stdin := flag.Bool("stdin", false, "Read one JSON config from stdin, write the LUT to stdout, and exit")
+	overwrite := flag.Bool("overwrite", true, "Overwrite existing output files; when false, configs whose outputs exist are skipped")
+	watch := flag.Bool("watch", false, "After processing configDir, keep running and regenerate LUTs for configs that are created or modified")
+	report := flag.Bool("report", false, "Log how many grid nodes of each LUT are clipped by the gamut conversion or the look")
+	preview := flag.Bool("preview", false, "Write a <name>.preview.png next to each LUT showing the test chart graded by it")
+	dryRun := flag.Bool("dryRun", false, "Generate every LUT and log its path, resolved config, and size without writing anything")
+	logFormat := flag.String("logFormat", "text", `Log format on stderr: "text", or "json" for one JSON object per line with a result line per config`)
+	flatten := flag.Bool("flatten", false, "Write all outputs directly into outputDir, prefixing names with the config's subdirectories instead of mirroring them")
+	defaultExposureStops := flag.String("defaultExposureStops", os.Getenv(envDefaultExposureStops),
+		"exposure_stops for configs that do not set it (default $"+envDefaultExposureStops+")")
+	defaultTargetColorSpace := flag.String("defaultTargetColorSpace", os.Getenv(envDefaultTargetColorSpace),
+		"target_color_space for configs that set neither it nor target (default $"+envDefaultTargetColorSpace+")")
+	normalize := flag.Bool("normalize", false, "Set normalize_exposure on every config: apply exposure_offset in linear light and roll highlights off instead of clipping them")
+	configOrder := flag.String("sort", "name", `Order in which configs are processed: "name" (by path) or "reverse"`)
+	jobs := flag.Int("jobs", runtime.NumCPU(), "Number of config files to process at once")
+	maxFileSize := flag.Int64("maxFileSize", 100<<20, "Refuse to write LUTs estimated larger than this many bytes (0 disables)")
 	flag.Parse()
 
+	defaults, err := configDefaults(*defaultExposureStops, *defaultTargetColorSpace)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defaults.NormalizeExposure = *normalize
+
+	var jlog *jsonLog
+	switch *logFormat {
+	case "text":
+	case "json":
+		jlog = &jsonLog{w: os.Stderr}
+		log.SetFlags(0)
+		log.SetOutput(jlog)
+	default:
+		log.Fatalf("unknown -logFormat %q (valid: text, json)", *logFormat)
+	}
+	if err := sortConfigPaths(nil, *configOrder); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	if *listPresets {
+		for _, name := range presetNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	if *showVersion {
+		fmt.Println(versionString())
+		return
+	}
+
+	if *printSchema {
+		schema := luts.ConfigSchema()
+		props := schema["properties"].(map[string]any)
+		props["preset"].(map[string]any)["enum"] = presetNames()
+		out, err := json.MarshalIndent(schema, "", "  ")
+		if err != nil {
+			log.Fatalf("Error encoding schema: %v", err)
+		}
+		fmt.Println(string(out))
+		return
+	}
+
+	if *listLooks {
+		for _, name := range luts.LookNames() {
+			fmt.Println(name)
+		}
+		return
+	}
+
+	if *fitCSV != "" {
+		if flag.NArg() != 1 {
+			log.Fatalf("-csv requires an output argument: -csv samples.csv output.cube")
+		}
+		if err := fitSamplesFile(*fitCSV, flag.Arg(0), *fitSize, *separator, *checksums); err != nil {
+			log.Fatalf("Error fitting samples: %v", err)
+		}
+		log.Printf("Fitted LUT written to %s\n", flag.Arg(0))
+		return
+	}
+
+	if *diff || *diffImage != "" {
+		if flag.NArg() != 2 {
+			log.Fatalf("-diff requires two arguments: first.cube second.cube")
+		}
+		exceeded, err := diffFiles(os.Stdout, flag.Arg(0), flag.Arg(1), *diffImage, *diffTolerance)
+		if err != nil {
+			log.Fatalf("Error comparing LUTs: %v", err)
+		}
+		if *diffImage != "" {
+			log.Printf("Difference heatmap written to %s\n", *diffImage)
+		}
+		if exceeded {
+			log.Printf("Largest difference exceeds -diffTolerance %g\n", *diffTolerance)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *compose {
+		if flag.NArg() != 3 {
+			log.Fatalf("-compose requires three arguments: first.cube second.cube output.cube")
+		}
+		if err := composeFiles(flag.Arg(0), flag.Arg(1), flag.Arg(2), *separator, *checksums); err != nil {
+			log.Fatalf("Error composing LUTs: %v", err)
+		}
+		log.Printf("Composed LUT written to %s\n", flag.Arg(2))
+		return
+	}
+
+	if *stdin {
+		if err := generateToStdout(os.Stdin, os.Stdout, *separator, defaults); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		return
+	}
+
+	if *sample != "" {
+		in, err := parseTriplet(*sample)
+		if err != nil {
+			log.Fatalf("Error: -sample: %v", err)
+		}
+		cfg, err := loadSingleConfig(*sampleConfig, defaults)
+		if err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		printSample(os.Stdout, cfg, in)
+		return
+	}
+
+	if *applyImage != "" {
+		if *inputImage == "" {
+			log.Fatalf("-applyImage requires -inputImage")
+		}
+		cfg, err := loadSingleConfig(*applyConfig, defaults)
+		if err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		if err := applyLUTToImage(cfg, *inputImage, *applyImage, *markClipping); err != nil {
+			log.Fatalf("Error applying LUT: %v", err)
+		}
+		log.Printf("Graded image written to %s\n", *applyImage)
+		return
+	}
+
+	if *contactSheet != "" {
+		base, err := loadSingleConfig(*sheetConfig, defaults)
+		if err != nil {
+			log.Fatalf("Error loading config: %v", err)
+		}
+		if *sheetTileSize <= 0 || *sheetColumns <= 0 {
+			log.Fatalf("-sheetTileSize and -sheetColumns must be positive")
+		}
+		sheet := luts.RenderContactSheet(base, luts.LookNames(), *sheetTileSize, *sheetColumns)
+		if err := writePNG(*contactSheet, sheet); err != nil {
+			log.Fatalf("Error writing contact sheet: %v", err)
+		}
+		log.Printf("Contact sheet written to %s\n", *contactSheet)
+		return
+	}
+
 	// Ensure output directory exists.
-	if err := os.MkdirAll(*outputDir, os.ModePerm); err != nil {
-		log.Fatalf("Error creating output directory: %v", err)
+	if !*dryRun {
+		if err := os.MkdirAll(*outputDir, os.ModePerm); err != nil {
+			log.Fatalf("Error creating output directory: %v", err)
+		}
+	}
+	opts := runOptions{configDir: *configDir, outputDir: *outputDir, checksums: *checksums, maxSize: *maxFileSize, exposure: *optimizeExposure, separator: *separator, validate: *validate, keep: !*overwrite, dryRun: *dryRun, report: *report, preview: *preview, defaults: defaults}
+	if *flatten {
+		opts.flatten = &flatNames{owners: map[string]string{}}
+	}
+	if *useCache {
+		cachePath := filepath.Join(*outputDir, cacheFileName)
+		cache, err := loadCache(cachePath, toolVersion())
+		if err != nil {
+			log.Fatalf("Error loading cache: %v", err)
+		}
+		opts.cache = cache
 	}
 
-	// Walk through the config directory and process each JSON file.
-	err := filepath.Walk(*configDir, func(path string, info fs.FileInfo, err error) error {
+	// Each config is processed independently, up to -jobs at a time;
+	// failures are logged and counted so a single bad config doesn't stop
+	// the run.
+	var mu sync.Mutex // Guards the counters
+	var succeeded, skipped, failed int
+	process := func(source string, run func(opts runOptions) error) {
+		if jlog == nil {
+			log.Printf("Processing config: %s\n", source)
+		}
+		start := time.Now()
+		var written []string
+		runOpts := opts
+		if jlog != nil {
+			runOpts.written = &written
+		}
+		err := recoverConfig(source, func() error { return run(runOpts) })
+		mu.Lock()
+		defer mu.Unlock()
+		status := "ok"
+		switch {
+		case errors.Is(err, errUnchanged):
+			status = "skipped"
+			if jlog == nil {
+				log.Printf("Unchanged, skipped %s\n", source)
+			}
+			skipped++
+		case errors.Is(err, errOutputExists):
+			status = "skipped"
+			if jlog == nil {
+				log.Printf("Warning: skipped %s: %v\n", source, err)
+			}
+			skipped++
+		case err != nil:
+			status = "error"
+			if jlog == nil {
+				log.Printf("Error processing %s: %v\n", source, err)
+			}
+			failed++
+		default:
+			succeeded++
+		}
+		if jlog != nil {
+			jlog.result(source, written, status, err, time.Since(start))
+		}
+	}
+	saveCache := func() {
+		if opts.cache != nil && !opts.dryRun {
+			if err := opts.cache.save(); err != nil {
+				log.Printf("Error saving cache %s: %v\n", opts.cache.path, err)
+			}
+		}
+	}
+	finish := func() {
+		saveCache()
+		log.Printf("Done: %d succeeded, %d skipped, %d failed\n", succeeded, skipped, failed)
+		if failed > 0 {
+			os.Exit(1)
+		}
+	}
+
+	if *fromCSV != "" {
+		f, err := os.Open(*fromCSV)
+		if err != nil {
+			log.Fatalf("Error opening CSV file: %v", err)
+		}
+		docs, err := configsFromCSV(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("Error reading CSV file %s: %v", *fromCSV, err)
+		}
+		runJobs(len(docs), *jobs, func(i int) {
+			source := fmt.Sprintf("%s row %d", *fromCSV, i+2)
+			process(source, func(opts runOptions) error { return processConfig(source, docs[i], opts) })
+		})
+		finish()
+		return
+	}
+
+	// Walk through the config directory, then process each config file.
+	var paths []string
+	err = filepath.Walk(*configDir, func(path string, info fs.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
-		if !info.IsDir() && strings.HasSuffix(info.Name(), ".json") {
-			log.Printf("Processing config: %s\n", path)
-			processConfigFile(path, *outputDir)
+		if isConfigFile(info) {
+			paths = append(paths, path)
 		}
 		return nil
 	})
 	if err != nil {
 		log.Fatalf("Error walking through config directory: %v", err)
 	}
+	sortConfigPaths(paths, *configOrder)
+	runJobs(len(paths), *jobs, func(i int) {
+		process(paths[i], func(opts runOptions) error { return processConfigFile(paths[i], opts) })
+	})
+	if !*watch {
+		finish()
+		return
+	}
+
+	saveCache()
+	log.Printf("Done: %d succeeded, %d skipped, %d failed\n", succeeded, skipped, failed)
+	watchConfigs(*configDir, *configOrder, func(path string) {
+		if jlog != nil {
+			process(path, func(opts runOptions) error { return processConfigFile(path, opts) })
+			saveCache()
+			return
+		}
+		start := time.Now()
+		err := recoverConfig(path, func() error { return processConfigFile(path, opts) })
+		if errors.Is(err, errUnchanged) {
+			log.Printf("Unchanged, skipped %s\n", path)
+			return
+		}
+		if err != nil {
+			log.Printf("Error processing %s: %v\n", path, err)
+			return
+		}
+		saveCache()
+		log.Printf("Regenerated %s in %v\n", path, time.Since(start).Round(time.Microsecond))
+	})
 }
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"image"
+	"image/png"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// exists reports whether a file exists at path.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestChecksumMatchesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "look.cube")
+	if err := writeOutput(path, "LUT_3D_SIZE 2\n0 0 0\n", true); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum, err := os.ReadFile(path + ".sha256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("%x  look.cube\n", sha256.Sum256(data)); string(sum) != want {
+		t.Errorf("checksum file = %q, want %q", sum, want)
+	}
+
+	plain := filepath.Join(dir, "plain.cube")
+	if err := writeOutput(plain, "LUT_3D_SIZE 2\n", false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(plain + ".sha256"); !os.IsNotExist(err) {
+		t.Errorf("checksum written without -checksums: %v", err)
+	}
+}
+
+func TestPreviewWritesChartPNG(t *testing.T) {
+	dir := t.TempDir()
+	opts := runOptions{outputDir: dir, preview: true}
+	if err := processConfig("look.json", []byte(`{"size": 9, "look": "tealOrange", "output": "look.cube"}`), opts); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(filepath.Join(dir, "look.preview.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	img, err := png.Decode(f)
+	if err != nil {
+		t.Fatalf("decoding the preview: %v", err)
+	}
+	if want := image.Rect(0, 0, previewSize, previewSize); img.Bounds() != want {
+		t.Errorf("preview bounds %v, want %v", img.Bounds(), want)
+	}
+
+	if err := processConfig("shaper.json", []byte(`{"shaper_only": true, "output": "shaper.cube"}`), opts); err != nil {
+		t.Fatal(err)
+	}
+	if exists(filepath.Join(dir, "shaper.preview.png")) {
+		t.Error("a shaper-only config wrote a preview")
+	}
+}
+
+func TestConfigOutputDirOverridesGlobal(t *testing.T) {
+	global, shared := t.TempDir(), filepath.Join(t.TempDir(), "monitors")
+	opts := runOptions{outputDir: global}
+	configs := map[string]string{
+		"monitor.json": fmt.Sprintf(`{"size": 2, "output": "monitor.cube", "output_dir": %q}`, shared),
+		"plain.json":   `{"size": 2, "output": "plain.cube"}`,
+	}
+	for name, doc := range configs {
+		if err := processConfig(name, []byte(doc), opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	for _, path := range []string{filepath.Join(shared, "monitor.cube"), filepath.Join(global, "plain.cube")} {
+		if !exists(path) {
+			t.Errorf("%s was not written", path)
+		}
+	}
+	for _, path := range []string{filepath.Join(global, "monitor.cube"), filepath.Join(shared, "plain.cube")} {
+		if exists(path) {
+			t.Errorf("%s was written", path)
+		}
+	}
+}
+
+func TestMaxFileSizeRefusesHugeSizes(t *testing.T) {
+	dir := t.TempDir()
+	opts := runOptions{outputDir: dir, maxSize: 1 << 20}
+	err := processConfig("huge.json", []byte(`{"size": 256, "allow_any_size": true, "output": "huge.cube"}`), opts)
+	if err == nil || !strings.Contains(err.Error(), "exceeds -maxFileSize") {
+		t.Errorf("size 256 under a 1 MiB limit: error %v", err)
+	}
+	if exists(filepath.Join(dir, "huge.cube")) {
+		t.Error("refused LUT was written")
+	}
+	if err := processConfig("normal.json", []byte(`{"size": 33, "output": "normal.cube"}`), opts); err != nil {
+		t.Errorf("size 33 under a 1 MiB limit: %v", err)
+	}
+	if !exists(filepath.Join(dir, "normal.cube")) {
+		t.Error("normal LUT was not written")
+	}
+}
+
+func TestUnknownLookFailsConfig(t *testing.T) {
+	dir := t.TempDir()
+	err := processConfig("typo.json", []byte(`{"size": 2, "look": "tealorang", "output": "typo.cube"}`), runOptions{outputDir: dir})
+	if err == nil || !strings.Contains(err.Error(), `unknown look "tealorang"`) {
+		t.Errorf("look tealorang: error %v", err)
+	}
+	if exists(filepath.Join(dir, "typo.cube")) {
+		t.Error("LUT with an unknown look was written")
+	}
+}
+
+func TestPanickingConfigFailsAlone(t *testing.T) {
+	defer func(saved func(luts.Config) (*luts.Cube, luts.Stats)) { buildCube = saved }(buildCube)
+	buildCube = func(cfg luts.Config) (*luts.Cube, luts.Stats) {
+		if cfg.Look == "warmVintage" {
+			panic("look failed")
+		}
+		return luts.BuildCube(cfg)
+	}
+	configDir, outputDir := t.TempDir(), t.TempDir()
+	names := []string{"a", "bad", "c"}
+	looks := []string{"none", "warmVintage", "tealOrange"}
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(configDir, name+".json")
+		doc := fmt.Sprintf(`{"size": 2, "look": %q, "output": "%s.cube"}`, looks[i], name)
+		if err := os.WriteFile(paths[i], []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := runOptions{configDir: configDir, outputDir: outputDir}
+	errs := make([]error, len(paths))
+	runJobs(len(paths), 3, func(i int) {
+		errs[i] = recoverConfig(paths[i], func() error { return processConfigFile(paths[i], opts) })
+	})
+	for i, name := range names {
+		written := exists(filepath.Join(outputDir, name+".cube"))
+		if name == "bad" {
+			if errs[i] == nil || !strings.Contains(errs[i].Error(), "panic: look failed") {
+				t.Errorf("%s: error %v, want the panic", name, errs[i])
+			}
+			if written {
+				t.Errorf("%s: output written despite the panic", name)
+			}
+			continue
+		}
+		if errs[i] != nil || !written {
+			t.Errorf("%s: error %v, written %v", name, errs[i], written)
+		}
+	}
+}
+
+func TestNestedConfigsMirrorSubdirectories(t *testing.T) {
+	configDir, outputDir := t.TempDir(), t.TempDir()
+	absolute := filepath.Join(t.TempDir(), "absolute.cube")
+	configs := map[string]string{
+		"projectA/shot1.json":    `{"size": 2, "output": "output.cube", "look": "tealOrange"}`,
+		"projectB/shot1.json":    `{"size": 2, "output": "output.cube", "look": "warmVintage"}`,
+		"projectB/deep/abs.json": fmt.Sprintf(`{"size": 2, "output": %q}`, absolute),
+		"top.json":               `{"size": 2, "output": "top.cube"}`,
+	}
+	opts := runOptions{configDir: configDir, outputDir: outputDir}
+	for name, doc := range configs {
+		path := filepath.Join(configDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := processConfigFile(path, opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	a, errA := os.ReadFile(filepath.Join(outputDir, "projectA", "output.cube"))
+	b, errB := os.ReadFile(filepath.Join(outputDir, "projectB", "output.cube"))
+	if errA != nil || errB != nil {
+		t.Fatalf("mirrored outputs missing: %v, %v", errA, errB)
+	}
+	if string(a) == string(b) {
+		t.Error("projectA and projectB outputs are identical; one overwrote the other")
+	}
+	for _, path := range []string{absolute, filepath.Join(outputDir, "top.cube")} {
+		if !exists(path) {
+			t.Errorf("%s was not written", path)
+		}
+	}
+	if exists(filepath.Join(outputDir, "output.cube")) {
+		t.Error("a nested config wrote into the top of the output directory")
+	}
+}
+
+func TestConcurrentJobsMatchSerial(t *testing.T) {
+	configDir := t.TempDir()
+	var paths []string
+	for i, look := range []string{"none", "tealOrange", "warmVintage", "bleachBypass", "filmPrint"} {
+		for _, sub := range []string{"a", "b", filepath.Join("b", "shared")} {
+			path := filepath.Join(configDir, sub, fmt.Sprintf("shot%d.json", i))
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			doc := fmt.Sprintf(`{"size": 9, "look": %q, "output": "shot%d.cube"}`, look, i)
+			if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+	}
+	run := func(jobs int) string {
+		outputDir := t.TempDir()
+		opts := runOptions{configDir: configDir, outputDir: outputDir}
+		errs := make([]error, len(paths))
+		runJobs(len(paths), jobs, func(i int) { errs[i] = processConfigFile(paths[i], opts) })
+		for i, err := range errs {
+			if err != nil {
+				t.Fatalf("%d jobs: %s: %v", jobs, paths[i], err)
+			}
+		}
+		return outputDir
+	}
+	serial, concurrent := run(1), run(8)
+	for _, path := range paths {
+		rel, _ := filepath.Rel(configDir, path)
+		rel = strings.TrimSuffix(rel, ".json") + ".cube"
+		want, err := os.ReadFile(filepath.Join(serial, rel))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := os.ReadFile(filepath.Join(concurrent, rel)); err != nil || string(got) != string(want) {
+			t.Errorf("%s: concurrent output differs from serial (%v)", rel, err)
+		}
+	}
+}
+
+func TestConfigOrderIndependentOfJobs(t *testing.T) {
+	configDir := t.TempDir()
+	var paths []string
+	for _, name := range []string{"c.json", "a.json", filepath.Join("b", "z.json"), "b.json", filepath.Join("b", "a.json")} {
+		path := filepath.Join(configDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		doc := fmt.Sprintf(`{"size": 5, "look": "tealOrange", "output": %q}`, strings.TrimSuffix(filepath.Base(name), ".json")+".cube")
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	want := slices.Clone(paths)
+	slices.Sort(want)
+
+	for _, order := range []string{"name", "reverse"} {
+		var first []string
+		for jobs := 1; jobs <= 8; jobs *= 2 {
+			shuffled := slices.Clone(paths)
+			rand.New(rand.NewPCG(uint64(jobs), 1)).Shuffle(len(shuffled), func(i, j int) {
+				shuffled[i], shuffled[j] = shuffled[j], shuffled[i]
+			})
+			if err := sortConfigPaths(shuffled, order); err != nil {
+				t.Fatal(err)
+			}
+			if first == nil {
+				first = shuffled
+			} else if !slices.Equal(shuffled, first) {
+				t.Errorf("-sort %s with %d jobs: order %v, want %v", order, jobs, shuffled, first)
+			}
+
+			outputDir := t.TempDir()
+			written := make([][]string, len(shuffled))
+			var started []string
+			var mu sync.Mutex
+			runJobs(len(shuffled), jobs, func(i int) {
+				mu.Lock()
+				started = append(started, shuffled[i])
+				mu.Unlock()
+				opts := runOptions{configDir: configDir, outputDir: outputDir, written: &written[i]}
+				if err := processConfigFile(shuffled[i], opts); err != nil {
+					t.Errorf("%s: %v", shuffled[i], err)
+				}
+			})
+			if jobs == 1 && !slices.Equal(started, shuffled) {
+				t.Errorf("-sort %s with one job processed %v, want %v", order, started, shuffled)
+			}
+			for i, path := range shuffled {
+				var rel []string
+				for _, name := range written[i] {
+					r, _ := filepath.Rel(outputDir, name)
+					rel = append(rel, r)
+				}
+				base, _ := filepath.Rel(configDir, strings.TrimSuffix(path, ".json"))
+				if exp := []string{base + ".cube"}; !slices.Equal(rel, exp) {
+					t.Errorf("%s with %d jobs wrote %v, want %v", path, jobs, rel, exp)
+				}
+			}
+		}
+		if order == "reverse" {
+			slices.Reverse(first)
+		}
+		if !slices.Equal(first, want) {
+			t.Errorf("-sort %s: %v, want the paths sorted by name", order, first)
+		}
+	}
+	if err := sortConfigPaths(nil, "mtime"); err == nil || !strings.Contains(err.Error(), "valid: name, reverse") {
+		t.Errorf("-sort mtime: error %v", err)
+	}
+}
+
+func TestWrittenTextUsesConfiguredLineEnding(t *testing.T) {
+	for _, ending := range []string{"lf", "crlf"} {
+		for name, doc := range map[string]string{
+			"cube":      `{"size": 5, "output": "out.cube"}`,
+			"3dl":       `{"size": 5, "output": "out.3dl"}`,
+			"vlt":       `{"size": 17, "output": "out.vlt"}`,
+			"dctl":      `{"output": "out.dctl"}`,
+			"shaper":    `{"shaper_only": true, "output": "out.cube"}`,
+			"look_pair": `{"size": 5, "look": "warmVintage", "look_pair": true, "output": "out.cube"}`,
+		} {
+			dir := t.TempDir()
+			doc = strings.Replace(doc, "{", fmt.Sprintf(`{"line_ending": %q, `, ending), 1)
+			var written []string
+			if err := processConfig(name+".json", []byte(doc), runOptions{outputDir: dir, written: &written}); err != nil {
+				t.Fatalf("%s, %s: %v", name, ending, err)
+			}
+			if len(written) == 0 {
+				t.Fatalf("%s, %s: nothing written", name, ending)
+			}
+			for _, path := range written {
+				data, err := os.ReadFile(path)
+				if err != nil {
+					t.Fatal(err)
+				}
+				text := string(data)
+				lines := strings.Count(text, "\n")
+				switch {
+				case strings.HasPrefix(text, "\uFEFF"):
+					t.Errorf("%s, %s: %s starts with a byte order mark", name, ending, filepath.Base(path))
+				case !strings.HasSuffix(text, "\n"):
+					t.Errorf("%s, %s: %s does not end with a newline", name, ending, filepath.Base(path))
+				case ending == "lf" && strings.Contains(text, "\r"):
+					t.Errorf("%s, %s: %s contains a carriage return", name, ending, filepath.Base(path))
+				case ending == "crlf" && strings.Count(text, "\r\n") != lines:
+					t.Errorf("%s, %s: %s ends %d of %d lines with \\r\\n", name, ending, filepath.Base(path), strings.Count(text, "\r\n"), lines)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// outputNameFuncs are the helpers available in output name templates, in
+// addition to text/template's builtins such as printf.
+var outputNameFuncs = template.FuncMap{
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+	// replace takes the subject last so it can be used in pipelines:
+	// {{.Look | replace "Orange" "Orng"}}
+	"replace": func(old, new, s string) string {
+		return strings.ReplaceAll(s, old, new)
+	},
+}
+
+// expandOutputName resolves cfg.Output as a text/template evaluated against
+// the config, e.g. "AppleLog_{{upper .Look}}_{{printf "%02d" .Size}}.cube".
+// Names without template actions are returned unchanged.
+func expandOutputName(cfg luts.Config) (string, error) {
+	if !strings.Contains(cfg.Output, "{{") {
+		return cfg.Output, nil
+	}
+	tmpl, err := template.New("output").Funcs(outputNameFuncs).Option("missingkey=error").Parse(cfg.Output)
+	if err != nil {
+		return "", fmt.Errorf("invalid output template: %w", err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, cfg); err != nil {
+		return "", fmt.Errorf("invalid output template: %w", err)
+	}
+	if b.Len() == 0 {
+		return "", fmt.Errorf("output template %q expands to an empty name", cfg.Output)
+	}
+	return b.String(), nil
+}
+
+// flattenName joins the components of a relative config directory and an
+// output name with underscores, so "projectA" and "shot1.cube" become
+// "projectA_shot1.cube". An empty dir leaves the name's own components.
+func flattenName(dir, name string) string {
+	parts := strings.Split(filepath.ToSlash(name), "/")
+	if dir != "" {
+		parts = append(strings.Split(filepath.ToSlash(dir), "/"), parts...)
+	}
+	return strings.Join(parts, "_")
+}
+
+// flatNames records which config claimed each flattened output path, so two
+// configs that flatten to the same name are reported instead of overwriting
+// each other. It is safe for concurrent use.
+type flatNames struct {
+	mu     sync.Mutex
+	owners map[string]string // Output path -> config path
+}
+
+// claim records path as an output of configPath, failing if another config
+// already claimed it.
+func (f *flatNames) claim(path, configPath string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if owner, ok := f.owners[path]; ok && owner != configPath {
+		return fmt.Errorf("flattened output %s collides with the output of %s", path, owner)
+	}
+	f.owners[path] = configPath
+	return nil
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+func TestExpandOutputName(t *testing.T) {
+	cfg := luts.Config{Look: "tealOrange", Size: 9, ExposureOffset: 1.25, Title: " Night Exterior "}
+	for _, tc := range []struct{ template, want string }{
+		{"plain.cube", "plain.cube"},
+		{`AppleLog_{{upper .Look}}_{{printf "%02d" .Size}}.cube`, "AppleLog_TEALORANGE_09.cube"},
+		{`{{lower .Look}}_{{printf "%.2f" .ExposureOffset}}.cube`, "tealorange_1.25.cube"},
+		{`{{.Look | replace "Orange" "Orng"}}.cube`, "tealOrng.cube"},
+		{`{{trim .Title | replace " " "_"}}.cube`, "Night_Exterior.cube"},
+	} {
+		cfg.Output = tc.template
+		got, err := expandOutputName(cfg)
+		if err != nil {
+			t.Errorf("%s: %v", tc.template, err)
+		} else if got != tc.want {
+			t.Errorf("%s = %q, want %q", tc.template, got, tc.want)
+		}
+	}
+}
+
+func TestExpandOutputNameErrors(t *testing.T) {
+	for _, template := range []string{"{{.Look", "{{.NoSuchField}}.cube", "{{if false}}x{{end}}"} {
+		if _, err := expandOutputName(luts.Config{Output: template}); err == nil {
+			t.Errorf("%s: no error", template)
+		}
+	}
+}
+
+func TestFlattenedNestedConfigsGetUniqueNames(t *testing.T) {
+	configDir, outputDir := t.TempDir(), t.TempDir()
+	opts := runOptions{configDir: configDir, outputDir: outputDir, flatten: &flatNames{owners: map[string]string{}}}
+	write := func(name, doc string) string {
+		path := filepath.Join(configDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(doc), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	for _, name := range []string{"projectA/shot1.json", "projectB/shot1.json", "projectB/deep/shot1.json"} {
+		if err := processConfigFile(write(name, `{"size": 2, "output": "shot1.cube"}`), opts); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"projectA_shot1.cube", "projectB_shot1.cube", "projectB_deep_shot1.cube"} {
+		if !exists(filepath.Join(outputDir, name)) {
+			t.Errorf("%s was not written", name)
+		}
+	}
+
+	clash := write("top.json", `{"size": 2, "output": "projectA_shot1.cube"}`)
+	err := processConfigFile(clash, opts)
+	if err == nil || !strings.Contains(err.Error(), "collides with the output of") {
+		t.Errorf("config claiming a flattened name: error %v", err)
+	}
+}
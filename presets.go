@@ -0,0 +1,86 @@
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// presetFS holds the preset library shipped with the binary.
+//
+//go:embed presets/*.json
+var presetFS embed.FS
+
+// presetNames returns the names of all bundled presets, sorted.
+func presetNames() []string {
+	entries, err := presetFS.ReadDir("presets")
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimSuffix(e.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadPreset returns the raw JSON of the named preset. Names are matched case-insensitively.
+func loadPreset(name string) ([]byte, error) {
+	for _, n := range presetNames() {
+		if strings.EqualFold(n, name) {
+			return presetFS.ReadFile(path.Join("presets", n+".json"))
+		}
+	}
+	return nil, fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presetNames(), ", "))
+}
+
+// parseConfig decodes a config JSON document on top of base, which holds the
+// run-wide defaults from the -default* flags. When the document selects a
+// preset, the preset is decoded first and the document is applied on top of
+// it, so any field set explicitly in the config overrides the preset value,
+// and either overrides base. A target set in the document or preset replaces
+// a target_color_space from base, since the two cannot be combined; setting
+// both in the document or preset is left for Validate to reject.
+func parseConfig(data []byte, base luts.Config) (luts.Config, error) {
+	cfg := base
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return luts.Config{}, err
+	}
+	explicitSpace := setsKey(data, "target_color_space")
+	if preset := cfg.Preset; preset != "" {
+		presetData, err := loadPreset(preset)
+		if err != nil {
+			return luts.Config{}, err
+		}
+		cfg = base
+		if err := json.Unmarshal(presetData, &cfg); err != nil {
+			return luts.Config{}, fmt.Errorf("preset %s: %w", preset, err)
+		}
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return luts.Config{}, err
+		}
+		explicitSpace = explicitSpace || setsKey(presetData, "target_color_space")
+	}
+	// Only an inherited target_color_space gives way; one set alongside
+	// target is left for Validate to report as a conflict.
+	if cfg.Target != "" && !explicitSpace {
+		cfg.TargetColorSpace = ""
+	}
+	return cfg, nil
+}
+
+// setsKey reports whether the JSON object in data has the top-level key.
+func setsKey(data []byte, key string) bool {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return false
+	}
+	_, ok := fields[key]
+	return ok
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+func TestPresetPopulatesFields(t *testing.T) {
+	cfg, err := parseConfig([]byte(`{"preset": "cinematicWarm"}`), luts.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Size != 33 || cfg.Look != "warmVintage" || cfg.ExposureOffset != 1.05 || cfg.RedTint != 1.08 || cfg.BlueTint != 0.92 {
+		t.Errorf("cinematicWarm = size %d, look %q, exposure %g, tints %g/%g", cfg.Size, cfg.Look, cfg.ExposureOffset, cfg.RedTint, cfg.BlueTint)
+	}
+}
+
+func TestExplicitFieldsOverridePreset(t *testing.T) {
+	cfg, err := parseConfig([]byte(`{"preset": "CoolNight", "size": 17, "look": "none"}`), luts.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Size != 17 || cfg.Look != "none" {
+		t.Errorf("explicit fields: size %d, look %q, want 17 and none", cfg.Size, cfg.Look)
+	}
+	if cfg.ExposureOffset != 0.85 || cfg.BlueTint != 1.08 {
+		t.Errorf("preset fields: exposure %g, blue tint %g, want 0.85 and 1.08", cfg.ExposureOffset, cfg.BlueTint)
+	}
+}
+
+func TestUnknownPreset(t *testing.T) {
+	if _, err := parseConfig([]byte(`{"preset": "noSuchPreset"}`), luts.Config{}); err == nil {
+		t.Error("parseConfig accepted an unknown preset")
+	}
+}
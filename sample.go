@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// parseTriplet parses a color given as "r,g,b".
+func parseTriplet(s string) ([3]float64, error) {
+	var v [3]float64
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return v, fmt.Errorf("want three comma-separated values, got %q", s)
+	}
+	for c, part := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return v, fmt.Errorf("invalid value %q: %w", part, err)
+		}
+		v[c] = f
+	}
+	return v, nil
+}
+
+// printSample writes the pipeline stages of the encoded input color in to w,
+// as traced by luts.TracePixel under cfg.
+func printSample(w io.Writer, cfg luts.Config, in [3]float64) {
+	t := luts.TracePixel(cfg, in[0], in[1], in[2])
+	row := func(label string, v [3]float64) {
+		fmt.Fprintf(w, "%-16s %.6f %.6f %.6f\n", label+":", v[0], v[1], v[2])
+	}
+	row("Input", t.Input)
+	if !cfg.Identity && !cfg.Invert {
+		row("Decoded linear", t.Decoded)
+		row("Target linear", t.Target)
+		row("Encoded", t.Encoded)
+	}
+	row("Graded", t.Graded)
+	row("Final", t.Final)
+}
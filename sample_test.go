@@ -0,0 +1,29 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+func TestPrintSampleFinalMatchesCube(t *testing.T) {
+	cfg := luts.Config{Size: 9, OutputBlack: 0.1, Look: "tealOrange"}
+	cfg.SetDefaults()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	cube, _ := luts.BuildCube(cfg)
+	for _, node := range [][3]int{{0, 0, 0}, {2, 5, 7}, {8, 8, 8}} {
+		in := [3]float64{float64(node[0]) / 8, float64(node[1]) / 8, float64(node[2]) / 8}
+		var out bytes.Buffer
+		printSample(&out, cfg, in)
+		v := cube.Data[(node[0]*cube.Size+node[1])*cube.Size+node[2]]
+		want := fmt.Sprintf("%-16s %.6f %.6f %.6f\n", "Final:", v[0], v[1], v[2])
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("-sample %v printed\n%s\nwant %q", in, out.String(), want)
+		}
+	}
+}
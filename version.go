@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+// Build information, set at build time with
+//
+//	go build -ldflags "-X main.version=v1.2.0 -X main.commit=$(git rev-parse HEAD) -X main.buildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version   = "dev"
+	commit    = "unknown"
+	buildDate = "unknown"
+)
+
+// buildCommit returns commit, falling back to the VCS revision recorded by
+// the Go toolchain when it was not set with -ldflags.
+func buildCommit() string {
+	if commit != "unknown" {
+		return commit
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				return s.Value
+			}
+		}
+	}
+	return commit
+}
+
+// versionString describes the running build for -version and LUT headers.
+func versionString() string {
+	return fmt.Sprintf("loglutgen %s (commit %s, built %s)", version, buildCommit(), buildDate)
+}
+
+// stampVersion adds a comment recording the generator build to the start of
+// LUT text in the cube and 3dl formats, and to DCTL source. Other formats are
+// returned unchanged, since vlt files must open with their own header and
+// HALD CLUTs are images. The comment ends with cfg's line ending.
+func stampVersion(cfg luts.Config, data string) string {
+	switch strings.ToLower(cfg.OutputFormat) {
+	case "cube", "3dl":
+		return "# " + versionString() + cfg.Newline() + data
+	case "dctl":
+		return "// " + versionString() + cfg.Newline() + data
+	}
+	return data
+}
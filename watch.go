@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"log"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Polling intervals for -watch. A changed file is regenerated only once it
+// has stayed unchanged for watchDebounce, so editors that write a file twice
+// in quick succession trigger a single regeneration.
+const (
+	watchInterval = 250 * time.Millisecond
+	watchDebounce = 500 * time.Millisecond
+)
+
+// fileStamp identifies one version of a file on disk.
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+// isConfigFile reports whether a directory entry is a JSON or YAML config to process.
+func isConfigFile(info fs.FileInfo) bool {
+	return !info.IsDir() && (strings.HasSuffix(info.Name(), ".json") || isYAML(info.Name())) && info.Name() != cacheFileName
+}
+
+// sortConfigPaths orders config paths for processing: "name" sorts them by
+// path, and "reverse" by path in descending order. Either way the order
+// depends only on the paths, not on how the filesystem enumerates them.
+func sortConfigPaths(paths []string, order string) error {
+	switch order {
+	case "name":
+		slices.Sort(paths)
+	case "reverse":
+		slices.Sort(paths)
+		slices.Reverse(paths)
+	default:
+		return fmt.Errorf("unknown -sort %q (valid: name, reverse)", order)
+	}
+	return nil
+}
+
+// scanConfigs returns the stamp of every config file under dir. Files that
+// vanish or cannot be read mid-walk are left out.
+func scanConfigs(dir string) map[string]fileStamp {
+	stamps := make(map[string]fileStamp)
+	filepath.Walk(dir, func(path string, info fs.FileInfo, err error) error {
+		if err == nil && isConfigFile(info) {
+			stamps[path] = fileStamp{info.ModTime(), info.Size()}
+		}
+		return nil
+	})
+	return stamps
+}
+
+// watchConfigs polls dir forever and calls regenerate for each config file
+// that is created or modified, once it has settled. Configs that settle in
+// the same poll are regenerated in the given -sort order.
+func watchConfigs(dir, order string, regenerate func(path string)) {
+	log.Printf("Watching %s for config changes\n", dir)
+	seen := scanConfigs(dir)
+	pending := make(map[string]time.Time) // Path -> time the last change was seen
+	for range time.Tick(watchInterval) {
+		now := time.Now()
+		current := scanConfigs(dir)
+		for path, stamp := range current {
+			if prev, ok := seen[path]; !ok || prev != stamp {
+				pending[path] = now
+			}
+		}
+		seen = current
+		var settled []string
+		for path, changed := range pending {
+			if _, ok := current[path]; !ok {
+				delete(pending, path) // Removed before it settled
+				continue
+			}
+			if now.Sub(changed) >= watchDebounce {
+				delete(pending, path)
+				settled = append(settled, path)
+			}
+		}
+		sortConfigPaths(settled, order) // order was checked at startup
+		for _, path := range settled {
+			regenerate(path)
+		}
+	}
+}
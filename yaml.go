@@ -0,0 +1,50 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// isYAML reports whether path names a YAML config file.
+func isYAML(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return true
+	}
+	return false
+}
+
+// yamlToJSON converts a YAML config document to the equivalent JSON, so YAML
+// configs go through the same parsing, presets, and validation as JSON ones
+// and use the same keys (exposure_offset and so on).
+func yamlToJSON(data []byte) ([]byte, error) {
+	var doc any
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return []byte("{}"), nil
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("converting YAML: %w", err)
+	}
+	return out, nil
+}
+
+// readConfigFile reads a JSON or YAML config file and returns it as JSON.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil || !isYAML(path) {
+		return data, err
+	}
+	if data, err = yamlToJSON(data); err != nil {
+		return nil, fmt.Errorf("parsing YAML: %w", err)
+	}
+	return data, nil
+}
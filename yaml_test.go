@@ -0,0 +1,63 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/flaticols/loglutgen/luts"
+)
+
+func writeTestFile(t *testing.T, dir, name, data string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestYAMLMatchesJSON(t *testing.T) {
+	dir := t.TempDir()
+	jsonPath := writeTestFile(t, dir, "look.json", `{
+  "title": "Teal",
+  "look": "tealOrange",
+  "exposure_offset": 1.1,
+  "lift": [0.01, 0, -0.01],
+  "size": 17
+}`)
+	yamlPath := writeTestFile(t, dir, "look.yaml", `title: Teal
+look: tealOrange
+exposure_offset: 1.1
+lift: [0.01, 0, -0.01]
+size: 17
+`)
+
+	var outputs []string
+	for _, path := range []string{jsonPath, yamlPath} {
+		cfg, err := loadSingleConfig(path, luts.Config{})
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		data, err := luts.Generate(cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		outputs = append(outputs, data)
+	}
+	if outputs[0] != outputs[1] {
+		t.Error("JSON and YAML configs produced different LUTs")
+	}
+}
+
+func TestYAMLParseErrorNamesConfig(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "bad.yml", "size: seventeen\n")
+	_, err := loadSingleConfig(path, luts.Config{})
+	if err == nil {
+		t.Fatal("loadSingleConfig accepted a string size")
+	}
+	if !strings.HasPrefix(err.Error(), "parsing config "+path+": ") {
+		t.Errorf("error = %v, want it to name the config", err)
+	}
+}